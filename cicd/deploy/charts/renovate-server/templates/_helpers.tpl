@@ -64,3 +64,11 @@ Create the name of the service account to use
 {{- define "renovate-server.webhookPort" -}}
 {{- index (split ":" (default ":0" .Values.config.server.webhook.listen)) "_1" -}}
 {{- end }}
+
+{{- define "renovate-server.monitoringPort" -}}
+{{- index (split ":" .Values.config.server.monitoring.listen) "_1" -}}
+{{- end }}
+
+{{- define "renovate-server.adminPort" -}}
+{{- index (split ":" (default ":8081" .Values.config.server.admin.listen)) "_1" -}}
+{{- end }}
@@ -33,6 +33,7 @@ func main() {
 
 	rootCmd := cmd.NewRenovateServerCmd()
 	rootCmd.AddCommand(version.NewVersionCmd())
+	rootCmd.AddCommand(cmd.NewValidateCmd())
 
 	err := rootCmd.Execute()
 	if err != nil {
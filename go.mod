@@ -5,6 +5,8 @@ go 1.16
 require (
 	arhat.dev/pkg v0.5.8
 	github.com/google/go-github/v36 v36.0.0
+	github.com/prometheus/client_golang v1.11.0
+	github.com/prometheus/client_model v0.2.0
 	github.com/robfig/cron/v3 v3.0.1
 	github.com/spf13/cobra v1.2.1
 	github.com/spf13/pflag v1.0.5
@@ -0,0 +1,211 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	apiVersion   = "6.0"
+	listPageSize = 100
+
+	// pull request properties api is still in preview
+	propertiesAPIVersion = "6.0-preview.1"
+
+	// pull request property recording the last seen description, service hooks
+	// do not include previous description of updated pull requests
+	prDescriptionProperty = "renovate-server.description"
+
+	headerContinuationToken = "X-Ms-Continuationtoken"
+)
+
+// client is a minimal azure devops rest api client for an organization
+type client struct {
+	orgURL *url.URL
+	token  string
+	http   *http.Client
+}
+
+type project struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type repository struct {
+	ID            string  `json:"id"`
+	Name          string  `json:"name"`
+	Project       project `json:"project"`
+	IsDisabled    bool    `json:"isDisabled"`
+	DefaultBranch string  `json:"defaultBranch"`
+}
+
+// FullName is the repo name used by renovate
+func (r *repository) FullName() string {
+	return r.Project.Name + "/" + r.Name
+}
+
+// listRepos lists git repos across all projects of the organization
+func (c *client) listRepos(ctx context.Context) ([]repository, error) {
+	projects, err := c.listProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []repository
+	for _, p := range projects {
+		var result struct {
+			Value []repository `json:"value"`
+		}
+
+		_, err = c.get(ctx, url.PathEscape(p.Name)+"/_apis/git/repositories", nil, &result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repos of project %q: %w", p.Name, err)
+		}
+
+		ret = append(ret, result.Value...)
+	}
+
+	return ret, nil
+}
+
+func (c *client) listProjects(ctx context.Context) ([]project, error) {
+	var (
+		ret               []project
+		continuationToken string
+	)
+	for {
+		query := url.Values{"$top": {strconv.FormatInt(listPageSize, 10)}}
+		if continuationToken != "" {
+			query.Set("continuationToken", continuationToken)
+		}
+
+		var result struct {
+			Value []project `json:"value"`
+		}
+
+		header, err := c.get(ctx, "_apis/projects", query, &result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list projects: %w", err)
+		}
+
+		ret = append(ret, result.Value...)
+
+		continuationToken = header.Get(headerContinuationToken)
+		if continuationToken == "" || len(result.Value) == 0 {
+			return ret, nil
+		}
+	}
+}
+
+// getPRDescription returns the pull request description recorded in pull request
+// properties, empty if never recorded
+func (c *client) getPRDescription(ctx context.Context, r *repository, id int) (string, error) {
+	var result struct {
+		Value map[string]struct {
+			Value string `json:"$value"`
+		} `json:"value"`
+	}
+
+	_, err := c.do(ctx, http.MethodGet, prPropertiesPath(r, id), propertiesAPIVersion, nil, nil, &result)
+	if err != nil {
+		return "", fmt.Errorf("failed to get properties of pull request %d: %w", id, err)
+	}
+
+	return result.Value[prDescriptionProperty].Value, nil
+}
+
+// setPRDescription records the pull request description in pull request properties
+func (c *client) setPRDescription(ctx context.Context, r *repository, id int, desc string) error {
+	patch := []map[string]string{{
+		"op":    "add",
+		"path":  "/" + prDescriptionProperty,
+		"value": desc,
+	}}
+
+	_, err := c.do(ctx, http.MethodPatch, prPropertiesPath(r, id), propertiesAPIVersion, nil, patch, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update properties of pull request %d: %w", id, err)
+	}
+
+	return nil
+}
+
+func prPropertiesPath(r *repository, id int) string {
+	return url.PathEscape(r.Project.Name) + "/_apis/git/repositories/" + url.PathEscape(r.ID) +
+		"/pullRequests/" + strconv.FormatInt(int64(id), 10) + "/properties"
+}
+
+func (c *client) get(ctx context.Context, path string, query url.Values, out interface{}) (http.Header, error) {
+	return c.do(ctx, http.MethodGet, path, apiVersion, query, nil, out)
+}
+
+// do sends a request to the api, body is sent as json patch document when not nil,
+// and the response is decoded into out when not nil
+func (c *client) do(
+	ctx context.Context,
+	method, path, version string,
+	query url.Values,
+	body, out interface{},
+) (http.Header, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("api-version", version)
+
+	ref, err := url.Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid api path: %w", err)
+	}
+	ref.RawQuery = query.Encode()
+	u := c.orgURL.ResolveReference(ref)
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err2 := json.Marshal(body)
+		if err2 != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %w", err2)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// personal access token is used as basic auth password
+	req.SetBasicAuth("", c.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json-patch+json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request %s: %w", u.Path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("unexpected response %s for %s: %s", resp.Status, u.Path, msg)
+	}
+
+	if out == nil {
+		return resp.Header, nil
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode response of %s: %w", u.Path, err)
+	}
+
+	return resp.Header, nil
+}
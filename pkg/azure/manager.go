@@ -0,0 +1,177 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"arhat.dev/pkg/log"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/filter"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+// NewManager creates platform manager for an azure devops organization
+func NewManager(
+	ctx context.Context,
+	config *conf.PlatformConfig,
+	scheduler types.Scheduler,
+) (types.PlatformManager, error) {
+	var (
+		err error
+
+		disabledRepoNameMatch *regexp.Regexp
+	)
+	if config.DisabledRepoNameMatch != "" {
+		disabledRepoNameMatch, err = regexp.Compile(config.DisabledRepoNameMatch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile disabled repo match: %w", err)
+		}
+	}
+
+	httpClient, err := config.API.Client.NewClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http client")
+	}
+
+	// organization url, e.g. https://dev.azure.com/my-org/
+	baseURL := config.API.BaseURL
+	if baseURL == "" {
+		return nil, fmt.Errorf("no organization url provided")
+	}
+
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+
+	orgURL, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid azure devops organization url: %w", err)
+	}
+
+	if config.API.OAuthToken == "" {
+		return nil, fmt.Errorf("no personal access token provided")
+	}
+
+	pushFilter, err := filter.NewPushFilter(&config.Push, &config.Git)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push filter: %w", err)
+	}
+
+	disabledRepos := make(map[string]struct{})
+	for _, p := range config.Projects {
+		if p.Disabled {
+			disabledRepos[p.Name] = struct{}{}
+		}
+	}
+
+	return &Manager{
+		ctx: ctx,
+
+		logger: log.Log.WithName("azure").WithFields(
+			log.String("path", config.Webhook.Path),
+			log.String("api", config.API.BaseURL),
+		),
+		client: &client{
+			orgURL: orgURL,
+			token:  config.API.OAuthToken,
+			http:   httpClient,
+		},
+		scheduler: scheduler,
+
+		disabledRepoNameMatch: disabledRepoNameMatch,
+		disabledRepos:         disabledRepos,
+		pushFilter:            pushFilter,
+
+		apiURL:   baseURL,
+		apiToken: config.API.OAuthToken,
+		gitUser:  config.Git.User,
+		gitEmail: config.Git.Email,
+
+		webhookUsername: config.Webhook.Username,
+		webhookPassword: config.Webhook.Secret,
+	}, nil
+}
+
+// Manager of azure devops, there is no issue tracker for renovate dashboard in
+// azure repos, so dashboard issue title is not used
+type Manager struct {
+	ctx context.Context
+
+	logger    log.Interface
+	client    *client
+	scheduler types.Scheduler
+
+	disabledRepoNameMatch *regexp.Regexp
+	disabledRepos         map[string]struct{}
+	pushFilter            *filter.PushFilter
+
+	apiURL   string
+	apiToken string
+	gitUser  string
+	gitEmail string
+
+	webhookUsername string
+	webhookPassword string
+}
+
+func (m *Manager) ListRepos() ([]string, error) {
+	repos, err := m.client.listRepos(m.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list all repos: %w", err)
+	}
+
+	var ret []string
+	for i := range repos {
+		if repos[i].IsDisabled {
+			continue
+		}
+
+		name := repos[i].FullName()
+		if m.disabled(name) {
+			continue
+		}
+
+		ret = append(ret, name)
+	}
+
+	return ret, nil
+}
+
+// disabled checks whether the repo is disabled by name match or project config
+func (m *Manager) disabled(repo string) bool {
+	if m.disabledRepoNameMatch != nil && m.disabledRepoNameMatch.MatchString(repo) {
+		return true
+	}
+
+	_, disabled := m.disabledRepos[repo]
+	return disabled
+}
+
+// CheckHealth checks credentials with azure devops api
+func (m *Manager) CheckHealth(ctx context.Context) error {
+	_, err := m.client.get(ctx, "_apis/projects", url.Values{"$top": {"1"}}, &struct{}{})
+	if err != nil {
+		return fmt.Errorf("failed to authenticate with azure devops api: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Manager) APIURL() string {
+	return m.apiURL
+}
+
+func (m *Manager) ExecutionArgs(repos ...string) (types.ExecutionArgs, error) {
+	return types.ExecutionArgs{
+		Platform: "azure",
+		APIURL:   m.apiURL,
+		APIToken: m.apiToken,
+		Repos:    repos,
+		GitUser:  m.gitUser,
+		GitEmail: m.gitEmail,
+	}, nil
+}
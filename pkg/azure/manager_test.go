@@ -0,0 +1,264 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+type fakeScheduler struct {
+	repos []string
+}
+
+func (s *fakeScheduler) Schedule(repos ...string) error {
+	s.repos = append(s.repos, repos...)
+	return nil
+}
+
+func newTestManager(t *testing.T, baseURL string, scheduler types.Scheduler) *Manager {
+	mgr, err := NewManager(context.TODO(), &conf.PlatformConfig{
+		API: conf.APIConfig{
+			BaseURL:    baseURL,
+			OAuthToken: "test-token",
+		},
+		Git: conf.GitConfig{
+			User:  "Renovate Bot",
+			Email: "bot@example.com",
+		},
+		Webhook: conf.WebhookConfig{
+			Username: "renovate",
+			Secret:   "test-secret",
+		},
+		DisabledRepoNameMatch: "^ignored/",
+		Projects: []conf.ProjectConfig{
+			{Name: "foo/disabled", Disabled: true},
+		},
+	}, scheduler)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return mgr.(*Manager)
+}
+
+func TestManager_ListRepos(t *testing.T) {
+	projects := []project{{Name: "foo"}, {Name: "ignored"}, {Name: "my project"}}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pass, _ := r.BasicAuth(); pass != "test-token" || r.URL.Query().Get("api-version") != apiVersion {
+			http.Error(w, "unexpected request", http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/my-org/_apis/projects":
+			// one project per page
+			i := 0
+			if ct := r.URL.Query().Get("continuationToken"); ct != "" {
+				_, _ = fmt.Sscanf(ct, "%d", &i)
+			}
+
+			if i+1 < len(projects) {
+				w.Header().Set(headerContinuationToken, fmt.Sprint(i+1))
+			}
+
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"value": projects[i : i+1]})
+		case "/my-org/foo/_apis/git/repositories":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"value": []repository{
+				{Name: "bar", Project: projects[0]},
+				{Name: "disabled", Project: projects[0]},
+				{Name: "old", Project: projects[0], IsDisabled: true},
+			}})
+		case "/my-org/ignored/_apis/git/repositories":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"value": []repository{
+				{Name: "bar", Project: projects[1]},
+			}})
+		case "/my-org/my project/_apis/git/repositories":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"value": []repository{
+				{Name: "baz", Project: projects[2]},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, srv.URL+"/my-org", &fakeScheduler{})
+	assert.Equal(t, srv.URL+"/my-org/", m.APIURL())
+
+	repos, err := m.ListRepos()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"foo/bar", "my project/baz"}, repos)
+
+	args, err := m.ExecutionArgs("foo/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "azure", args.Platform)
+}
+
+func TestManager_ServeHTTP(t *testing.T) {
+	prEvent := func(event string, id int, status, desc string) string {
+		return `{"eventType":"git.pullrequest.` + event + `","resource":{"pullRequestId":` + fmt.Sprint(id) + `,` +
+			`"status":"` + status + `","description":"` + desc + `",` +
+			`"repository":{"id":"r1","name":"bar","project":{"name":"foo"}}}}`
+	}
+
+	push := func(ref, pusher string) string {
+		return `{"eventType":"git.push","resource":{` +
+			`"refUpdates":[{"name":"` + ref + `","newObjectId":"1234"}],` +
+			`"pushedBy":{"displayName":"` + pusher + `","uniqueName":"` + pusher + `@example.com"},` +
+			`"repository":{"name":"bar","project":{"name":"foo"},"defaultBranch":"refs/heads/main"}}}`
+	}
+
+	// pull request properties by path
+	properties := make(map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("api-version") != propertiesAPIVersion {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			value := map[string]interface{}{}
+			if desc, ok := properties[r.URL.Path]; ok {
+				value[prDescriptionProperty] = map[string]string{"$type": "System.String", "$value": desc}
+			}
+
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"count": len(value), "value": value})
+		case http.MethodPatch:
+			var patch []struct {
+				Op    string `json:"op"`
+				Path  string `json:"path"`
+				Value string `json:"value"`
+			}
+			if r.Header.Get("Content-Type") != "application/json-patch+json" ||
+				json.NewDecoder(r.Body).Decode(&patch) != nil ||
+				len(patch) != 1 || patch[0].Path != "/"+prDescriptionProperty {
+				http.Error(w, "invalid patch", http.StatusBadRequest)
+				return
+			}
+
+			properties[r.URL.Path] = patch[0].Value
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	scheduler := &fakeScheduler{}
+	m := newTestManager(t, srv.URL+"/my-org", scheduler)
+
+	tests := []struct {
+		name    string
+		payload string
+		noAuth  bool
+
+		expectedCode  int
+		expectedRepos []string
+	}{
+		{
+			name:         "Unauthorized",
+			payload:      `{"eventType":"git.push","resource":{"repository":{"name":"bar","project":{"name":"foo"}}}}`,
+			noAuth:       true,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:          "Push",
+			payload:       push("refs/heads/main", "dev"),
+			expectedCode:  http.StatusOK,
+			expectedRepos: []string{"foo/bar"},
+		},
+		{
+			name:          "Push Renovate Branch",
+			payload:       push("refs/heads/renovate/foo-1.x", "dev"),
+			expectedCode:  http.StatusOK,
+			expectedRepos: []string{"foo/bar"},
+		},
+		{
+			name:          "Push By Renovate",
+			payload:       push("refs/heads/main", "bot"),
+			expectedCode:  http.StatusOK,
+			expectedRepos: []string{"foo/bar"},
+		},
+		{
+			name:          "PR Unchecked",
+			payload:       prEvent("updated", 1, "active", "- [ ] rebase"),
+			expectedCode:  http.StatusOK,
+			expectedRepos: []string{"foo/bar"},
+		},
+		{
+			name:          "PR Checked",
+			payload:       prEvent("updated", 1, "active", "- [x] rebase"),
+			expectedCode:  http.StatusOK,
+			expectedRepos: []string{"foo/bar", "foo/bar"},
+		},
+		{
+			name:          "PR Unchanged",
+			payload:       prEvent("updated", 1, "active", "- [x] rebase"),
+			expectedCode:  http.StatusOK,
+			expectedRepos: []string{"foo/bar", "foo/bar"},
+		},
+		{
+			name:          "PR Completed",
+			payload:       prEvent("updated", 1, "completed", "- [x] rebase"),
+			expectedCode:  http.StatusOK,
+			expectedRepos: []string{"foo/bar", "foo/bar", "foo/bar"},
+		},
+		{
+			name:          "PR Created Checked",
+			payload:       prEvent("created", 2, "active", "- [x] foo - [ ] rebase"),
+			expectedCode:  http.StatusOK,
+			expectedRepos: []string{"foo/bar", "foo/bar", "foo/bar"},
+		},
+		{
+			name:          "PR Created Then Updated",
+			payload:       prEvent("updated", 2, "active", "- [x] foo - [ ] rebase - [ ] other"),
+			expectedCode:  http.StatusOK,
+			expectedRepos: []string{"foo/bar", "foo/bar", "foo/bar"},
+		},
+		{
+			name:          "PR Created Then Checked",
+			payload:       prEvent("updated", 2, "active", "- [x] foo - [x] rebase - [ ] other"),
+			expectedCode:  http.StatusOK,
+			expectedRepos: []string{"foo/bar", "foo/bar", "foo/bar", "foo/bar"},
+		},
+		{
+			name: "Disabled Repo",
+			payload: `{"eventType":"git.pullrequest.updated","resource":{"status":"completed",` +
+				`"repository":{"name":"disabled","project":{"name":"foo"}}}}`,
+			expectedCode:  http.StatusOK,
+			expectedRepos: []string{"foo/bar", "foo/bar", "foo/bar", "foo/bar"},
+		},
+		{
+			name: "Disabled Repo Name Match",
+			payload: `{"eventType":"git.pullrequest.updated","resource":{"status":"completed",` +
+				`"repository":{"name":"bar","project":{"name":"ignored"}}}}`,
+			expectedCode:  http.StatusOK,
+			expectedRepos: []string{"foo/bar", "foo/bar", "foo/bar", "foo/bar"},
+		},
+	}
+
+	// executed in order, scheduled repos are accumulated
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(test.payload)))
+			if !test.noAuth {
+				req.SetBasicAuth("renovate", "test-secret")
+			}
+
+			rec := httptest.NewRecorder()
+			m.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.expectedCode, rec.Code)
+			assert.Equal(t, test.expectedRepos, scheduler.repos)
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package azure
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"arhat.dev/pkg/log"
+
+	"arhat.dev/renovate-server/pkg/filter"
+	"arhat.dev/renovate-server/pkg/util"
+)
+
+// emptyObjectID is the new object id of deleted refs
+const emptyObjectID = "0000000000000000000000000000000000000000"
+
+type refUpdate struct {
+	Name        string `json:"name"`
+	NewObjectID string `json:"newObjectId"`
+}
+
+type identityRef struct {
+	DisplayName string `json:"displayName"`
+	UniqueName  string `json:"uniqueName"`
+}
+
+type webhookPayload struct {
+	EventType string `json:"eventType"`
+	Resource  struct {
+		// git.push
+		Repository *repository  `json:"repository"`
+		RefUpdates []refUpdate  `json:"refUpdates"`
+		PushedBy   *identityRef `json:"pushedBy"`
+
+		// git.pullrequest.created, git.pullrequest.updated
+		PullRequestID int    `json:"pullRequestId"`
+		Status        string `json:"status"`
+		Description   string `json:"description"`
+	} `json:"resource"`
+}
+
+func (m *Manager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	logger := m.logger.WithFields()
+
+	defer func() {
+		err := recover()
+		if err != nil {
+			logger.E("recovered", log.Any("panic", err))
+		}
+	}()
+
+	logger.D("event received")
+
+	if !m.authorized(req) {
+		logger.I("unauthorized webhook request")
+		w.Header().Set("WWW-Authenticate", `Basic realm="renovate-server"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	evt := &webhookPayload{}
+	err := json.NewDecoder(req.Body).Decode(evt)
+	if err != nil {
+		logger.I("payload invalid", log.Error(err))
+		http.Error(w, "invalid webhook payload", http.StatusBadRequest)
+		return
+	}
+
+	repo := func() string {
+		if evt.Resource.Repository == nil {
+			logger.V("ignored event without repository", log.String("event", evt.EventType))
+			return ""
+		}
+
+		repo := evt.Resource.Repository.FullName()
+		logger = logger.WithFields(log.String("repo", repo))
+
+		switch evt.EventType {
+		case "git.pullrequest.created":
+			logger.V("received pull request created event")
+
+			// record initial description so checked items in it won't trigger renovate
+			err2 := m.client.setPRDescription(req.Context(),
+				evt.Resource.Repository, evt.Resource.PullRequestID, evt.Resource.Description)
+			if err2 != nil {
+				logger.I("failed to record pull request description", log.Error(err2))
+			}
+			return ""
+		case "git.pullrequest.updated":
+			logger.V("received pull request updated event")
+
+			switch evt.Resource.Status {
+			case "completed", "abandoned":
+				return repo
+			}
+
+			return m.matchPRUpdate(req.Context(), logger, evt)
+		case "git.push":
+			logger.V("received push event")
+
+			if reason := m.matchPush(evt); reason != "" {
+				logger.D("push ignored", log.String("reason", reason))
+				return ""
+			}
+
+			return repo
+		default:
+			logger.V("ignored event", log.String("event", evt.EventType))
+			return ""
+		}
+	}()
+	if repo == "" {
+		logger.I("no execution triggered")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if m.disabled(repo) {
+		logger.I("execution ignored")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	logger.I("scheduling renovate execution")
+
+	// run renovate against this repo
+	err = m.scheduler.Schedule(repo)
+	if err != nil {
+		logger.I("failed to schedule renovate execution", log.Error(err))
+		http.Error(w, "failed to execute renovate", http.StatusInternalServerError)
+		return
+	}
+
+	logger.I("scheduled renovate execution")
+	w.WriteHeader(http.StatusOK)
+}
+
+// matchPRUpdate returns the repo if any item in the pull request description
+// was checked since last seen
+func (m *Manager) matchPRUpdate(ctx context.Context, logger log.Interface, evt *webhookPayload) string {
+	r, id, desc := evt.Resource.Repository, evt.Resource.PullRequestID, evt.Resource.Description
+
+	// description not recorded is treated as nothing checked
+	oldBody, err := m.client.getPRDescription(ctx, r, id)
+	if err != nil {
+		logger.I("failed to get last seen pull request description", log.Error(err))
+		return ""
+	}
+
+	if oldBody != desc {
+		err = m.client.setPRDescription(ctx, r, id, desc)
+		if err != nil {
+			logger.I("failed to record pull request description", log.Error(err))
+		}
+	}
+
+	if util.ItemChecked(oldBody, desc) {
+		return r.FullName()
+	}
+
+	return ""
+}
+
+// matchPush returns empty string if any ref update of the push should trigger
+// renovate, otherwise the reason why it's ignored
+func (m *Manager) matchPush(evt *webhookPayload) string {
+	var users []string
+	if u := evt.Resource.PushedBy; u != nil {
+		users = []string{u.DisplayName, u.UniqueName}
+	}
+
+	reason := "no ref updated"
+	for _, u := range evt.Resource.RefUpdates {
+		if u.NewObjectID == emptyObjectID {
+			// ref deleted
+			continue
+		}
+
+		// changed files are not included in payload
+		reason = m.pushFilter.Match(&filter.Push{
+			Ref:           u.Name,
+			DefaultBranch: strings.TrimPrefix(evt.Resource.Repository.DefaultBranch, "refs/heads/"),
+			Users:         users,
+		})
+		if reason == "" {
+			return ""
+		}
+	}
+
+	return reason
+}
+
+// authorized checks basic auth credentials configured in the service hook
+func (m *Manager) authorized(req *http.Request) bool {
+	if m.webhookUsername == "" && m.webhookPassword == "" {
+		return true
+	}
+
+	user, pass, ok := req.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	userMatch := subtle.ConstantTimeCompare([]byte(user), []byte(m.webhookUsername))
+	passMatch := subtle.ConstantTimeCompare([]byte(pass), []byte(m.webhookPassword))
+	return userMatch&passMatch == 1
+}
@@ -0,0 +1,114 @@
+package bitbucketserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const listPageSize = 100
+
+// client is a minimal bitbucket server rest api 1.0 client
+type client struct {
+	apiURL *url.URL
+	token  string
+	http   *http.Client
+}
+
+type project struct {
+	Key string `json:"key"`
+}
+
+type repository struct {
+	Slug     string  `json:"slug"`
+	Project  project `json:"project"`
+	Archived bool    `json:"archived"`
+}
+
+// FullName is the repo name used by renovate
+func (r *repository) FullName() string {
+	return r.Project.Key + "/" + r.Slug
+}
+
+type repositoryPage struct {
+	IsLastPage    bool         `json:"isLastPage"`
+	NextPageStart int          `json:"nextPageStart"`
+	Values        []repository `json:"values"`
+}
+
+type branch struct {
+	ID        string `json:"id"`
+	DisplayID string `json:"displayId"`
+}
+
+// defaultBranch returns the default branch name of the repo
+func (c *client) defaultBranch(ctx context.Context, r *repository) (string, error) {
+	b := &branch{}
+	err := c.get(ctx, fmt.Sprintf("projects/%s/repos/%s/branches/default",
+		url.PathEscape(r.Project.Key), url.PathEscape(r.Slug)), nil, b)
+	if err != nil {
+		return "", err
+	}
+
+	return b.DisplayID, nil
+}
+
+// listRepos lists repos across all projects writable to the token owner
+func (c *client) listRepos(ctx context.Context) ([]repository, error) {
+	var ret []repository
+	start := 0
+	for {
+		p := &repositoryPage{}
+		err := c.get(ctx, "repos", url.Values{
+			"permission": {"REPO_WRITE"},
+			"state":      {"AVAILABLE"},
+			"start":      {strconv.FormatInt(int64(start), 10)},
+			"limit":      {strconv.FormatInt(listPageSize, 10)},
+		}, p)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, p.Values...)
+		if p.IsLastPage || len(p.Values) == 0 {
+			return ret, nil
+		}
+
+		start = p.NextPageStart
+	}
+}
+
+func (c *client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	u := c.apiURL.ResolveReference(&url.URL{Path: path, RawQuery: query.Encode()})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to request %s: %w", u.Path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected response %s for %s: %s", resp.Status, u.Path, msg)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("failed to decode response of %s: %w", u.Path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package bitbucketserver
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"arhat.dev/pkg/log"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/filter"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+// NewManager creates platform manager for bitbucket server (data center)
+func NewManager(
+	ctx context.Context,
+	config *conf.PlatformConfig,
+	scheduler types.Scheduler,
+) (types.PlatformManager, error) {
+	var (
+		err error
+
+		disabledRepoNameMatch *regexp.Regexp
+	)
+	if config.DisabledRepoNameMatch != "" {
+		disabledRepoNameMatch, err = regexp.Compile(config.DisabledRepoNameMatch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile disabled repo match: %w", err)
+		}
+	}
+
+	httpClient, err := config.API.Client.NewClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http client")
+	}
+
+	// self hosted only, no default
+	baseURL := config.API.BaseURL
+	if baseURL == "" {
+		return nil, fmt.Errorf("no base url provided")
+	}
+
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+
+	restURL, err := url.Parse(baseURL + "rest/api/1.0/")
+	if err != nil {
+		return nil, fmt.Errorf("invalid bitbucket server base url: %w", err)
+	}
+
+	if config.API.OAuthToken == "" {
+		return nil, fmt.Errorf("no oauth token provided")
+	}
+
+	pushFilter, err := filter.NewPushFilter(&config.Push, &config.Git)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push filter: %w", err)
+	}
+
+	disabledRepos := make(map[string]struct{})
+	for _, p := range config.Projects {
+		if p.Disabled {
+			disabledRepos[p.Name] = struct{}{}
+		}
+	}
+
+	return &Manager{
+		ctx: ctx,
+
+		logger: log.Log.WithName("bitbucket-server").WithFields(
+			log.String("path", config.Webhook.Path),
+			log.String("api", config.API.BaseURL),
+		),
+		client: &client{
+			apiURL: restURL,
+			token:  config.API.OAuthToken,
+			http:   httpClient,
+		},
+		scheduler: scheduler,
+
+		disabledRepoNameMatch: disabledRepoNameMatch,
+		disabledRepos:         disabledRepos,
+		pushFilter:            pushFilter,
+
+		apiURL:   baseURL,
+		apiToken: config.API.OAuthToken,
+		gitUser:  config.Git.User,
+		gitEmail: config.Git.Email,
+
+		webhookSecret: []byte(config.Webhook.Secret),
+	}, nil
+}
+
+// Manager of bitbucket server, there is no issue tracker in bitbucket server
+// so dashboard issue title is not used
+type Manager struct {
+	ctx context.Context
+
+	logger    log.Interface
+	client    *client
+	scheduler types.Scheduler
+
+	disabledRepoNameMatch *regexp.Regexp
+	disabledRepos         map[string]struct{}
+	pushFilter            *filter.PushFilter
+
+	apiURL   string
+	apiToken string
+	gitUser  string
+	gitEmail string
+
+	webhookSecret []byte
+}
+
+func (m *Manager) ListRepos() ([]string, error) {
+	repos, err := m.client.listRepos(m.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list all repos: %w", err)
+	}
+
+	var ret []string
+	for i := range repos {
+		if repos[i].Archived {
+			continue
+		}
+
+		name := repos[i].FullName()
+		if m.disabled(name) {
+			continue
+		}
+
+		ret = append(ret, name)
+	}
+
+	return ret, nil
+}
+
+// disabled checks whether the repo is disabled by name match or project config
+func (m *Manager) disabled(repo string) bool {
+	if m.disabledRepoNameMatch != nil && m.disabledRepoNameMatch.MatchString(repo) {
+		return true
+	}
+
+	_, disabled := m.disabledRepos[repo]
+	return disabled
+}
+
+// CheckHealth checks credentials with bitbucket server api
+func (m *Manager) CheckHealth(ctx context.Context) error {
+	err := m.client.get(ctx, "repos", url.Values{
+		"permission": {"REPO_WRITE"},
+		"limit":      {"1"},
+	}, &repositoryPage{})
+	if err != nil {
+		return fmt.Errorf("failed to authenticate with bitbucket server api: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Manager) APIURL() string {
+	return m.apiURL
+}
+
+func (m *Manager) ExecutionArgs(repos ...string) (types.ExecutionArgs, error) {
+	return types.ExecutionArgs{
+		Platform: "bitbucket-server",
+		APIURL:   m.apiURL,
+		APIToken: m.apiToken,
+		Repos:    repos,
+		GitUser:  m.gitUser,
+		GitEmail: m.gitEmail,
+	}, nil
+}
@@ -0,0 +1,233 @@
+package bitbucketserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+type fakeScheduler struct {
+	repos []string
+}
+
+func (s *fakeScheduler) Schedule(repos ...string) error {
+	s.repos = append(s.repos, repos...)
+	return nil
+}
+
+func newTestManager(t *testing.T, baseURL string, scheduler types.Scheduler) *Manager {
+	mgr, err := NewManager(context.TODO(), &conf.PlatformConfig{
+		API: conf.APIConfig{
+			BaseURL:    baseURL,
+			OAuthToken: "test-token",
+		},
+		Git: conf.GitConfig{
+			User:  "renovate-bot",
+			Email: "bot@bitbucket.example.com",
+		},
+		Webhook: conf.WebhookConfig{
+			Secret: "test-secret",
+		},
+		DisabledRepoNameMatch: "^IGNORED/",
+		Projects: []conf.ProjectConfig{
+			{Name: "FOO/disabled", Disabled: true},
+		},
+	}, scheduler)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return mgr.(*Manager)
+}
+
+func TestManager_ListRepos(t *testing.T) {
+	var allRepos []repository
+	for i := 0; i < listPageSize+10; i++ {
+		allRepos = append(allRepos, repository{Slug: fmt.Sprintf("r%d", i), Project: project{Key: "FOO"}})
+	}
+	allRepos = append(allRepos,
+		repository{Slug: "archived", Project: project{Key: "FOO"}, Archived: true},
+		repository{Slug: "disabled", Project: project{Key: "FOO"}},
+		repository{Slug: "bar", Project: project{Key: "IGNORED"}},
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/1.0/repos" || r.Header.Get("Authorization") != "Bearer test-token" {
+			http.Error(w, "unexpected request", http.StatusForbidden)
+			return
+		}
+
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		end := start + limit
+		if end > len(allRepos) {
+			end = len(allRepos)
+		}
+
+		_ = json.NewEncoder(w).Encode(&repositoryPage{
+			IsLastPage:    end == len(allRepos),
+			NextPageStart: end,
+			Values:        allRepos[start:end],
+		})
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, srv.URL, &fakeScheduler{})
+	assert.Equal(t, srv.URL+"/", m.APIURL())
+
+	repos, err := m.ListRepos()
+	assert.NoError(t, err)
+	assert.Len(t, repos, listPageSize+10)
+	assert.Contains(t, repos, "FOO/r0")
+	assert.NotContains(t, repos, "FOO/archived")
+	assert.NotContains(t, repos, "FOO/disabled")
+	assert.NotContains(t, repos, "IGNORED/bar")
+
+	args, err := m.ExecutionArgs("FOO/r0")
+	assert.NoError(t, err)
+	assert.Equal(t, "bitbucket-server", args.Platform)
+}
+
+func TestManager_ServeHTTP(t *testing.T) {
+	sign := func(payload []byte) string {
+		mac := hmac.New(sha256.New, []byte("test-secret"))
+		_, _ = mac.Write(payload)
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	const pr = `"pullRequest":{"description":"- [x] rebase","toRef":{"repository":{"slug":"bar","project":{"key":"FOO"}}}}`
+
+	tests := []struct {
+		name      string
+		event     string
+		payload   string
+		signature string
+
+		expectedCode int
+		expectedRepo string
+	}{
+		{
+			name:  "Refs Changed",
+			event: "repo:refs_changed",
+			payload: `{"repository":{"slug":"bar","project":{"key":"FOO"}},"actor":{"name":"dev"},` +
+				`"changes":[{"refId":"refs/heads/renovate/foo-1.x","type":"ADD"},{"refId":"refs/heads/master","type":"UPDATE"}]}`,
+			expectedCode: http.StatusOK,
+			expectedRepo: "FOO/bar",
+		},
+		{
+			name:  "Refs Changed Renovate Branch",
+			event: "repo:refs_changed",
+			payload: `{"repository":{"slug":"bar","project":{"key":"FOO"}},"actor":{"name":"dev"},` +
+				`"changes":[{"refId":"refs/heads/renovate/foo-1.x","type":"UPDATE"}]}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:  "Refs Changed By Renovate",
+			event: "repo:refs_changed",
+			payload: `{"repository":{"slug":"bar","project":{"key":"FOO"}},"actor":{"name":"renovate-bot"},` +
+				`"changes":[{"refId":"refs/heads/master","type":"UPDATE"}]}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:  "Default Branch Deleted",
+			event: "repo:refs_changed",
+			payload: `{"repository":{"slug":"bar","project":{"key":"FOO"}},"actor":{"name":"dev"},` +
+				`"changes":[{"refId":"refs/heads/master","type":"DELETE"}]}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Invalid Signature",
+			event:        "repo:refs_changed",
+			payload:      `{"repository":{"slug":"bar","project":{"key":"FOO"}}}`,
+			signature:    sign([]byte("other")),
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Ping",
+			event:        "diagnostics:ping",
+			payload:      `{"test":true}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "PR Checked",
+			event:        "pr:modified",
+			payload:      `{"previousDescription":"- [ ] rebase",` + pr + `}`,
+			expectedCode: http.StatusOK,
+			expectedRepo: "FOO/bar",
+		},
+		{
+			name:         "PR Title Modified",
+			event:        "pr:modified",
+			payload:      `{` + pr + `}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "PR Merged",
+			event:        "pr:merged",
+			payload:      `{` + pr + `}`,
+			expectedCode: http.StatusOK,
+			expectedRepo: "FOO/bar",
+		},
+		{
+			name:         "Disabled Repo",
+			event:        "repo:refs_changed",
+			payload:      `{"repository":{"slug":"disabled","project":{"key":"FOO"}}}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Disabled Repo Name Match",
+			event:        "pr:merged",
+			payload:      `{"pullRequest":{"toRef":{"repository":{"slug":"bar","project":{"key":"IGNORED"}}}}}`,
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/1.0/projects/FOO/repos/bar/branches/default" {
+			http.Error(w, "unexpected request", http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"id":"refs/heads/master","displayId":"master"}`))
+	}))
+	defer srv.Close()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scheduler := &fakeScheduler{}
+			m := newTestManager(t, srv.URL, scheduler)
+
+			sig := test.signature
+			if sig == "" {
+				sig = sign([]byte(test.payload))
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(test.payload)))
+			req.Header.Set(headerEventKey, test.event)
+			req.Header.Set(headerSignature, sig)
+
+			rec := httptest.NewRecorder()
+			m.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.expectedCode, rec.Code)
+			if test.expectedRepo == "" {
+				assert.Empty(t, scheduler.repos)
+			} else {
+				assert.Equal(t, []string{test.expectedRepo}, scheduler.repos)
+			}
+		})
+	}
+}
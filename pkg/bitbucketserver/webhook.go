@@ -0,0 +1,238 @@
+package bitbucketserver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"arhat.dev/pkg/log"
+
+	"arhat.dev/renovate-server/pkg/filter"
+	"arhat.dev/renovate-server/pkg/util"
+)
+
+const (
+	headerEventKey  = "X-Event-Key"
+	headerSignature = "X-Hub-Signature"
+)
+
+type webhookUser struct {
+	Name         string `json:"name"`
+	Slug         string `json:"slug"`
+	EmailAddress string `json:"emailAddress"`
+	DisplayName  string `json:"displayName"`
+}
+
+type refChange struct {
+	RefID string `json:"refId"`
+	Type  string `json:"type"`
+}
+
+type webhookPayload struct {
+	Repository *repository `json:"repository"`
+
+	// push event
+	Actor   *webhookUser `json:"actor"`
+	Changes []refChange  `json:"changes"`
+
+	PreviousDescription *string `json:"previousDescription"`
+	PullRequest         *struct {
+		Description string `json:"description"`
+		ToRef       struct {
+			Repository repository `json:"repository"`
+		} `json:"toRef"`
+	} `json:"pullRequest"`
+}
+
+func (m *Manager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	logger := m.logger.WithFields()
+
+	defer func() {
+		err := recover()
+		if err != nil {
+			logger.E("recovered", log.Any("panic", err))
+		}
+	}()
+
+	logger.D("event received")
+
+	payload, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		logger.I("failed to read payload", log.Error(err))
+		http.Error(w, "failed to read payload", http.StatusBadRequest)
+		return
+	}
+
+	err = m.validateSignature(req.Header.Get(headerSignature), payload)
+	if err != nil {
+		logger.I("signature invalid", log.Error(err))
+		http.Error(w, "invalid hmac signature", http.StatusBadRequest)
+		return
+	}
+
+	eventKey := req.Header.Get(headerEventKey)
+	if eventKey == "diagnostics:ping" {
+		logger.V("received ping event")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	evt := &webhookPayload{}
+	err = json.Unmarshal(payload, evt)
+	if err != nil {
+		logger.I("payload invalid", log.Error(err))
+		http.Error(w, "invalid webhook payload", http.StatusBadRequest)
+		return
+	}
+
+	repo := func() string {
+		switch eventKey {
+		case "pr:modified":
+			if evt.PullRequest == nil {
+				return ""
+			}
+
+			repo := evt.PullRequest.ToRef.Repository.FullName()
+			logger = logger.WithFields(log.String("repo", repo))
+			logger.V("received pull request modified event")
+
+			// no issue tracker in bitbucket server, checkboxes in renovate pull request
+			// descriptions are the only things users can check
+			if evt.PreviousDescription == nil {
+				logger.V("pull request description unchanged")
+				return ""
+			}
+
+			logger.V("pull request description changed")
+			if util.ItemChecked(*evt.PreviousDescription, evt.PullRequest.Description) {
+				return repo
+			}
+			return ""
+		case "pr:merged", "pr:declined", "pr:deleted":
+			if evt.PullRequest == nil {
+				return ""
+			}
+
+			repo := evt.PullRequest.ToRef.Repository.FullName()
+			logger = logger.WithFields(log.String("repo", repo))
+			logger.V("received pull request state changed event", log.String("event", eventKey))
+
+			return repo
+		case "repo:refs_changed":
+			if evt.Repository == nil {
+				return ""
+			}
+
+			repo := evt.Repository.FullName()
+			logger = logger.WithFields(log.String("repo", repo))
+			logger.V("received push event")
+
+			if m.disabled(repo) {
+				// avoid api call for default branch
+				return repo
+			}
+
+			if reason := m.matchPush(evt); reason != "" {
+				logger.D("push ignored", log.String("reason", reason))
+				return ""
+			}
+
+			return repo
+		default:
+			logger.V("ignored event", log.String("event", eventKey))
+			return ""
+		}
+	}()
+	if repo == "" {
+		logger.I("no execution triggered")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if m.disabled(repo) {
+		logger.I("execution ignored")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	logger.I("scheduling renovate execution")
+
+	// run renovate against this repo
+	err = m.scheduler.Schedule(repo)
+	if err != nil {
+		logger.I("failed to schedule renovate execution", log.Error(err))
+		http.Error(w, "failed to execute renovate", http.StatusInternalServerError)
+		return
+	}
+
+	logger.I("scheduled renovate execution")
+	w.WriteHeader(http.StatusOK)
+}
+
+// matchPush returns empty string if any ref change of the push should trigger
+// renovate, otherwise the reason why it's ignored
+func (m *Manager) matchPush(evt *webhookPayload) string {
+	var users []string
+	if u := evt.Actor; u != nil {
+		users = []string{u.Name, u.Slug, u.EmailAddress, u.DisplayName}
+	}
+
+	// not included in payload
+	defaultBranch, err := m.client.defaultBranch(m.ctx, evt.Repository)
+	if err != nil {
+		return fmt.Sprintf("failed to get default branch: %v", err)
+	}
+
+	reason := "no ref updated"
+	for _, c := range evt.Changes {
+		if c.Type == "DELETE" {
+			continue
+		}
+
+		// changed files are not included in payload
+		reason = m.pushFilter.Match(&filter.Push{
+			Ref:           c.RefID,
+			DefaultBranch: defaultBranch,
+			Users:         users,
+		})
+		if reason == "" {
+			return ""
+		}
+	}
+
+	return reason
+}
+
+// validateSignature checks signature in the form of `sha256=<hex encoded hmac>`
+func (m *Manager) validateSignature(sig string, payload []byte) error {
+	if len(m.webhookSecret) == 0 {
+		return nil
+	}
+
+	if sig == "" {
+		return fmt.Errorf("missing signature")
+	}
+
+	const prefix = "sha256="
+	if !strings.HasPrefix(sig, prefix) {
+		return fmt.Errorf("unsupported signature algorithm")
+	}
+
+	actual, err := hex.DecodeString(strings.TrimPrefix(sig, prefix))
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, m.webhookSecret)
+	_, _ = mac.Write(payload)
+	if !hmac.Equal(mac.Sum(nil), actual) {
+		return fmt.Errorf("signature mismatch")
+	}
+
+	return nil
+}
@@ -41,7 +41,7 @@ func NewRenovateServerCmd() *cobra.Command {
 		SilenceErrors: true,
 		SilenceUsage:  true,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			if cmd.Use == "version" {
+			if cmd.Use == "version" || cmd.Use == "validate" {
 				return nil
 			}
 
@@ -54,7 +54,9 @@ func NewRenovateServerCmd() *cobra.Command {
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return run(appCtx, config)
+			return run(appCtx, config, configFile, func() (*conf.Config, error) {
+				return conf.ReloadConfig(cmd, configFile, cliLogConfig)
+			})
 		},
 	}
 
@@ -67,7 +69,12 @@ func NewRenovateServerCmd() *cobra.Command {
 	return renovateServerCmd
 }
 
-func run(appCtx context.Context, config *conf.Config) error {
+func run(
+	appCtx context.Context,
+	config *conf.Config,
+	configFile string,
+	reloadConfig func() (*conf.Config, error),
+) error {
 	logger := log.Log.WithName("server")
 
 	logger.I("creating controller")
@@ -85,15 +92,27 @@ func run(appCtx context.Context, config *conf.Config) error {
 
 	logger.I("controller running")
 
-	logger.I("working on initial all repos check")
-
-	ctrl.CheckAllRepos()
+	if config.Server.ConfigPollInterval > 0 {
+		go conf.WatchConfigFile(appCtx, configFile, config.Server.ConfigPollInterval)
+	}
 
-	logger.I("all repos check finished")
+	for {
+		select {
+		case <-appCtx.Done():
+			return nil
+		case <-ctrl.LeadershipLost():
+			return fmt.Errorf("leadership lost")
+		case <-conf.ReloadRequested(appCtx):
+			logger.I("reloading config")
+
+			newConfig, err := reloadConfig()
+			if err == nil {
+				err = ctrl.Reload(newConfig)
+			}
 
-	// nolint:gosimple
-	select {
-	case <-appCtx.Done():
-		return nil
+			if err != nil {
+				logger.E("failed to reload config, keeping current config", log.Error(err))
+			}
+		}
 	}
 }
@@ -0,0 +1,59 @@
+/*
+Copyright 2020 The arhat.dev Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"arhat.dev/renovate-server/pkg/conf"
+)
+
+// NewValidateCmd creates command checking the config file without starting the server
+func NewValidateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:          "validate",
+		Short:        "validate config file",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configFile, err := cmd.Flags().GetString("config")
+			if err != nil {
+				return err
+			}
+
+			data, err := ioutil.ReadFile(configFile)
+			if err != nil {
+				return fmt.Errorf("failed to read config file %s: %w", configFile, err)
+			}
+
+			problems := conf.ValidateConfig(data)
+			for _, p := range problems {
+				_, _ = fmt.Fprintf(os.Stdout, "%s: %s\n", configFile, p)
+			}
+
+			if len(problems) != 0 {
+				return fmt.Errorf("found %d problem(s) in config file %s", len(problems), configFile)
+			}
+
+			_, _ = fmt.Fprintf(os.Stdout, "%s: ok\n", configFile)
+			return nil
+		},
+	}
+}
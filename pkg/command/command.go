@@ -0,0 +1,99 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Supported commands
+const (
+	// Run renovate against the repo
+	Run = "run"
+
+	// Rebase the renovate pull request
+	Rebase = "rebase"
+
+	// Recreate the closed renovate pull request
+	Recreate = "recreate"
+)
+
+// markers of checkboxes created by renovate
+const (
+	rebaseMarker   = "<!-- rebase-check -->"
+	recreateMarker = "<!-- recreate-branch=%s -->"
+)
+
+// Supported returns true if cmd is a known command
+func Supported(cmd string) bool {
+	switch cmd {
+	case Run, Rebase, Recreate:
+		return true
+	default:
+		return false
+	}
+}
+
+// Parse finds the first line starting with prefix in the comment, returns the
+// command following the prefix
+func Parse(prefix, comment string) (cmd string, ok bool) {
+	for _, line := range strings.Split(comment, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != prefix {
+			continue
+		}
+
+		if len(fields) == 1 {
+			return Run, true
+		}
+
+		return strings.ToLower(fields[1]), true
+	}
+
+	return "", false
+}
+
+// Allowed returns true if role is one of the allowed roles
+func Allowed(role string, allowed []string) bool {
+	for _, r := range allowed {
+		if strings.EqualFold(r, role) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckRebase checks the rebase checkbox in the body of renovate pull request,
+// returns false if there is no such checkbox
+func CheckRebase(body string) (string, bool) {
+	return checkItem(body, rebaseMarker)
+}
+
+// CheckRecreate checks the checkbox to recreate closed pull request of the branch
+// in the body of renovate dashboard issue, returns false if there is no such checkbox
+func CheckRecreate(body, branch string) (string, bool) {
+	return checkItem(body, fmt.Sprintf(recreateMarker, branch))
+}
+
+func checkItem(body, marker string) (string, bool) {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, marker) {
+			continue
+		}
+
+		if strings.Contains(line, "[x]") {
+			// already checked
+			return body, true
+		}
+
+		if !strings.Contains(line, "[ ]") {
+			continue
+		}
+
+		lines[i] = strings.Replace(line, "[ ]", "[x]", 1)
+		return strings.Join(lines, "\n"), true
+	}
+
+	return body, false
+}
@@ -0,0 +1,56 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment string
+		cmd     string
+		ok      bool
+	}{
+		{name: "None", comment: "lgtm"},
+		{name: "Prefix Only", comment: "/renovate", cmd: Run, ok: true},
+		{name: "Run", comment: "thanks\n/renovate run please", cmd: Run, ok: true},
+		{name: "Case", comment: "  /renovate Rebase", cmd: Rebase, ok: true},
+		{name: "Unknown", comment: "/renovate merge", cmd: "merge", ok: true},
+		{name: "Not At Line Start", comment: "try `/renovate run`"},
+		{name: "Longer Prefix", comment: "/renovatebot run"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd, ok := Parse("/renovate", test.comment)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.cmd, cmd)
+		})
+	}
+}
+
+func TestCheckRebase(t *testing.T) {
+	body, ok := CheckRebase("foo\n - [ ] <!-- rebase-check -->If you want to rebase, check this box\nbar [ ]")
+	assert.True(t, ok)
+	assert.Equal(t, "foo\n - [x] <!-- rebase-check -->If you want to rebase, check this box\nbar [ ]", body)
+
+	_, ok = CheckRebase("- [ ] some other pr")
+	assert.False(t, ok)
+}
+
+func TestCheckRecreate(t *testing.T) {
+	const dashboard = "## Closed\n" +
+		" - [ ] <!-- recreate-branch=renovate/foo -->foo\n" +
+		" - [ ] <!-- recreate-branch=renovate/foo-bar -->foo-bar\n"
+
+	body, ok := CheckRecreate(dashboard, "renovate/foo-bar")
+	assert.True(t, ok)
+	assert.Equal(t, "## Closed\n"+
+		" - [ ] <!-- recreate-branch=renovate/foo -->foo\n"+
+		" - [x] <!-- recreate-branch=renovate/foo-bar -->foo-bar\n", body)
+
+	_, ok = CheckRecreate(dashboard, "renovate/bar")
+	assert.False(t, ok)
+}
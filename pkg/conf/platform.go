@@ -84,12 +84,31 @@ type APIConfig struct {
 	BaseURL    string `json:"baseURL" yaml:"baseURL"`
 	OAuthToken string `json:"oauthToken" yaml:"oauthToken"`
 
+	// App authenticates as github app installation instead of oauth token (github only)
+	App *GitHubAppConfig `json:"app" yaml:"app"`
+
 	Client HTTPClientConfig `json:"client" yaml:"client"`
 }
 
+type GitHubAppConfig struct {
+	AppID int64 `json:"appID" yaml:"appID"`
+
+	// PrivateKeyFile is the path to the pem encoded private key of the app
+	PrivateKeyFile string `json:"privateKeyFile" yaml:"privateKeyFile"`
+
+	// InstallationID of the app, can be omitted if the app has only one installation
+	InstallationID int64 `json:"installationID" yaml:"installationID"`
+}
+
 type WebhookConfig struct {
 	Path   string `json:"path" yaml:"path"`
 	Secret string `json:"secret" yaml:"secret"`
+
+	// Secrets are additional accepted secrets, useful when rotating secret (gitlab only)
+	Secrets []string `json:"secrets" yaml:"secrets"`
+
+	// Username for basic auth protected webhooks, secret is used as the password (azure only)
+	Username string `json:"username" yaml:"username"`
 }
 
 type GitConfig struct {
@@ -105,9 +124,103 @@ type PlatformConfig struct {
 	DashboardIssueTitle   string `json:"dashboardIssueTitle" yaml:"dashboardIssueTitle"`
 	DisabledRepoNameMatch string `json:"disabledRepoNameMatch" yaml:"disabledRepoNameMatch"`
 
+	Commands CommandsConfig `json:"commands" yaml:"commands"`
+
+	// Filter selects repos to run renovate against (github and gitlab)
+	Filter RepoFilterConfig `json:"filter" yaml:"filter"`
+
+	// Push filters push events triggering renovate (all platforms)
+	Push PushFilterConfig `json:"push" yaml:"push"`
+
+	// ConfigValidation of renovate config files changed by push events (github and gitlab)
+	ConfigValidation ConfigValidationConfig `json:"configValidation" yaml:"configValidation"`
+
+	// MaxConcurrentExecutions limits running executions of this platform, 0 means no limit
+	MaxConcurrentExecutions int `json:"maxConcurrentExecutions" yaml:"maxConcurrentExecutions"`
+
 	Projects []ProjectConfig `json:"projects" yaml:"projects"`
 }
 
+// CommandsConfig for slash commands in issue and pull request comments
+type CommandsConfig struct {
+	// Prefix of commands, defaults to `/renovate`
+	Prefix string `json:"prefix" yaml:"prefix"`
+
+	// AllowedRoles of users can use commands, github: admin, write, read;
+	// gitlab: guest, reporter, developer, maintainer, owner
+	//
+	// defaults to admin, write (github) or developer, maintainer, owner (gitlab)
+	AllowedRoles []string `json:"allowedRoles" yaml:"allowedRoles"`
+}
+
+// RepoFilterConfig includes repos matching all conditions, applied to both repo listing
+// and webhook events
+type RepoFilterConfig struct {
+	// IncludeNameMatch only includes repos with full name matching this regex
+	IncludeNameMatch string `json:"includeNameMatch" yaml:"includeNameMatch"`
+
+	// Owners only includes repos owned by these users/orgs (github) or groups (gitlab,
+	// including subgroups)
+	Owners []string `json:"owners" yaml:"owners"`
+
+	// Topics only includes repos with all these topics (github topics, gitlab tags)
+	Topics []string `json:"topics" yaml:"topics"`
+
+	// Visibility only includes repos with one of these visibilities (public, private, internal)
+	Visibility []string `json:"visibility" yaml:"visibility"`
+
+	SkipForks bool `json:"skipForks" yaml:"skipForks"`
+
+	// IncludeArchived includes archived repos, they are skipped by default
+	IncludeArchived bool `json:"includeArchived" yaml:"includeArchived"`
+
+	// SkipEmpty skips repos without any commit (github: repos with size 0)
+	SkipEmpty bool `json:"skipEmpty" yaml:"skipEmpty"`
+
+	// MinAccessLevel only includes repos the user has at least this access level
+	// (gitlab only): guest, reporter, developer, maintainer, owner
+	MinAccessLevel string `json:"minAccessLevel" yaml:"minAccessLevel"`
+}
+
+// PushFilterConfig selects push events triggering renovate, pushes by the
+// configured git user or email are always ignored
+type PushFilterConfig struct {
+	// Branches only triggers on pushes to branches matching these globs, defaults
+	// to the default branch of the repo
+	Branches []string `json:"branches" yaml:"branches"`
+
+	// IgnoreBranches ignores pushes to branches matching these globs
+	IgnoreBranches []string `json:"ignoreBranches" yaml:"ignoreBranches"`
+
+	// IgnoreUsers ignores pushes by these users (login or email), e.g. bots
+	IgnoreUsers []string `json:"ignoreUsers" yaml:"ignoreUsers"`
+
+	// IncludeTags triggers on tag pushes, they are ignored by default
+	IncludeTags bool `json:"includeTags" yaml:"includeTags"`
+
+	// ManifestChangesOnly only triggers when renovate config or package manifests
+	// are changed by the pushed commits, not supported by bitbucket server and azure
+	ManifestChangesOnly bool `json:"manifestChangesOnly" yaml:"manifestChangesOnly"`
+
+	// ManifestFiles overrides globs of renovate config and package manifests, globs
+	// without `/` match file names in any directory, others match file paths from the
+	// repo root
+	ManifestFiles []string `json:"manifestFiles" yaml:"manifestFiles"`
+}
+
+// ConfigValidationConfig of renovate config files changed by push events, files are
+// fetched at the pushed commit and the result is reported as commit status, only push
+// events triggering renovate (see Push) are validated
+type ConfigValidationConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+
+	// StatusContext is the name of commit status, defaults to `renovate-server/config`
+	StatusContext string `json:"statusContext" yaml:"statusContext"`
+
+	// CommentOnFailure comments problems found on the commit
+	CommentOnFailure bool `json:"commentOnFailure" yaml:"commentOnFailure"`
+}
+
 type ProjectConfig struct {
 	// Name of the project (repo name)
 	Name string `json:"name" yaml:"name"`
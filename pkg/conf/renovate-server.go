@@ -32,16 +32,34 @@ type Config struct {
 
 	GitHub []PlatformConfig `json:"github" yaml:"github"`
 	GitLab []PlatformConfig `json:"gitlab" yaml:"gitlab"`
+	Gitea  []PlatformConfig `json:"gitea" yaml:"gitea"`
+
+	BitbucketServer []PlatformConfig `json:"bitbucketServer" yaml:"bitbucketServer"`
+	Azure           []PlatformConfig `json:"azure" yaml:"azure"`
 }
 
 type ServerConfig struct {
 	Log log.ConfigSet `json:"log" yaml:"log"`
 
+	// ConfigPollInterval is the interval to check changes of the config file for reload,
+	// 0 disables polling, config is also reloaded on SIGHUP
+	ConfigPollInterval time.Duration `json:"configPollInterval" yaml:"configPollInterval"`
+
 	Webhook struct {
 		Listen string              `json:"listen" yaml:"listen"`
 		TLS    tlshelper.TLSConfig `json:"tls" yaml:"tls"`
 	} `json:"webhook" yaml:"webhook"`
 
+	// Admin API for queue inspection and manual triggers, disabled if not set
+	Admin *AdminConfig `json:"admin" yaml:"admin"`
+
+	// Monitoring serves prometheus metrics and health probes with plain http,
+	// should not be exposed to the public like webhooks
+	Monitoring struct {
+		// Listen address of monitoring server, disabled if empty
+		Listen string `json:"listen" yaml:"listen"`
+	} `json:"monitoring" yaml:"monitoring"`
+
 	Scheduling struct {
 		// Delay period for webhook event
 		Delay time.Duration `json:"delay" yaml:"delay"`
@@ -50,33 +68,230 @@ type ServerConfig struct {
 		CronTabs []string `json:"cronTabs" yaml:"cronTabs"`
 		// Timezone
 		Timezone string `json:"timezone" yaml:"timezone"`
+
+		// MaxConcurrentExecutions limits running executions of all platforms, 0 means no limit
+		MaxConcurrentExecutions int `json:"maxConcurrentExecutions" yaml:"maxConcurrentExecutions"`
+
+		// Mode of scheduling, one of [token, repo], defaults to token
+		//
+		// token: pending executions of the same platform are merged into one execution
+		// repo: each repo is scheduled independently
+		Mode string `json:"mode" yaml:"mode"`
+
+		// BatchWindow is the time period to group ready repos into one execution in repo mode,
+		// 0 means no batching
+		BatchWindow time.Duration `json:"batchWindow" yaml:"batchWindow"`
+
+		// MaxBatchSize limits repos grouped into one execution in repo mode, 0 means no limit
+		MaxBatchSize int `json:"maxBatchSize" yaml:"maxBatchSize"`
+
+		// Retry policy for failed executions
+		Retry RetryConfig `json:"retry" yaml:"retry"`
 	} `json:"scheduling" yaml:"scheduling"`
 
+	// Store persists pending executions across restarts, kept in memory only if not set
+	Store StoreConfig `json:"store" yaml:"store"`
+
+	// LeaderElection among replicas, only the leader runs executions and cron jobs,
+	// requires a queue store to share pending executions
+	LeaderElection *LeaderElectionConfig `json:"leaderElection" yaml:"leaderElection"`
+
 	Executor struct {
 		Kubernetes *KubernetesExecutorConfig `json:"kubernetes" yaml:"kubernetes"`
+		Local      *LocalExecutorConfig      `json:"local" yaml:"local"`
+		Docker     *DockerExecutorConfig     `json:"docker" yaml:"docker"`
 	} `json:"executor" yaml:"executor"`
 }
 
+type AdminConfig struct {
+	// Listen address of admin api, defaults to :8081
+	Listen string              `json:"listen" yaml:"listen"`
+	TLS    tlshelper.TLSConfig `json:"tls" yaml:"tls"`
+
+	// Token required in `Authorization: Bearer <token>` header of admin api requests
+	Token string `json:"token" yaml:"token"`
+}
+
+type RetryConfig struct {
+	// InitialBackoff is the delay before the first retry
+	InitialBackoff time.Duration `json:"initialBackoff" yaml:"initialBackoff"`
+
+	// Multiplier of backoff for each subsequent retry
+	Multiplier float64 `json:"multiplier" yaml:"multiplier"`
+
+	// MaxBackoff caps the delay between retries
+	MaxBackoff time.Duration `json:"maxBackoff" yaml:"maxBackoff"`
+
+	// MaxAttempts of executions for a repo before it's moved to dead letters, 0 means no limit
+	MaxAttempts int `json:"maxAttempts" yaml:"maxAttempts"`
+}
+
+type StoreConfig struct {
+	File      *FileStoreConfig      `json:"file" yaml:"file"`
+	ConfigMap *ConfigMapStoreConfig `json:"configMap" yaml:"configMap"`
+}
+
+type FileStoreConfig struct {
+	// Dir to store pending executions, one file for each
+	Dir string `json:"dir" yaml:"dir"`
+}
+
+type ConfigMapStoreConfig struct {
+	KubeClient kubehelper.KubeClientConfig `json:"kubeClient" yaml:"kubeClient"`
+
+	// Name of the configmap in the namespace of this pod
+	Name string `json:"name" yaml:"name"`
+}
+
+type LeaderElectionConfig struct {
+	Kubernetes *KubernetesLeaderElectionConfig `json:"kubernetes" yaml:"kubernetes"`
+	File       *FileLeaderElectionConfig       `json:"file" yaml:"file"`
+}
+
+type KubernetesLeaderElectionConfig struct {
+	KubeClient kubehelper.KubeClientConfig `json:"kubeClient" yaml:"kubeClient"`
+
+	// Identity of this replica, defaults to pod name
+	Identity string                               `json:"identity" yaml:"identity"`
+	Lock     kubehelper.LeaderElectionLockConfig  `json:"lock" yaml:"lock"`
+	Lease    kubehelper.LeaderElectionLeaseConfig `json:"lease" yaml:"lease"`
+}
+
+type FileLeaderElectionConfig struct {
+	// Path of the lock file, all replicas MUST have access to the same file
+	// (e.g. running on the same host)
+	Path string `json:"path" yaml:"path"`
+
+	// RetryInterval of acquiring the lock
+	RetryInterval time.Duration `json:"retryInterval" yaml:"retryInterval"`
+}
+
 type KubernetesExecutorConfig struct {
 	KubeClient kubehelper.KubeClientConfig `json:"kubeClient" yaml:"kubeClient"`
 
 	// JobTTL delete after specified time period
 	JobTTL time.Duration `json:"jobTTL" yaml:"jobTTL"`
 
+	// JobActiveDeadline fails the job if it's still running after specified time period, 0 means no deadline
+	JobActiveDeadline time.Duration `json:"jobActiveDeadline" yaml:"jobActiveDeadline"`
+
 	RenovateImage           string `json:"renovateImage" yaml:"renovateImage"`
 	RenovateImagePullPolicy string `json:"renovateImagePullPolicy" yaml:"renovateImagePullPolicy"`
+
+	// ConfigValidatorCommand validates renovate config files changed by push events in
+	// the renovate image, path of the config file is appended as the last arg, defaults
+	// to [renovate-config-validator]
+	ConfigValidatorCommand []string `json:"configValidatorCommand" yaml:"configValidatorCommand"`
+
+	// PodTemplate is a partial pod template spec, strategically merged over the generated
+	// pod template of renovate jobs, the renovate container is named `renovate`
+	PodTemplate map[string]interface{} `json:"podTemplate" yaml:"podTemplate"`
+
+	// Cache persists renovate base dir across jobs
+	Cache *KubernetesCacheConfig `json:"cache" yaml:"cache"`
+}
+
+type KubernetesCacheConfig struct {
+	// MountPath of the cache volume, used as renovate base dir, defaults to /tmp/renovate
+	MountPath string `json:"mountPath" yaml:"mountPath"`
+
+	// ClaimName of an existing pvc shared by all jobs, each repo uses its own sub path
+	//
+	// when not set, a pvc is created for each repo on demand
+	ClaimName string `json:"claimName" yaml:"claimName"`
+
+	// StorageClassName of pvc created on demand, use cluster default when not set
+	StorageClassName string `json:"storageClassName" yaml:"storageClassName"`
+
+	// Size of pvc created on demand (e.g. 10Gi)
+	Size string `json:"size" yaml:"size"`
+
+	// AccessModes of pvc created on demand, defaults to [ReadWriteOnce]
+	AccessModes []string `json:"accessModes" yaml:"accessModes"`
+
+	// FSGroup owning the cache volume, should be a group of the user running renovate,
+	// defaults to gid of the user in the renovate image (1000)
+	FSGroup *int64 `json:"fsGroup" yaml:"fsGroup"`
+}
+
+type LocalExecutorConfig struct {
+	// Command to run renovate, defaults to `renovate` (looked up in $PATH)
+	Command []string `json:"command" yaml:"command"`
+
+	// WorkDir is the parent dir of per run working directories, defaults to system temporary dir
+	WorkDir string `json:"workDir" yaml:"workDir"`
+
+	// KeepWorkDir do not remove working directory after execution
+	KeepWorkDir bool `json:"keepWorkDir" yaml:"keepWorkDir"`
+
+	// Timeout of a single renovate execution, 0 means no timeout
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+
+	// MaxConcurrency limits how many renovate processes can run at the same time, 0 means no limit
+	MaxConcurrency int `json:"maxConcurrency" yaml:"maxConcurrency"`
+
+	// Env is the extra environment variables passed to renovate and config validator,
+	// only PATH, HOME, TMPDIR and proxy variables are inherited from renovate-server
+	Env map[string]string `json:"env" yaml:"env"`
+
+	// ConfigValidatorCommand validates renovate config files changed by push events
+	// (e.g. [renovate-config-validator]), path of the config file is appended as the
+	// last arg, only syntax is checked when not set
+	ConfigValidatorCommand []string `json:"configValidatorCommand" yaml:"configValidatorCommand"`
+}
+
+type DockerExecutorConfig struct {
+	// Host is the docker engine endpoint, only unix socket is supported
+	// defaults to unix:///var/run/docker.sock
+	Host string `json:"host" yaml:"host"`
+
+	// APIVersion of docker engine api (e.g. v1.41), use unversioned api when not set
+	APIVersion string `json:"apiVersion" yaml:"apiVersion"`
+
+	// ContainerTTL delete finished containers after specified time period
+	ContainerTTL time.Duration `json:"containerTTL" yaml:"containerTTL"`
+
+	// Timeout of a single renovate execution, the container is killed after timeout,
+	// 0 means no timeout
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+
+	RenovateImage           string `json:"renovateImage" yaml:"renovateImage"`
+	RenovateImagePullPolicy string `json:"renovateImagePullPolicy" yaml:"renovateImagePullPolicy"`
+
+	// ConfigValidatorCommand validates renovate config files changed by push events in
+	// the renovate image, path of the config file is appended as the last arg, defaults
+	// to [renovate-config-validator]
+	ConfigValidatorCommand []string `json:"configValidatorCommand" yaml:"configValidatorCommand"`
 }
 
 func FlagsForServer(prefix string, config *ServerConfig) *pflag.FlagSet {
 	fs := pflag.NewFlagSet("app", pflag.ExitOnError)
 
+	fs.DurationVar(&config.ConfigPollInterval, prefix+"configPollInterval",
+		constant.DefaultConfigPollInterval, "set interval to check config file changes for reload, 0 to disable",
+	)
 	fs.StringVar(&config.Webhook.Listen, prefix+"webhook.listen",
 		constant.DefaultWebhookListenAddress, "set webhook listener address",
 	)
 	fs.AddFlagSet(tlshelper.FlagsForTLSConfig(prefix+"webhook.tls", &config.Webhook.TLS))
+	fs.StringVar(&config.Monitoring.Listen, prefix+"monitoring.listen",
+		constant.DefaultMonitoringListenAddress, "set monitoring listener address, empty to disable",
+	)
 	fs.DurationVar(&config.Scheduling.Delay, prefix+"scheduling.delay",
 		constant.DefaultSchedulingDelay, "set delay time before actually invoke executor",
 	)
+	fs.DurationVar(&config.Scheduling.Retry.InitialBackoff, prefix+"scheduling.retry.initialBackoff",
+		constant.DefaultRetryInitialBackoff, "set delay time before the first retry of failed execution",
+	)
+	fs.Float64Var(&config.Scheduling.Retry.Multiplier, prefix+"scheduling.retry.multiplier",
+		constant.DefaultRetryMultiplier, "set backoff multiplier for each subsequent retry",
+	)
+	fs.DurationVar(&config.Scheduling.Retry.MaxBackoff, prefix+"scheduling.retry.maxBackoff",
+		constant.DefaultRetryMaxBackoff, "set max delay time between retries",
+	)
+	fs.IntVar(&config.Scheduling.Retry.MaxAttempts, prefix+"scheduling.retry.maxAttempts",
+		constant.DefaultRetryMaxAttempts, "set max execution attempts for a repo, 0 means no limit",
+	)
 
 	return fs
 }
@@ -18,16 +18,19 @@ package conf
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"arhat.dev/pkg/envhelper"
 	"arhat.dev/pkg/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"gopkg.in/yaml.v3"
 
 	"arhat.dev/renovate-server/pkg/constant"
@@ -46,40 +49,12 @@ func ReadConfig(
 	}
 
 	if len(configBytes) > 0 {
-		configStr := envhelper.Expand(string(configBytes), func(s, origin string) string {
-			// nolint:gocritic
-			switch s {
-			// TODO: add special cases if any
-			default:
-				v, found := os.LookupEnv(s)
-				if found {
-					return v
-				}
-				return origin
-			}
-		})
-
-		dec := yaml.NewDecoder(strings.NewReader(configStr))
-		if err = dec.Decode(config); err != nil {
+		if err = decodeConfig(configBytes, config); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal config file %s: %v", *configFile, err)
 		}
 	}
 
-	if len(config.Server.Log) > 0 {
-		if flags.Changed("log.format") {
-			config.Server.Log[0].Format = cliLogConfig.Format
-		}
-
-		if flags.Changed("log.level") {
-			config.Server.Log[0].Level = cliLogConfig.Level
-		}
-
-		if flags.Changed("log.file") {
-			config.Server.Log[0].File = cliLogConfig.File
-		}
-	} else {
-		config.Server.Log = append(config.Server.Log, *cliLogConfig)
-	}
+	applyLogConfig(flags, cliLogConfig, config)
 
 	if err = cmd.ParseFlags(os.Args); err != nil {
 		return nil, err
@@ -92,8 +67,11 @@ func ReadConfig(
 
 	appCtx, exit := context.WithCancel(context.WithValue(context.Background(), constant.ContextKeyConfig, config))
 
+	reloadCh := make(chan struct{}, 1)
+	appCtx = context.WithValue(appCtx, constant.ContextKeyReload, reloadCh)
+
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 	go func() {
 		exitCount := 0
 		for sig := range sigCh {
@@ -105,11 +83,142 @@ func ReadConfig(
 				} else {
 					os.Exit(1)
 				}
-				//case syscall.SIGHUP:
-				//	// force reload
+			case syscall.SIGHUP:
+				// force reload
+				NotifyReload(appCtx)
 			}
 		}
 	}()
 
 	return appCtx, nil
 }
+
+// ReloadConfig reads the config file again, defaults and command line flags are
+// applied the same way as ReadConfig
+func ReloadConfig(cmd *cobra.Command, configFile string, cliLogConfig *log.Config) (*Config, error) {
+	config := new(Config)
+	// set defaults
+	fs := FlagsForServer("", &config.Server)
+
+	configBytes, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", configFile, err)
+	}
+
+	if err = decodeConfig(configBytes, config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config file %s: %w", configFile, err)
+	}
+
+	flags := cmd.Flags()
+	applyLogConfig(flags, cliLogConfig, config)
+
+	// command line flags take precedence over the config file
+	flags.Visit(func(f *pflag.Flag) {
+		if fs.Lookup(f.Name) == nil {
+			return
+		}
+
+		err2 := fs.Set(f.Name, f.Value.String())
+		if err2 != nil && err == nil {
+			err = fmt.Errorf("failed to apply flag %s: %w", f.Name, err2)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// NotifyReload requests config reload if the context is created by ReadConfig
+func NotifyReload(ctx context.Context) {
+	reloadCh, ok := ctx.Value(constant.ContextKeyReload).(chan struct{})
+	if !ok {
+		return
+	}
+
+	select {
+	case reloadCh <- struct{}{}:
+	default:
+	}
+}
+
+// ReloadRequested returns the channel notified when config reload is requested,
+// nil if the context is not created by ReadConfig
+func ReloadRequested(ctx context.Context) <-chan struct{} {
+	reloadCh, _ := ctx.Value(constant.ContextKeyReload).(chan struct{})
+	return reloadCh
+}
+
+// WatchConfigFile requests config reload when content of the config file changed,
+// checked every interval until ctx is canceled
+func WatchConfigFile(ctx context.Context, configFile string, interval time.Duration) {
+	digest := func() [sha256.Size]byte {
+		data, err := ioutil.ReadFile(configFile)
+		if err != nil {
+			// keep the last known digest if the file is being replaced
+			return [sha256.Size]byte{}
+		}
+
+		return sha256.Sum256(data)
+	}
+
+	last := digest()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := digest()
+			if current == ([sha256.Size]byte{}) || current == last {
+				continue
+			}
+
+			last = current
+			NotifyReload(ctx)
+		}
+	}
+}
+
+func decodeConfig(data []byte, config *Config) error {
+	return yaml.NewDecoder(strings.NewReader(expandEnv(data))).Decode(config)
+}
+
+// expandEnv replaces environment variable references in the config file
+func expandEnv(data []byte) string {
+	return envhelper.Expand(string(data), func(s, origin string) string {
+		// nolint:gocritic
+		switch s {
+		// TODO: add special cases if any
+		default:
+			v, found := os.LookupEnv(s)
+			if found {
+				return v
+			}
+			return origin
+		}
+	})
+}
+
+// applyLogConfig overrides the first log config with command line flags
+func applyLogConfig(flags *pflag.FlagSet, cliLogConfig *log.Config, config *Config) {
+	if len(config.Server.Log) > 0 {
+		if flags.Changed("log.format") {
+			config.Server.Log[0].Format = cliLogConfig.Format
+		}
+
+		if flags.Changed("log.level") {
+			config.Server.Log[0].Level = cliLogConfig.Level
+		}
+
+		if flags.Changed("log.file") {
+			config.Server.Log[0].File = cliLogConfig.File
+		}
+	} else {
+		config.Server.Log = append(config.Server.Log, *cliLogConfig)
+	}
+}
@@ -0,0 +1,283 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+
+	"arhat.dev/renovate-server/pkg/constant"
+)
+
+// CronTabParser parses cron tabs in scheduling config
+var CronTabParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// Problem found in config file
+type Problem struct {
+	// Line in the config file, 0 if unknown
+	Line int
+
+	// Path of the config field, e.g. `github[0].webhook.path`
+	Path string
+
+	Message string
+}
+
+func (p Problem) String() string {
+	var prefix string
+	if p.Line > 0 {
+		prefix = fmt.Sprintf("line %d: ", p.Line)
+	}
+
+	if p.Path != "" {
+		prefix += p.Path + ": "
+	}
+
+	return prefix + p.Message
+}
+
+var yamlErrorLineRegex = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+func newYAMLProblem(msg string) Problem {
+	m := yamlErrorLineRegex.FindStringSubmatch(msg)
+	if m == nil {
+		return Problem{Message: msg}
+	}
+
+	line, _ := strconv.Atoi(m[1])
+	return Problem{Line: line, Message: m[2]}
+}
+
+// ValidateConfig decodes config file in strict mode (unknown fields are errors) and
+// checks values otherwise only validated when used, all problems found are returned
+// sorted by line
+func ValidateConfig(data []byte) []Problem {
+	configStr := expandEnv(data)
+
+	doc := new(yaml.Node)
+	err := yaml.Unmarshal([]byte(configStr), doc)
+	if err != nil {
+		return []Problem{newYAMLProblem(err.Error())}
+	}
+
+	config := new(Config)
+	// set defaults
+	_ = FlagsForServer("", &config.Server)
+
+	v := &validator{doc: doc}
+
+	dec := yaml.NewDecoder(strings.NewReader(configStr))
+	dec.KnownFields(true)
+	err = dec.Decode(config)
+
+	var typeErr *yaml.TypeError
+	switch {
+	case err == nil, errors.Is(err, io.EOF):
+	case errors.As(err, &typeErr):
+		// fields decoded successfully are still checked
+		for _, msg := range typeErr.Errors {
+			v.problems = append(v.problems, newYAMLProblem(msg))
+		}
+	default:
+		return []Problem{newYAMLProblem(err.Error())}
+	}
+
+	v.checkServer(&config.Server)
+	v.checkPlatforms(config)
+
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		return v.problems[i].Line < v.problems[j].Line
+	})
+
+	return v.problems
+}
+
+type validator struct {
+	doc      *yaml.Node
+	problems []Problem
+}
+
+func (v *validator) add(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{
+		Line:    v.lineOf(path),
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// lineOf returns line of the config field, or line of its closest parent present
+// in the config file
+func (v *validator) lineOf(path string) int {
+	node := v.doc
+	line := 0
+	next := func(n *yaml.Node) {
+		for n.Kind == yaml.AliasNode {
+			n = n.Alias
+		}
+
+		node = n
+	}
+
+	if node.Kind == yaml.DocumentNode && len(node.Content) != 0 {
+		next(node.Content[0])
+	}
+
+	for _, part := range strings.Split(path, ".") {
+		key, index := part, -1
+		if i := strings.IndexByte(part, '['); i != -1 {
+			key = part[:i]
+			index, _ = strconv.Atoi(strings.TrimSuffix(part[i+1:], "]"))
+		}
+
+		if node.Kind != yaml.MappingNode {
+			return line
+		}
+
+		found := false
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				line = node.Content[i].Line
+				next(node.Content[i+1])
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return line
+		}
+
+		if index < 0 {
+			continue
+		}
+
+		if node.Kind != yaml.SequenceNode || index >= len(node.Content) {
+			return line
+		}
+
+		line = node.Content[index].Line
+		next(node.Content[index])
+	}
+
+	return line
+}
+
+func (v *validator) checkServer(config *ServerConfig) {
+	switch config.Scheduling.Mode {
+	case "", constant.SchedulingModeToken, constant.SchedulingModeRepo:
+	default:
+		v.add("server.scheduling.mode", "unsupported scheduling mode %q", config.Scheduling.Mode)
+	}
+
+	for i, tab := range config.Scheduling.CronTabs {
+		_, err := CronTabParser.Parse(tab)
+		if err != nil {
+			v.add(fmt.Sprintf("server.scheduling.cronTabs[%d]", i), "invalid cron tab %q: %v", tab, err)
+		}
+	}
+
+	if tz := config.Scheduling.Timezone; tz != "" {
+		_, err := time.LoadLocation(tz)
+		if err != nil {
+			v.add("server.scheduling.timezone", "invalid timezone %q: %v", tz, err)
+		}
+	}
+
+	if config.LeaderElection != nil && config.Store.File == nil && config.Store.ConfigMap == nil {
+		v.add("server.leaderElection", "queue store is required for leader election")
+	}
+
+	executor := &config.Executor
+	if executor.Kubernetes == nil && executor.Local == nil && executor.Docker == nil {
+		v.add("server.executor", "no executor provided")
+	}
+
+	if executor.Kubernetes != nil {
+		v.checkPullPolicy("server.executor.kubernetes", executor.Kubernetes.RenovateImagePullPolicy)
+	}
+
+	if executor.Docker != nil {
+		v.checkPullPolicy("server.executor.docker", executor.Docker.RenovateImagePullPolicy)
+	}
+}
+
+func (v *validator) checkPullPolicy(path, policy string) {
+	switch strings.ToLower(policy) {
+	case "", "always", "never", "ifnotpresent", "if_not_present":
+	default:
+		v.add(path+".renovateImagePullPolicy", "unsupported image pull policy %q", policy)
+	}
+}
+
+func (v *validator) checkPlatforms(config *Config) {
+	// webhook path to the platform config using it
+	paths := make(map[string]string)
+
+	for _, p := range []struct {
+		key     string
+		configs []PlatformConfig
+	}{
+		{key: "github", configs: config.GitHub},
+		{key: "gitlab", configs: config.GitLab},
+		{key: "gitea", configs: config.Gitea},
+		{key: "bitbucketServer", configs: config.BitbucketServer},
+		{key: "azure", configs: config.Azure},
+	} {
+		for i := range p.configs {
+			pc := &p.configs[i]
+			prefix := fmt.Sprintf("%s[%d]", p.key, i)
+
+			path := pc.Webhook.Path
+			switch used, exists := paths[path]; {
+			case path == "":
+				v.add(prefix+".webhook.path", "no webhook path provided")
+			case exists:
+				v.add(prefix+".webhook.path", "duplicate webhook path %q, already used by %s", path, used)
+			default:
+				paths[path] = prefix
+			}
+
+			switch {
+			case pc.API.OAuthToken != "":
+			case p.key == "github" && pc.API.App != nil:
+			case p.key == "github":
+				v.add(prefix+".api.oauthToken", "no oauth token or github app provided")
+			default:
+				v.add(prefix+".api.oauthToken", "no oauth token provided")
+			}
+
+			if app := pc.API.App; p.key == "github" && app != nil {
+				if app.AppID == 0 {
+					v.add(prefix+".api.app.appID", "no github app id provided")
+				}
+
+				if app.PrivateKeyFile == "" {
+					v.add(prefix+".api.app.privateKeyFile", "no github app private key file provided")
+				}
+			}
+
+			v.checkRegex(prefix+".disabledRepoNameMatch", pc.DisabledRepoNameMatch)
+			v.checkRegex(prefix+".filter.includeNameMatch", pc.Filter.IncludeNameMatch)
+		}
+	}
+}
+
+func (v *validator) checkRegex(path, expr string) {
+	if expr == "" {
+		return
+	}
+
+	_, err := regexp.Compile(expr)
+	if err != nil {
+		v.add(path, "invalid regex: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package conf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		problems []string
+	}{
+		{
+			name: "Valid",
+			config: `
+server:
+  scheduling:
+    cronTabs: ["@every 1h", "0 */2 * * *"]
+    timezone: Asia/Shanghai
+  executor:
+    local: {}
+github:
+- api:
+    app:
+      appID: 1
+      privateKeyFile: /etc/github/app.pem
+  webhook:
+    path: /github
+gitlab:
+- api:
+    oauthToken: foo
+  webhook:
+    path: /gitlab
+`,
+		},
+		{
+			name:     "Syntax",
+			config:   "server:\n  log: [\n",
+			problems: []string{"line 2: did not find expected node content"},
+		},
+		{
+			name: "Invalid",
+			config: `
+server:
+  unknown: true
+  scheduling:
+    cronTabs:
+    - "@every 1h"
+    - "* * *"
+    timezone: Nowhere
+  executor:
+    kubernetes:
+      renovateImagePullPolicy: sometimes
+github:
+- webhook:
+    path: /foo
+  disabledRepoNameMatch: "(["
+gitlab:
+- api:
+    oauthToken: foo
+  webhook:
+    path: /foo
+`,
+			problems: []string{
+				"line 3: field unknown not found in type conf.ServerConfig",
+				"line 7: server.scheduling.cronTabs[1]: invalid cron tab \"* * *\": expected 5 to 6 fields, found 3: [* * *]",
+				"line 8: server.scheduling.timezone: invalid timezone \"Nowhere\": unknown time zone Nowhere",
+				"line 11: server.executor.kubernetes.renovateImagePullPolicy: unsupported image pull policy \"sometimes\"",
+				"line 13: github[0].api.oauthToken: no oauth token or github app provided",
+				"line 15: github[0].disabledRepoNameMatch: invalid regex: error parsing regexp: missing closing ]: `[`",
+				"line 20: gitlab[0].webhook.path: duplicate webhook path \"/foo\", already used by github[0]",
+			},
+		},
+		{
+			name: "Incomplete",
+			config: `
+server:
+  leaderElection:
+    file:
+      path: /tmp/leader.lock
+  executor:
+    local: {}
+github:
+- api:
+    app:
+      appID: 1
+  webhook:
+    path: /github
+- api:
+    app:
+      privateKeyFile: /etc/github/app.pem
+  webhook:
+    path: /github-2
+`,
+			problems: []string{
+				"line 3: server.leaderElection: queue store is required for leader election",
+				"line 10: github[0].api.app.privateKeyFile: no github app private key file provided",
+				"line 15: github[1].api.app.appID: no github app id provided",
+			},
+		},
+		{
+			name:   "Empty",
+			config: "",
+			problems: []string{
+				"server.executor: no executor provided",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var problems []string
+			for _, p := range ValidateConfig([]byte(test.config)) {
+				problems = append(problems, p.String())
+			}
+
+			assert.Equal(t, test.problems, problems)
+		})
+	}
+}
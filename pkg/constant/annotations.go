@@ -2,5 +2,7 @@ package constant
 
 // nolint:revive
 const (
-	AnnotationRenovateRepos = "renovate.arhat.dev/repos"
+	AnnotationRenovateRepos    = "renovate.arhat.dev/repos"
+	AnnotationRenovateEndpoint = "renovate.arhat.dev/endpoint"
+	AnnotationRenovateManager  = "renovate.arhat.dev/manager"
 )
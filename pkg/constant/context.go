@@ -21,4 +21,5 @@ type ContextKey string
 // nolint:revive
 const (
 	ContextKeyConfig = ContextKey("config")
+	ContextKeyReload = ContextKey("reload")
 )
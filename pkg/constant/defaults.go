@@ -22,7 +22,51 @@ import "time"
 const (
 	DefaultRenovateServerConfigFile = "/etc/renovate-server/config.yaml"
 	DefaultWebhookListenAddress     = ":8080"
+	DefaultAdminListenAddress       = ":8081"
+	DefaultMonitoringListenAddress  = ":9090"
 	DefaultSchedulingDelay          = 60 * time.Second
+	DefaultConfigPollInterval       = 30 * time.Second
+)
+
+// Scheduling modes
+const (
+	SchedulingModeToken = "token"
+	SchedulingModeRepo  = "repo"
+)
+
+// Retry Defaults
+const (
+	DefaultRetryInitialBackoff = 60 * time.Second
+	DefaultRetryMultiplier     = 2
+	DefaultRetryMaxBackoff     = time.Hour
+	DefaultRetryMaxAttempts    = 5
+)
+
+// Paths served with the monitoring server
+const (
+	MetricsPath = "/metrics"
+	HealthzPath = "/healthz"
+	ReadyzPath  = "/readyz"
+)
+
+// Platform API
+const (
+	// MaxAPIPageSize is the max page size accepted by github and gitlab
+	MaxAPIPageSize = 100
+
+	// MaxAPIRateLimitWait is the max time to wait for rate limit reset, requests
+	// fail if the rate limit resets later
+	MaxAPIRateLimitWait = 5 * time.Minute
+)
+
+// Slash commands
+const (
+	DefaultCommandPrefix = "/renovate"
+)
+
+// Config validation
+const (
+	DefaultConfigValidationStatusContext = "renovate-server/config"
 )
 
 // GitHub Defaults
@@ -35,8 +79,23 @@ const (
 	DefaultGitLabAPIBaseURL = "https://gitlab.com/"
 )
 
+// Gitea Defaults
+const (
+	DefaultGiteaAPIBaseURL = "https://gitea.com/"
+)
+
+// Docker Defaults
+const (
+	DefaultDockerHost = "unix:///var/run/docker.sock"
+)
+
 // Renovate config
 const (
 	DefaultRenovateImage           = "docker.io/renovate/renovate:latest"
 	DefaultRenovateImagePullPolicy = "Always"
+	DefaultRenovateCommand         = "renovate"
+	DefaultConfigValidatorCommand  = "renovate-config-validator"
+	DefaultRenovateBaseDir         = "/tmp/renovate"
+	// gid of the user running renovate in the renovate image
+	DefaultRenovateGroupID = 1000
 )
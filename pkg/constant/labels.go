@@ -2,5 +2,12 @@ package constant
 
 // nolint:revive
 const (
-	LabelRenovateRepo = "renovate.arhat.dev/repo"
+	LabelRenovateRepo  = "renovate.arhat.dev/repo"
+	LabelRenovateCache = "renovate.arhat.dev/cache"
+
+	// LabelRenovateConfigValidation marks resources created to validate renovate config
+	LabelRenovateConfigValidation = "renovate.arhat.dev/config-validation"
+
+	// LabelRenovateInstance is the id of the renovate-server process created the resource
+	LabelRenovateInstance = "renovate.arhat.dev/instance"
 )
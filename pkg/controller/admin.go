@@ -0,0 +1,368 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"arhat.dev/pkg/log"
+
+	"arhat.dev/renovate-server/pkg/constant"
+)
+
+// states of executions
+const (
+	// waiting for due time in queue
+	executionStateScheduled = "scheduled"
+	// grouped with other repos in batch window (repo mode)
+	executionStateBatching = "batching"
+	// waiting for concurrency limits
+	executionStateWaiting = "waiting"
+	executionStateRunning = "running"
+)
+
+var (
+	errManagerNotFound = errors.New("platform manager not found")
+	errNotLeader       = errors.New("not the leader, pending executions are managed by the leader")
+)
+
+// Execution is a pending or running renovate execution
+type Execution struct {
+	Manager string   `json:"manager"`
+	Repos   []string `json:"repos"`
+	State   string   `json:"state"`
+
+	DueAt     *time.Time `json:"dueAt,omitempty"`
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+}
+
+// PendingExecutions returns executions not running yet, sorted by due time
+func (c *Controller) PendingExecutions() ([]Execution, error) {
+	ret := make([]Execution, 0)
+	if !c.isLeading() {
+		if c.store == nil {
+			return ret, nil
+		}
+
+		// the leader picks up executions from queue store
+		items, err := c.store.List()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pending executions: %w", err)
+		}
+
+		for i := range items {
+			ret = append(ret, Execution{
+				Manager: items[i].Manager,
+				Repos:   []string{items[i].Repo},
+				State:   executionStateScheduled,
+				DueAt:   &items[i].DueAt,
+			})
+		}
+	}
+
+	for _, d := range c.tq.Remains() {
+		t := d.Data.(*task)
+		dueAt := t.dueAt
+		ret = append(ret, Execution{
+			Manager: t.manager,
+			Repos:   t.repos,
+			State:   executionStateScheduled,
+			DueAt:   &dueAt,
+		})
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].DueAt.Before(*ret[j].DueAt)
+	})
+
+	if c.batcher != nil {
+		c.batcher.each(func(t *task) {
+			ret = append(ret, Execution{Manager: t.manager, Repos: t.repos, State: executionStateBatching})
+		})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, t := range c.waiting {
+		ret = append(ret, Execution{Manager: t.manager, Repos: t.repos, State: executionStateWaiting})
+	}
+
+	return ret, nil
+}
+
+// RunningExecutions returns executions started by this controller, sorted by start time
+func (c *Controller) RunningExecutions() []Execution {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ret := make([]Execution, 0, len(c.running))
+	for t := range c.running {
+		startedAt := t.startedAt
+		ret = append(ret, Execution{
+			Manager:   t.manager,
+			Repos:     t.repos,
+			State:     executionStateRunning,
+			StartedAt: &startedAt,
+		})
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].StartedAt.Before(*ret[j].StartedAt)
+	})
+
+	return ret
+}
+
+// Trigger renovate execution for repos of the platform manager without delay
+func (c *Controller) Trigger(manager string, repos ...string) error {
+	if _, ok := c.settings().managers[manager]; !ok {
+		return errManagerNotFound
+	}
+
+	if c.mode != constant.SchedulingModeRepo {
+		return c.scheduleAfter(manager, manager, repos, 0)
+	}
+
+	for _, r := range repos {
+		err := c.scheduleAfter(repoTaskKey(manager, r), manager, []string{r}, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CheckRepos lists and runs renovate against all repos of the platform manager
+// in background
+func (c *Controller) CheckRepos(manager string) error {
+	if _, ok := c.settings().managers[manager]; !ok {
+		return errManagerNotFound
+	}
+
+	if !c.isLeading() {
+		return errNotLeader
+	}
+
+	go c.checkRepos(c.logger.WithFields(log.String("job", "admin"), log.String("endpoint", manager)), manager)
+	return nil
+}
+
+// Cancel pending executions of the repo, all pending executions of the manager
+// are canceled if repo is empty, returns canceled executions
+func (c *Controller) Cancel(manager, repo string) ([]Execution, error) {
+	if _, ok := c.settings().managers[manager]; !ok {
+		return nil, errManagerNotFound
+	}
+
+	if !c.isLeading() {
+		return nil, errNotLeader
+	}
+
+	return c.removePending(func(m, r string) bool {
+		return m == manager && (repo == "" || r == repo)
+	}), nil
+}
+
+// Drain cancels all pending executions, returns canceled executions
+func (c *Controller) Drain() ([]Execution, error) {
+	if !c.isLeading() {
+		return nil, errNotLeader
+	}
+
+	return c.removePending(func(string, string) bool { return true }), nil
+}
+
+// removePending removes repos matched from tasks in queue, pending batches and
+// waiting tasks
+func (c *Controller) removePending(match func(manager, repo string) bool) []Execution {
+	split := func(t *task) (keep, drop []string) {
+		for _, r := range t.repos {
+			if match(t.manager, r) {
+				drop = append(drop, r)
+			} else {
+				keep = append(keep, r)
+			}
+		}
+
+		return
+	}
+
+	removed := make([]Execution, 0)
+	for _, d := range c.tq.Remains() {
+		if _, drop := split(d.Data.(*task)); len(drop) == 0 {
+			continue
+		}
+
+		t, ok := c.removeQueued(d.Key.(string))
+		if !ok {
+			// due just now
+			continue
+		}
+
+		keep, drop := split(t)
+		if len(keep) != 0 {
+			err := c.offer(&task{
+				key:         t.key,
+				manager:     t.manager,
+				repos:       keep,
+				scheduledAt: t.scheduledAt,
+				dueAt:       t.dueAt,
+			})
+			if err != nil {
+				c.logger.I("failed to requeue task", log.String("key", t.key), log.Error(err))
+			}
+		}
+
+		dueAt := t.dueAt
+		removed = append(removed, Execution{
+			Manager: t.manager,
+			Repos:   drop,
+			State:   executionStateScheduled,
+			DueAt:   &dueAt,
+		})
+	}
+
+	if c.batcher != nil {
+		for manager, repos := range c.batcher.remove(match) {
+			removed = append(removed, Execution{Manager: manager, Repos: repos, State: executionStateBatching})
+		}
+	}
+
+	c.mu.Lock()
+	var waiting []*task
+	for _, t := range c.waiting {
+		keep, drop := split(t)
+		if len(drop) != 0 {
+			removed = append(removed, Execution{Manager: t.manager, Repos: drop, State: executionStateWaiting})
+		}
+
+		if len(keep) != 0 {
+			t.repos = keep
+			waiting = append(waiting, t)
+		}
+	}
+	c.waiting = waiting
+	c.mu.Unlock()
+
+	c.mu.Lock()
+	for _, e := range removed {
+		for _, r := range e.Repos {
+			delete(c.attempts, repoKey{manager: e.Manager, repo: r})
+		}
+	}
+	c.mu.Unlock()
+
+	for _, e := range removed {
+		c.unpersist(e.Manager, e.Repos)
+	}
+
+	return removed
+}
+
+type adminRequest struct {
+	Manager string   `json:"manager"`
+	Repo    string   `json:"repo"`
+	Repos   []string `json:"repos"`
+}
+
+// adminHandler serves admin api, all requests MUST be authorized with the admin token
+func (c *Controller) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/v1/executions/pending", adminAPI(http.MethodGet, func(*adminRequest) (interface{}, error) {
+		return c.PendingExecutions()
+	}))
+	mux.HandleFunc("/api/v1/executions/running", adminAPI(http.MethodGet, func(*adminRequest) (interface{}, error) {
+		return c.RunningExecutions(), nil
+	}))
+	mux.HandleFunc("/api/v1/executions/failed", adminAPI(http.MethodGet, func(*adminRequest) (interface{}, error) {
+		return c.FailedExecutions(), nil
+	}))
+	mux.HandleFunc("/api/v1/trigger", adminAPI(http.MethodPost, func(req *adminRequest) (interface{}, error) {
+		repos := req.Repos
+		if req.Repo != "" {
+			repos = append(repos, req.Repo)
+		}
+
+		if len(repos) == 0 {
+			return nil, errBadRequest("no repo provided")
+		}
+
+		return map[string]interface{}{"manager": req.Manager, "repos": repos}, c.Trigger(req.Manager, repos...)
+	}))
+	mux.HandleFunc("/api/v1/check", adminAPI(http.MethodPost, func(req *adminRequest) (interface{}, error) {
+		return map[string]interface{}{"manager": req.Manager}, c.CheckRepos(req.Manager)
+	}))
+	mux.HandleFunc("/api/v1/cancel", adminAPI(http.MethodPost, func(req *adminRequest) (interface{}, error) {
+		return c.Cancel(req.Manager, req.Repo)
+	}))
+	mux.HandleFunc("/api/v1/drain", adminAPI(http.MethodPost, func(*adminRequest) (interface{}, error) {
+		return c.Drain()
+	}))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		digest := sha256.Sum256([]byte(token))
+		if subtle.ConstantTimeCompare(digest[:], c.adminToken) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+
+		mux.ServeHTTP(w, req)
+	})
+}
+
+type errBadRequest string
+
+func (e errBadRequest) Error() string {
+	return string(e)
+}
+
+// adminAPI handles admin api requests with method, request body is decoded as adminRequest
+// for POST requests, result or error returned by fn is encoded as json response
+func adminAPI(method string, fn func(req *adminRequest) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+
+		req := &adminRequest{}
+		if method == http.MethodPost {
+			err := json.NewDecoder(r.Body).Decode(req)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body: " + err.Error()})
+				return
+			}
+		}
+
+		ret, err := fn(req)
+		var badRequest errBadRequest
+		switch {
+		case err == nil:
+			writeJSON(w, http.StatusOK, ret)
+		case errors.As(err, &badRequest):
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		case errors.Is(err, errManagerNotFound):
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, errNotLeader):
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+		default:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
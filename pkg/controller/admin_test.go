@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/constant"
+)
+
+func TestController_adminHandler(t *testing.T) {
+	c := newTestController(t, &fakeExecutor{}, 0)
+	c.mode = constant.SchedulingModeRepo
+	c.settings().delay = time.Hour
+	digest := sha256.Sum256([]byte("admin-token"))
+	c.adminToken = digest[:]
+	c.settings().addManager("/a", &fakeManager{}, &conf.PlatformConfig{})
+	c.settings().addManager("/b", &fakeManager{}, &conf.PlatformConfig{})
+
+	assert.NoError(t, c.Schedule("/a", "foo/bar", "foo/baz"))
+	assert.NoError(t, c.Schedule("/b", "foo/bar"))
+	c.submit(&task{key: "/b#foo/qux", manager: "/b", repos: []string{"foo/qux"}})
+
+	handler := c.adminHandler()
+	call := func(method, path, token, body string) (int, []map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var ret []map[string]interface{}
+		_ = json.Unmarshal(rec.Body.Bytes(), &ret)
+		return rec.Code, ret
+	}
+
+	code, _ := call(http.MethodGet, "/api/v1/executions/pending", "", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = call(http.MethodGet, "/api/v1/executions/pending", "other-token", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, pending := call(http.MethodGet, "/api/v1/executions/pending", "admin-token", "")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, pending, 4) {
+		assert.Equal(t, "scheduled", pending[0]["state"])
+		assert.Equal(t, "waiting", pending[3]["state"])
+		assert.Equal(t, []interface{}{"foo/qux"}, pending[3]["repos"])
+	}
+
+	code, _ = call(http.MethodPost, "/api/v1/executions/pending", "admin-token", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+
+	code, canceled := call(http.MethodPost, "/api/v1/cancel", "admin-token", `{"manager":"/a","repo":"foo/bar"}`)
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, canceled, 1) {
+		assert.Equal(t, "/a", canceled[0]["manager"])
+		assert.Equal(t, []interface{}{"foo/bar"}, canceled[0]["repos"])
+	}
+	_, ok := c.tq.Find(repoTaskKey("/a", "foo/bar"))
+	assert.False(t, ok)
+
+	code, _ = call(http.MethodPost, "/api/v1/cancel", "admin-token", `{"manager":"/unknown"}`)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, canceled = call(http.MethodPost, "/api/v1/drain", "admin-token", `{}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, canceled, 3)
+
+	_, pending = call(http.MethodGet, "/api/v1/executions/pending", "admin-token", "")
+	assert.Empty(t, pending)
+
+	code, _ = call(http.MethodPost, "/api/v1/trigger", "admin-token", `{"manager":"/a"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = call(http.MethodPost, "/api/v1/trigger", "admin-token", `{"manager":"/a","repo":"foo/bar"}`)
+	assert.Equal(t, http.StatusOK, code)
+	select {
+	case d := <-c.tq.TakeCh():
+		assert.Equal(t, []string{"foo/bar"}, d.Data.(*task).repos)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "triggered execution not due")
+	}
+}
+
+func TestController_Cancel_TokenMode(t *testing.T) {
+	c := newTestController(t, &fakeExecutor{}, 0)
+	c.settings().delay = time.Hour
+	c.settings().addManager("/a", &fakeManager{}, &conf.PlatformConfig{})
+
+	assert.NoError(t, c.Schedule("/a", "foo/bar", "foo/baz"))
+
+	canceled, err := c.Cancel("/a", "foo/bar")
+	assert.NoError(t, err)
+	assert.Len(t, canceled, 1)
+
+	tk, ok := c.tq.Find("/a")
+	if assert.True(t, ok) {
+		assert.Equal(t, []string{"foo/baz"}, tk.(*task).repos)
+	}
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"sync"
+	"time"
+)
+
+func newBatcher(window time.Duration, maxSize int, submit func(manager string, repos []string)) *batcher {
+	return &batcher{
+		window:  window,
+		maxSize: maxSize,
+		submit:  submit,
+
+		pending: make(map[string]*pendingBatch),
+		mu:      new(sync.Mutex),
+	}
+}
+
+// batcher groups ready repos of the same platform manager within a time window
+type batcher struct {
+	window  time.Duration
+	maxSize int
+	submit  func(manager string, repos []string)
+
+	// manager -> batch collecting repos
+	pending map[string]*pendingBatch
+	mu      *sync.Mutex
+}
+
+type pendingBatch struct {
+	repos []string
+	timer *time.Timer
+}
+
+// add repos to the pending batch of the manager, the batch is submitted when
+// the window ends or max batch size reached
+//
+// batches are submitted with lock held, so repos are always visible to each
+// until submitted
+func (b *batcher) add(manager string, repos []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, r := range repos {
+		p, ok := b.pending[manager]
+		if !ok {
+			p = &pendingBatch{}
+			p.timer = time.AfterFunc(b.window, func() {
+				b.flush(manager, p)
+			})
+			b.pending[manager] = p
+		}
+
+		p.repos = mergeRepos(p.repos, []string{r})
+		if b.maxSize > 0 && len(p.repos) >= b.maxSize {
+			p.timer.Stop()
+			b.submit(manager, p.repos)
+			delete(b.pending, manager)
+		}
+	}
+}
+
+func (b *batcher) flush(manager string, p *pendingBatch) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pending[manager] != p {
+		// already submitted due to max batch size
+		return
+	}
+
+	b.submit(manager, p.repos)
+	delete(b.pending, manager)
+}
+
+// remove repos matched from pending batches, returns removed repos by manager
+func (b *batcher) remove(match func(manager, repo string) bool) map[string][]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ret := make(map[string][]string)
+	for manager, p := range b.pending {
+		var keep []string
+		for _, r := range p.repos {
+			if match(manager, r) {
+				ret[manager] = append(ret[manager], r)
+			} else {
+				keep = append(keep, r)
+			}
+		}
+
+		if len(keep) == 0 {
+			p.timer.Stop()
+			delete(b.pending, manager)
+			continue
+		}
+
+		p.repos = keep
+	}
+
+	return ret
+}
+
+// each calls fn with repos in pending batches
+func (b *batcher) each(fn func(t *task)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for manager, p := range b.pending {
+		fn(&task{manager: manager, repos: p.repos})
+	}
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatcher(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]string
+	)
+	b := newBatcher(100*time.Millisecond, 2, func(manager string, repos []string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		assert.Equal(t, "/a", manager)
+		batches = append(batches, repos)
+	})
+
+	b.add("/a", []string{"foo/a", "foo/b", "foo/c"})
+	b.add("/a", []string{"foo/c"})
+
+	mu.Lock()
+	assert.Equal(t, [][]string{{"foo/a", "foo/b"}}, batches)
+	mu.Unlock()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(batches) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	assert.Equal(t, []string{"foo/c"}, batches[1])
+	mu.Unlock()
+}
+
+func TestBatcher_remove(t *testing.T) {
+	submitted := make(chan []string, 1)
+	b := newBatcher(100*time.Millisecond, 0, func(manager string, repos []string) {
+		submitted <- repos
+	})
+
+	b.add("/a", []string{"foo/a", "foo/b"})
+	b.add("/b", []string{"foo/a"})
+
+	removed := b.remove(func(manager, repo string) bool { return repo == "foo/a" })
+	assert.Equal(t, map[string][]string{"/a": {"foo/a"}, "/b": {"foo/a"}}, removed)
+
+	// batch of /b is empty and not submitted
+	select {
+	case repos := <-submitted:
+		assert.Equal(t, []string{"foo/b"}, repos)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "batch not submitted")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	assert.Empty(t, submitted)
+}
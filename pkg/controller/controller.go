@@ -2,64 +2,68 @@ package controller
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"arhat.dev/pkg/log"
 	"arhat.dev/pkg/queue"
-	"github.com/robfig/cron/v3"
 
 	"arhat.dev/renovate-server/pkg/conf"
-	"arhat.dev/renovate-server/pkg/executor"
-	"arhat.dev/renovate-server/pkg/github"
-	"arhat.dev/renovate-server/pkg/gitlab"
+	"arhat.dev/renovate-server/pkg/constant"
+	"arhat.dev/renovate-server/pkg/metrics"
+	"arhat.dev/renovate-server/pkg/store"
 	"arhat.dev/renovate-server/pkg/types"
 )
 
 func NewController(ctx context.Context, config *conf.Config) (*Controller, error) {
-	var (
-		exec types.Executor
-		err  error
-	)
-	switch {
-	case config.Server.Executor.Kubernetes != nil:
-		exec, err = executor.NewKubernetesExecutor(ctx, config.Server.Executor.Kubernetes)
-	default:
-		return nil, fmt.Errorf("no executor provided")
-	}
+	tlsConfig, err := config.Server.Webhook.TLS.GetTLSConfig(true)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create executor: %w", err)
+		return nil, fmt.Errorf("failed to create tls config for webhook server: %w", err)
 	}
 
-	tlsConfig, err := config.Server.Webhook.TLS.GetTLSConfig(true)
+	queueStore, err := store.NewQueueStore(ctx, &config.Server.Store)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create tls config for webhook server: %w", err)
+		return nil, fmt.Errorf("failed to create queue store: %w", err)
 	}
 
-	var cronJob *cron.Cron
-	if len(config.Server.Scheduling.CronTabs) != 0 {
-		location := time.UTC
-		if config.Server.Scheduling.Timezone != "" {
-			location, err = time.LoadLocation(config.Server.Scheduling.Timezone)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse timezone: %w", err)
-			}
+	var (
+		adminListenAddr string
+		adminTLSConfig  *tls.Config
+		adminToken      []byte
+	)
+	if admin := config.Server.Admin; admin != nil {
+		if admin.Token == "" {
+			return nil, fmt.Errorf("admin api token is required")
 		}
 
-		cronJob = cron.New(
-			cron.WithLocation(location),
-			cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)),
-			cron.WithParser(
-				cron.NewParser(
-					cron.SecondOptional|cron.Minute|cron.Hour|cron.Dom|cron.Month|cron.Dow|cron.Descriptor,
-				),
-			),
-		)
+		adminListenAddr = admin.Listen
+		if adminListenAddr == "" {
+			adminListenAddr = constant.DefaultAdminListenAddress
+		}
+
+		adminTLSConfig, err = admin.TLS.GetTLSConfig(true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create tls config for admin api server: %w", err)
+		}
+
+		digest := sha256.Sum256([]byte(admin.Token))
+		adminToken = digest[:]
+	}
+
+	mode := config.Server.Scheduling.Mode
+	switch mode {
+	case "":
+		mode = constant.SchedulingModeToken
+	case constant.SchedulingModeToken, constant.SchedulingModeRepo:
+	default:
+		return nil, fmt.Errorf("unsupported scheduling mode %q", mode)
 	}
 
 	ctrl := &Controller{
@@ -67,31 +71,56 @@ func NewController(ctx context.Context, config *conf.Config) (*Controller, error
 
 		logger:     log.Log.WithName("controller"),
 		listenAddr: config.Server.Webhook.Listen,
-		managers:   make(map[string]types.PlatformManager),
 		tlsConfig:  tlsConfig,
 
-		delay:    config.Server.Scheduling.Delay,
-		executor: exec,
-		tq:       queue.NewTimeoutQueue(),
+		adminListenAddr: adminListenAddr,
+		adminTLSConfig:  adminTLSConfig,
+		adminToken:      adminToken,
 
-		cronTabs: config.Server.Scheduling.CronTabs,
-		cronJob:  cronJob,
+		monitoringListenAddr: config.Server.Monitoring.Listen,
+
+		mode:  mode,
+		tq:    queue.NewTimeoutQueue(),
+		store: queueStore,
+
+		reloadMu:          new(sync.Mutex),
+		wakeCh:            make(chan struct{}, 1),
+		mu:                new(sync.Mutex),
+		queued:            make(map[*task]struct{}),
+		running:           make(map[*task]struct{}),
+		runningPerManager: make(map[string]int),
+		authenticated:     make(map[string]struct{}),
+		healthChecks:      make(map[string]*cachedHealthCheck),
+
+		attempts:    make(map[repoKey]int),
+		deadLetters: make(map[repoKey]*FailedExecution),
+
+		leadershipLost: make(chan struct{}),
 	}
 
-	for i, gh := range config.GitHub {
-		mgr, err2 := github.NewManager(ctx, &config.GitHub[i], ctrl)
-		if err2 != nil {
-			return nil, fmt.Errorf("failed to create github manager, index %d: %w", i, err2)
-		}
-		ctrl.managers[gh.Webhook.Path] = mgr
+	s, err := ctrl.newSettings(config, nil)
+	if err != nil {
+		return nil, err
 	}
+	ctrl.current.Store(s)
 
-	for i, gh := range config.GitLab {
-		mgr, err2 := gitlab.NewManager(ctx, &config.GitLab[i], ctrl)
-		if err2 != nil {
-			return nil, fmt.Errorf("failed to create gitlab manager, index %d: %w", i, err2)
+	if config.Server.LeaderElection != nil {
+		if queueStore == nil {
+			return nil, fmt.Errorf("queue store is required for leader election")
 		}
-		ctrl.managers[gh.Webhook.Path] = mgr
+
+		ctrl.elector, err = newLeaderElector(config.Server.LeaderElection, ctrl.onElected, ctrl.onEjected)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create leader elector: %w", err)
+		}
+	}
+
+	if mode == constant.SchedulingModeRepo && config.Server.Scheduling.BatchWindow > 0 {
+		ctrl.batcher = newBatcher(
+			config.Server.Scheduling.BatchWindow,
+			config.Server.Scheduling.MaxBatchSize,
+			ctrl.submitBatch,
+		)
 	}
 
 	return ctrl, nil
@@ -102,25 +131,75 @@ type Controller struct {
 
 	logger     log.Interface
 	listenAddr string
-	managers   map[string]types.PlatformManager
 	tlsConfig  *tls.Config
 
-	delay    time.Duration
-	executor types.Executor
-	tq       *queue.TimeoutQueue
+	// admin api is disabled if listen address is empty
+	adminListenAddr string
+	adminTLSConfig  *tls.Config
+	// sha256 digest of admin api token
+	adminToken []byte
+
+	// monitoring server is disabled if listen address is empty
+	monitoringListenAddr string
+
+	mode string
+	tq   *queue.TimeoutQueue
+	// store persists pending executions, nil if not configured
+	store types.QueueStore
+
+	// current *settings, replaced when config reloaded
+	current  atomic.Value
+	reloadMu *sync.Mutex
+
+	// wakeCh notifies dispatcher to check waiting tasks
+	wakeCh chan struct{}
+
+	mu *sync.Mutex
+	// tasks offered to queue until taken or removed, tasks expired but not
+	// delivered by queue yet are not included in tq.Remains()
+	queued map[*task]struct{}
+	// tasks ready to run but waiting for concurrency limits
+	waiting []*task
+	// tasks running with executor
+	running           map[*task]struct{}
+	runningPerManager map[string]int
+	// platform managers authenticated with platform api
+	authenticated map[string]struct{}
+	// recent results of health checks calling external apis
+	healthChecks map[string]*cachedHealthCheck
+	// leader started dispatching and cron jobs
+	leaderStarted bool
+
+	// batcher groups ready repos in repo mode, nil if batching disabled
+	batcher  *batcher
+	batchSeq uint64
+
+	// failed attempts of repos pending retry
+	attempts    map[repoKey]int
+	deadLetters map[repoKey]*FailedExecution
+
+	// elector is nil if leader election disabled
+	elector        leaderElector
+	leading        int32
+	leadershipLost chan struct{}
+
+	cronRunning int32
+}
 
-	cronTabs []string
-	cronJob  *cron.Cron
+// settings returns current settings reloadable with config
+func (c *Controller) settings() *settings {
+	return c.current.Load().(*settings)
 }
 
 func (c *Controller) Start() error {
-	mux := http.NewServeMux()
-	for path := range c.managers {
-		mux.Handle(path, c.managers[path])
-	}
-
 	srv := &http.Server{
-		Handler:   mux,
+		// in-flight requests are served by handlers of previous settings after reload
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			s, release := c.acquireSettings()
+			defer release()
+
+			s.handler.ServeHTTP(w, req)
+		}),
 		TLSConfig: c.tlsConfig,
 		BaseContext: func(listener net.Listener) context.Context {
 			return c.ctx
@@ -137,29 +216,11 @@ func (c *Controller) Start() error {
 	}
 
 	c.tq.Start(c.ctx.Done())
-	go func() {
-		ch := c.tq.TakeCh()
-		for d := range ch {
-			args := d.Data.(types.ExecutionArgs)
-			c.logger.I("executing renovate")
-			err2 := c.executor.Execute(args)
-			if err2 != nil {
-				c.logger.I("failed to execute renovate for repo, rescheduling",
-					log.Strings("repos", args.Repos),
-					log.String("endpoint", args.APIURL),
-					log.Error(err),
-				)
-				_ = c.Schedule(args)
-			} else {
-				c.logger.I("finished renovate execution")
-			}
-		}
-	}()
 
 	go func() {
 		err2 := srv.Serve(l)
-		if err2 != nil && errors.Is(err, http.ErrServerClosed) {
-			panic(fmt.Errorf("failed to serve webhook server: %w", err))
+		if err2 != nil && !errors.Is(err2, http.ErrServerClosed) {
+			c.logger.E("failed to serve webhook server", log.Error(err2))
 		}
 	}()
 
@@ -174,84 +235,154 @@ func (c *Controller) Start() error {
 		}
 	}()
 
-	if c.cronJob != nil {
-		jobFunc := func() {
-			c.logger.I("working on cron job")
-
-			c.CheckAllRepos()
-
-			c.logger.I("cron job finished")
+	if c.adminListenAddr != "" {
+		err = c.startAdminServer()
+		if err != nil {
+			return err
 		}
-		for i := range c.cronTabs {
-			_, err = c.cronJob.AddFunc(c.cronTabs[i], jobFunc)
-			if err != nil {
-				return err
-			}
+	}
+
+	if c.monitoringListenAddr != "" {
+		err = c.startMonitoringServer()
+		if err != nil {
+			return err
 		}
+	}
+
+	if c.elector == nil {
+		c.startLeading()
+		return nil
+	}
+
+	// webhooks are served by all replicas, executions are persisted for the leader
+	c.logger.I("waiting for leader election")
+	go c.elector.Run(c.ctx)
+
+	return nil
+}
 
-		c.cronJob.Start()
+// startAdminServer serves admin api with a separate listener
+func (c *Controller) startAdminServer() error {
+	srv := &http.Server{
+		Handler:   c.adminHandler(),
+		TLSConfig: c.adminTLSConfig,
+		BaseContext: func(listener net.Listener) context.Context {
+			return c.ctx
+		},
+	}
+
+	l, err := net.Listen("tcp", c.adminListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen tcp for admin api server: %w", err)
+	}
+
+	if c.adminTLSConfig != nil {
+		l = tls.NewListener(l, c.adminTLSConfig)
 	}
 
+	go func() {
+		err2 := srv.Serve(l)
+		if err2 != nil && !errors.Is(err2, http.ErrServerClosed) {
+			c.logger.E("failed to serve admin api server", log.Error(err2))
+		}
+	}()
+
+	go func() {
+		<-c.ctx.Done()
+		_ = srv.Close()
+	}()
+
+	c.logger.I("admin api server started", log.String("listen", c.adminListenAddr))
 	return nil
 }
 
 func (c *Controller) CheckAllRepos() {
 	wg := new(sync.WaitGroup)
-	for k := range c.managers {
+	for k := range c.settings().managers {
 		wg.Add(1)
 
 		go func(key string) {
 			defer wg.Done()
 
-			logger := c.logger.WithFields(
+			c.checkRepos(c.logger.WithFields(
 				log.String("job", "cron"),
 				log.String("endpoint", key),
-			)
-			mgr := c.managers[key]
-			repos, err2 := mgr.ListRepos()
-			if err2 != nil {
-				logger.I("failed to list repos", log.Error(err2))
-				return
-			}
-
-			args := mgr.ExecutionArgs(repos...)
-			err2 = c.executor.Execute(args)
-			if err2 != nil {
-				logger.I("failed to execute all repos check job, schedule as normal job", log.Error(err2))
-				err2 = c.Schedule(args)
-				if err2 != nil {
-					logger.I("failed to schedule all repos check job as normal job", log.Error(err2))
-				}
-				return
-			}
+			), key)
 		}(k)
 	}
 
 	wg.Wait()
 }
 
-func (c *Controller) Schedule(args types.ExecutionArgs) error {
-	key := args.APIURL + args.APIToken
-	repos := args.Repos
-
-	oldArgs, removed := c.tq.Remove(key)
-	if removed {
-		oldRepos := oldArgs.(types.ExecutionArgs).Repos
-
-		for _, oldR := range oldRepos {
-			found := false
-			for _, r := range args.Repos {
-				if oldR == r {
-					found = true
-				}
-			}
-
-			if !found {
-				repos = append(repos, oldR)
-			}
-		}
+// checkRepos lists repos of the platform manager and runs renovate against them
+func (c *Controller) checkRepos(logger log.Interface, key string) {
+	s, release := c.acquireSettings()
+	defer release()
+
+	mgr, ok := s.managers[key]
+	if !ok {
+		// removed by config reload
+		return
+	}
+
+	startedAt := time.Now()
+	repos, err := mgr.ListRepos()
+	if err != nil {
+		metrics.ListReposDuration.WithLabelValues(key, "failed").Observe(time.Since(startedAt).Seconds())
+		logger.I("failed to list repos", log.Error(err))
+		return
+	}
+	metrics.ListReposDuration.WithLabelValues(key, "succeeded").Observe(time.Since(startedAt).Seconds())
+
+	if c.mode == constant.SchedulingModeToken {
+		c.submit(&task{key: key, manager: key, repos: repos})
+		return
 	}
 
-	args.Repos = repos
-	return c.tq.OfferWithDelay(key, args, c.delay)
+	for _, r := range repos {
+		c.onTaskReady(&task{key: repoTaskKey(key, r), manager: key, repos: []string{r}})
+	}
+}
+
+// startMonitoringServer serves metrics and health probes with a separate listener
+func (c *Controller) startMonitoringServer() error {
+	srv := &http.Server{
+		Handler: c.monitoringHandler(),
+		BaseContext: func(listener net.Listener) context.Context {
+			return c.ctx
+		},
+	}
+
+	l, err := net.Listen("tcp", c.monitoringListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen tcp for monitoring server: %w", err)
+	}
+
+	go func() {
+		err2 := srv.Serve(l)
+		if err2 != nil && !errors.Is(err2, http.ErrServerClosed) {
+			c.logger.E("failed to serve monitoring server", log.Error(err2))
+		}
+	}()
+
+	go func() {
+		<-c.ctx.Done()
+		_ = srv.Close()
+	}()
+
+	c.logger.I("monitoring server started", log.String("listen", c.monitoringListenAddr))
+	return nil
+}
+
+func (c *Controller) monitoringHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(constant.MetricsPath, metrics.Handler())
+	mux.Handle(constant.HealthzPath, healthHandler(func(*http.Request) *HealthReport {
+		return c.Healthy()
+	}))
+	mux.Handle(constant.ReadyzPath, healthHandler(func(req *http.Request) *HealthReport {
+		return c.Ready(req.Context())
+	}))
+
+	return mux
 }
@@ -0,0 +1,253 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"arhat.dev/pkg/log"
+
+	"arhat.dev/renovate-server/pkg/metrics"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+// interval to recheck waiting tasks, executions not started by this
+// process may finish without notifying us
+const dispatchInterval = 10 * time.Second
+
+// dispatch starts ready tasks with respect to concurrency limits
+func (c *Controller) dispatch() {
+	ticker := time.NewTicker(dispatchInterval)
+	defer ticker.Stop()
+
+	takeCh := c.tq.TakeCh()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case d := <-takeCh:
+			c.onTaskReady(d.Data.(*task))
+		case <-c.wakeCh:
+		case <-ticker.C:
+			if c.elector != nil {
+				// pick up executions persisted by other replicas
+				err := c.syncPending()
+				if err != nil {
+					c.logger.I("failed to sync pending executions", log.Error(err))
+				}
+			}
+		}
+
+		c.startWaitingTasks()
+		c.updateQueueMetrics()
+	}
+}
+
+// onTaskReady handles task due in queue, tasks are grouped by batcher if enabled
+func (c *Controller) onTaskReady(t *task) {
+	// task is tracked as queued until handed over
+	defer func() {
+		c.mu.Lock()
+		delete(c.queued, t)
+		c.mu.Unlock()
+	}()
+
+	if c.batcher != nil {
+		c.batcher.add(t.manager, t.repos)
+		return
+	}
+
+	c.submit(t)
+}
+
+func (c *Controller) submitBatch(manager string, repos []string) {
+	c.mu.Lock()
+	c.batchSeq++
+	seq := c.batchSeq
+	c.mu.Unlock()
+
+	c.submit(&task{
+		key:     fmt.Sprintf("%s#batch-%d", manager, seq),
+		manager: manager,
+		repos:   repos,
+	})
+}
+
+// submit task to run as soon as concurrency limits allow
+func (c *Controller) submit(t *task) {
+	if t.scheduledAt.IsZero() {
+		t.scheduledAt = time.Now()
+	}
+
+	c.addWaiting(t)
+	c.wake()
+}
+
+func (c *Controller) wake() {
+	select {
+	case c.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Controller) addWaiting(t *task) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, w := range c.waiting {
+		if w.key == t.key {
+			w.repos = mergeRepos(w.repos, t.repos)
+			return
+		}
+	}
+
+	c.waiting = append(c.waiting, t)
+}
+
+func (c *Controller) startWaitingTasks() {
+	var untracked map[string]int
+	if counter, ok := c.settings().executor.Executor.(types.ExecutionCounter); ok {
+		untracked = counter.UntrackedExecutions()
+	}
+
+	untrackedTotal := 0
+	for _, n := range untracked {
+		untrackedTotal += n
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.settings()
+	var remaining []*task
+	for _, t := range c.waiting {
+		if s.maxConcurrent > 0 && len(c.running)+untrackedTotal >= s.maxConcurrent {
+			remaining = append(remaining, t)
+			continue
+		}
+
+		limit := s.managerLimits[t.manager]
+		if limit > 0 && c.runningPerManager[t.manager]+untracked[t.manager] >= limit {
+			remaining = append(remaining, t)
+			continue
+		}
+
+		t.startedAt = time.Now()
+		c.running[t] = struct{}{}
+		c.runningPerManager[t.manager]++
+
+		go c.run(t)
+	}
+
+	c.waiting = remaining
+}
+
+func (c *Controller) run(t *task) {
+	// settings are replaced with lock held when reloading, executor of previous
+	// settings is stopped after executions finished
+	c.mu.Lock()
+	s := c.settings()
+	s.executor.executions.Add(1)
+	s.inflight.Add(1)
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.running, t)
+		c.runningPerManager[t.manager]--
+		c.mu.Unlock()
+
+		s.inflight.Done()
+		s.executor.executions.Done()
+		c.wake()
+	}()
+
+	logger := c.logger.WithFields(log.String("manager", t.manager))
+
+	mgr, ok := s.managers[t.manager]
+	if !ok {
+		logger.I("platform manager not found, discarding task", log.Strings("repos", t.repos))
+		c.unpersist(t.manager, t.repos)
+		return
+	}
+
+	args, err := mgr.ExecutionArgs(t.repos...)
+	if err != nil {
+		logger.I("failed to prepare renovate execution", log.Error(err))
+		c.onExecutionFailed(logger, t, err, types.IsPermanent(err))
+		return
+	}
+	args.Manager = t.manager
+
+	c.execute(logger, s.executor, t, args)
+}
+
+// execute runs renovate with executor and retries the execution if it failed
+func (c *Controller) execute(logger log.Interface, exec *executorInstance, t *task, args types.ExecutionArgs) {
+	logger = logger.WithFields(
+		log.Strings("repos", args.Repos),
+		log.String("endpoint", args.APIURL),
+	)
+
+	logger.I("executing renovate")
+	metrics.ExecutionsStarted.WithLabelValues(exec.name, t.manager).Inc()
+
+	var result string
+	err := exec.Execute(args)
+	switch {
+	case err == nil:
+		result = "succeeded"
+		logger.I("finished renovate execution")
+		c.onExecutionSucceeded(t)
+		c.unpersistFinished(t.manager, t.repos)
+	case errors.Is(err, context.Canceled):
+		result = "canceled"
+		// kept in queue store to be restored after restart
+		logger.I("renovate execution canceled", log.Error(err))
+	default:
+		// executions exceeded deadline are retried as well, moved to dead letters
+		// after max attempts
+		result = "failed"
+		logger.I("failed to execute renovate", log.Error(err))
+		c.onExecutionFailed(logger, t, err, types.IsPermanent(err))
+	}
+
+	metrics.ExecutionsFinished.WithLabelValues(exec.name, t.manager, result).Inc()
+}
+
+// updateQueueMetrics sets queue depth and age of the oldest pending execution,
+// tasks in batch window are not counted
+func (c *Controller) updateQueueMetrics() {
+	var (
+		oldest           time.Time
+		scheduled, ready int
+	)
+	observe := func(t *task) {
+		if oldest.IsZero() || t.scheduledAt.Before(oldest) {
+			oldest = t.scheduledAt
+		}
+	}
+
+	for _, d := range c.tq.Remains() {
+		t := d.Data.(*task)
+		scheduled += len(t.repos)
+		observe(t)
+	}
+
+	c.mu.Lock()
+	for _, t := range c.waiting {
+		ready += len(t.repos)
+		observe(t)
+	}
+	c.mu.Unlock()
+
+	metrics.QueueDepth.WithLabelValues(executionStateScheduled).Set(float64(scheduled))
+	metrics.QueueDepth.WithLabelValues(executionStateWaiting).Set(float64(ready))
+
+	age := 0.0
+	if !oldest.IsZero() {
+		age = time.Since(oldest).Seconds()
+	}
+	metrics.QueueOldestItemAge.Set(age)
+}
@@ -0,0 +1,289 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"arhat.dev/pkg/log"
+	"arhat.dev/pkg/queue"
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/constant"
+	"arhat.dev/renovate-server/pkg/store"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+type fakeManager struct {
+	http.Handler
+	apiURL string
+}
+
+func (m *fakeManager) ListRepos() ([]string, error) { return nil, nil }
+
+func (m *fakeManager) APIURL() string { return m.apiURL }
+
+func (m *fakeManager) ExecutionArgs(repos ...string) (types.ExecutionArgs, error) {
+	return types.ExecutionArgs{APIURL: m.apiURL, Repos: repos}, nil
+}
+
+// fakeExecutor blocks executions until released
+type fakeExecutor struct {
+	mu        sync.Mutex
+	running   int
+	untracked map[string]int
+	release   chan struct{}
+	// returned by Execute after released
+	err error
+}
+
+func (e *fakeExecutor) Execute(args types.ExecutionArgs) error {
+	e.mu.Lock()
+	e.running++
+	e.mu.Unlock()
+
+	<-e.release
+
+	e.mu.Lock()
+	e.running--
+	e.mu.Unlock()
+	return e.err
+}
+
+func (e *fakeExecutor) Running() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.running
+}
+
+func (e *fakeExecutor) UntrackedExecutions() map[string]int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ret := make(map[string]int)
+	for k, v := range e.untracked {
+		ret[k] = v
+	}
+	return ret
+}
+
+func newTestController(t *testing.T, exec types.Executor, maxConcurrent int) *Controller {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tq := queue.NewTimeoutQueue()
+	tq.Start(ctx.Done())
+
+	c := &Controller{
+		ctx:    ctx,
+		logger: log.NoOpLogger,
+
+		mode: constant.SchedulingModeToken,
+		tq:   tq,
+
+		reloadMu:          new(sync.Mutex),
+		wakeCh:            make(chan struct{}, 1),
+		mu:                new(sync.Mutex),
+		queued:            make(map[*task]struct{}),
+		running:           make(map[*task]struct{}),
+		runningPerManager: make(map[string]int),
+		authenticated:     make(map[string]struct{}),
+		healthChecks:      make(map[string]*cachedHealthCheck),
+
+		attempts:    make(map[repoKey]int),
+		deadLetters: make(map[repoKey]*FailedExecution),
+	}
+
+	c.current.Store(&settings{
+		config:        &conf.Config{},
+		managers:      make(map[string]types.PlatformManager),
+		managerLimits: make(map[string]int),
+		handler:       http.NotFoundHandler(),
+		cancel:        func() {},
+		inflight:      new(sync.WaitGroup),
+
+		executor: &executorInstance{
+			Executor:   exec,
+			cancel:     func() {},
+			executions: new(sync.WaitGroup),
+		},
+
+		maxConcurrent: maxConcurrent,
+		retry: conf.RetryConfig{
+			InitialBackoff: time.Minute,
+			Multiplier:     2,
+			MaxBackoff:     5 * time.Minute,
+			MaxAttempts:    3,
+		},
+	})
+
+	return c
+}
+
+func TestController_ConcurrencyLimits(t *testing.T) {
+	exec := &fakeExecutor{
+		untracked: map[string]int{"/b": 1},
+		release:   make(chan struct{}),
+	}
+	c := newTestController(t, exec, 3)
+	c.settings().addManager("/a", &fakeManager{apiURL: "https://a.example.com/"}, &conf.PlatformConfig{MaxConcurrentExecutions: 1})
+	// same api url as /a
+	c.settings().addManager("/b", &fakeManager{apiURL: "https://a.example.com/"}, &conf.PlatformConfig{})
+
+	c.submit(&task{key: "/a", manager: "/a", repos: []string{"a/1"}})
+	c.submit(&task{key: "/a-2", manager: "/a", repos: []string{"a/2"}})
+	c.submit(&task{key: "/b", manager: "/b", repos: []string{"b/1"}})
+	c.submit(&task{key: "/b-2", manager: "/b", repos: []string{"b/2"}})
+
+	c.startWaitingTasks()
+
+	// one of /a (manager limit) and one of /b (global limit with 1 untracked)
+	assert.Eventually(t, func() bool { return exec.Running() == 2 }, 5*time.Second, 10*time.Millisecond)
+	c.mu.Lock()
+	assert.Len(t, c.waiting, 2)
+	assert.Equal(t, 1, c.runningPerManager["/a"])
+	assert.Equal(t, 1, c.runningPerManager["/b"])
+	c.mu.Unlock()
+
+	// untracked execution finished
+	exec.mu.Lock()
+	exec.untracked = nil
+	exec.mu.Unlock()
+
+	c.startWaitingTasks()
+	assert.Eventually(t, func() bool { return exec.Running() == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	go c.dispatch()
+	for i := 0; i < 4; i++ {
+		exec.release <- struct{}{}
+	}
+
+	assert.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.waiting) == 0 && len(c.running) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestController_Schedule_RepoMode(t *testing.T) {
+	c := newTestController(t, &fakeExecutor{}, 0)
+	c.mode = constant.SchedulingModeRepo
+	c.settings().delay = time.Hour
+
+	assert.NoError(t, c.Schedule("/a", "foo/bar", "foo/baz"))
+	assert.NoError(t, c.Schedule("/a", "foo/bar"))
+
+	assert.Len(t, c.tq.Remains(), 2)
+	for _, r := range []string{"foo/bar", "foo/baz"} {
+		tk, ok := c.tq.Find(repoTaskKey("/a", r))
+		if assert.True(t, ok) {
+			assert.Equal(t, []string{r}, tk.(*task).repos)
+		}
+	}
+}
+
+func TestController_syncPending(t *testing.T) {
+	queueStore, err := store.NewFileStore(&conf.FileStoreConfig{Dir: t.TempDir()})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	c := newTestController(t, &fakeExecutor{}, 0)
+	c.store = queueStore
+	c.settings().delay = time.Hour
+	c.settings().addManager("/a", &fakeManager{}, &conf.PlatformConfig{})
+
+	assert.NoError(t, c.Schedule("/a", "foo/bar"))
+	assert.NoError(t, c.Schedule("/a", "foo/baz"))
+	assert.NoError(t, queueStore.Put(types.PendingExecution{Manager: "/removed", Repo: "foo/bar"}))
+
+	// restarted
+	exec := &fakeExecutor{release: make(chan struct{})}
+	c2 := newTestController(t, exec, 0)
+	c2.store = queueStore
+	c2.settings().addManager("/a", &fakeManager{}, &conf.PlatformConfig{})
+
+	assert.NoError(t, c2.syncPending())
+	tk, ok := c2.tq.Find("/a")
+	if assert.True(t, ok) {
+		assert.ElementsMatch(t, []string{"foo/bar", "foo/baz"}, tk.(*task).repos)
+	}
+
+	items, err := queueStore.List()
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+
+	// taken from queue when due
+	_, _ = c2.removeQueued("/a")
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		c2.run(tk.(*task))
+	}()
+
+	// kept in store until finished
+	assert.Eventually(t, func() bool { return exec.Running() == 1 }, 5*time.Second, 10*time.Millisecond)
+	items, err = queueStore.List()
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+
+	close(exec.release)
+	<-finished
+	items, err = queueStore.List()
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestController_syncPending_due(t *testing.T) {
+	queueStore, err := store.NewFileStore(&conf.FileStoreConfig{Dir: t.TempDir()})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	c := newTestController(t, &fakeExecutor{}, 0)
+	c.store = queueStore
+	c.settings().addManager("/a", &fakeManager{}, &conf.PlatformConfig{})
+
+	// due but not taken by dispatcher yet
+	assert.NoError(t, c.Schedule("/a", "foo/bar"))
+	assert.Eventually(t, func() bool { return len(c.tq.Remains()) == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, c.syncPending())
+	assert.Empty(t, c.tq.Remains())
+
+	d := <-c.tq.TakeCh()
+	c.onTaskReady(d.Data.(*task))
+	assert.NoError(t, c.syncPending())
+	assert.Empty(t, c.tq.Remains())
+	if assert.Len(t, c.waiting, 1) {
+		assert.Equal(t, []string{"foo/bar"}, c.waiting[0].repos)
+	}
+}
+
+func TestController_onExecutionFailed_persisted(t *testing.T) {
+	queueStore, err := store.NewFileStore(&conf.FileStoreConfig{Dir: t.TempDir()})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	c := newTestController(t, &fakeExecutor{}, 0)
+	c.store = queueStore
+	assert.NoError(t, queueStore.Put(types.PendingExecution{Manager: "/a", Repo: "foo/bar"}))
+	assert.NoError(t, queueStore.Put(types.PendingExecution{Manager: "/a", Repo: "foo/baz"}))
+
+	// retried repos are persisted again
+	c.onExecutionFailed(log.NoOpLogger, &task{manager: "/a", repos: []string{"foo/bar"}}, errors.New("failed"), false)
+	// dead letters are removed
+	c.onExecutionFailed(log.NoOpLogger, &task{manager: "/a", repos: []string{"foo/baz"}}, errors.New("failed"), true)
+
+	items, err := queueStore.List()
+	assert.NoError(t, err)
+	if assert.Len(t, items, 1) {
+		assert.Equal(t, "foo/bar", items[0].Repo)
+		assert.True(t, items[0].DueAt.After(time.Now()))
+	}
+}
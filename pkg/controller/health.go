@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+// timeout of a single health check
+const healthCheckTimeout = 5 * time.Second
+
+// results of checks calling platform or executor api are reused within this
+// duration to avoid calling external apis for every probe
+const healthCheckCacheTTL = 30 * time.Second
+
+var errCronNotRunning = errors.New("cron scheduler not running")
+
+// HealthCheck is the result of a single health check
+type HealthCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// cachedHealthCheck is the result of a check against target
+type cachedHealthCheck struct {
+	target    interface{}
+	checkedAt time.Time
+	err       error
+}
+
+// HealthReport is the result of all health checks
+type HealthReport struct {
+	OK     bool          `json:"ok"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+// Healthy checks the controller is not stopped
+func (c *Controller) Healthy() *HealthReport {
+	check := HealthCheck{Name: "controller", OK: c.ctx.Err() == nil}
+	if !check.OK {
+		check.Error = c.ctx.Err().Error()
+	}
+
+	return &HealthReport{OK: check.OK, Checks: []HealthCheck{check}}
+}
+
+// Ready checks every platform manager has authenticated with platform api,
+// the executor backend is reachable and the cron scheduler is running
+func (c *Controller) Ready(ctx context.Context) *HealthReport {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	s := c.settings()
+	var (
+		checks = make([]HealthCheck, 0, len(s.managers)+2)
+		mu     = new(sync.Mutex)
+		wg     = new(sync.WaitGroup)
+	)
+	add := func(name string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		check := HealthCheck{Name: name, OK: err == nil}
+		if err != nil {
+			check.Error = err.Error()
+		}
+		checks = append(checks, check)
+	}
+
+	for k, mgr := range s.managers {
+		wg.Add(1)
+
+		go func(key string, mgr types.PlatformManager) {
+			defer wg.Done()
+
+			add("manager:"+key, c.checkAuthenticated(ctx, key, mgr))
+		}(k, mgr)
+	}
+
+	if hc, ok := s.executor.Executor.(types.HealthChecker); ok {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			name := "executor:" + s.executor.name
+			add(name, c.cachedCheck(ctx, name, s.executor, hc))
+		}()
+	}
+
+	// cron jobs only run in the leader
+	if s.cronJob != nil && c.isLeading() {
+		var err error
+		if atomic.LoadInt32(&c.cronRunning) != 1 {
+			err = errCronNotRunning
+		}
+
+		add("cron", err)
+	}
+
+	wg.Wait()
+
+	sort.Slice(checks, func(i, j int) bool {
+		return checks[i].Name < checks[j].Name
+	})
+
+	report := &HealthReport{OK: true, Checks: checks}
+	for _, check := range checks {
+		report.OK = report.OK && check.OK
+	}
+
+	return report
+}
+
+// checkAuthenticated checks the platform manager with platform api until it
+// authenticated successfully once, credentials are not checked again to save
+// api rate limit
+func (c *Controller) checkAuthenticated(ctx context.Context, key string, mgr types.PlatformManager) error {
+	c.mu.Lock()
+	_, authenticated := c.authenticated[key]
+	c.mu.Unlock()
+
+	if authenticated {
+		return nil
+	}
+
+	if hc, ok := mgr.(types.HealthChecker); ok {
+		err := c.cachedCheck(ctx, "manager:"+key, mgr, hc)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	// not reloaded during the check
+	if c.settings().managers[key] == mgr {
+		c.authenticated[key] = struct{}{}
+	}
+	c.mu.Unlock()
+
+	return nil
+}
+
+// cachedCheck returns result of the last check against the same target if not
+// older than healthCheckCacheTTL, otherwise checks again
+func (c *Controller) cachedCheck(
+	ctx context.Context, name string, target interface{}, hc types.HealthChecker,
+) error {
+	c.mu.Lock()
+	cached, ok := c.healthChecks[name]
+	c.mu.Unlock()
+
+	if ok && cached.target == target && time.Since(cached.checkedAt) < healthCheckCacheTTL {
+		return cached.err
+	}
+
+	err := hc.CheckHealth(ctx)
+
+	c.mu.Lock()
+	c.healthChecks[name] = &cachedHealthCheck{target: target, checkedAt: time.Now(), err: err}
+	c.mu.Unlock()
+
+	return err
+}
+
+// healthHandler serves health report, details of each check are returned as json
+// when the `verbose` query parameter is set
+func healthHandler(check func(req *http.Request) *HealthReport) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		report := check(req)
+
+		code := http.StatusOK
+		if !report.OK {
+			code = http.StatusServiceUnavailable
+		}
+
+		if _, verbose := req.URL.Query()["verbose"]; verbose {
+			writeJSON(w, code, report)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(code)
+		if report.OK {
+			_, _ = w.Write([]byte("ok\n"))
+		} else {
+			_, _ = w.Write([]byte("not ok\n"))
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/constant"
+)
+
+// fakeCheckedManager fails health checks until authorized
+type fakeCheckedManager struct {
+	fakeManager
+
+	authorized bool
+	checks     int
+}
+
+func (m *fakeCheckedManager) CheckHealth(context.Context) error {
+	m.checks++
+	if !m.authorized {
+		return errors.New("unauthorized")
+	}
+
+	return nil
+}
+
+func TestController_Ready(t *testing.T) {
+	c := newTestController(t, &fakeExecutor{}, 0)
+	c.settings().executor.name = "fake"
+	cronJob := cron.New()
+	c.settings().cronJob = cronJob
+	t.Cleanup(func() { cronJob.Stop() })
+
+	mgr := &fakeCheckedManager{}
+	c.settings().addManager("/a", mgr, &conf.PlatformConfig{})
+	c.settings().addManager("/b", &fakeManager{}, &conf.PlatformConfig{})
+
+	handler := healthHandler(func(req *http.Request) *HealthReport {
+		return c.Ready(req.Context())
+	})
+	call := func(path string) (int, string) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	code, body := call("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not ok\n", body)
+	assert.Equal(t, 1, mgr.checks)
+
+	code, body = call("/readyz?verbose")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	report := &HealthReport{}
+	assert.NoError(t, json.Unmarshal([]byte(body), report))
+	assert.Equal(t, &HealthReport{
+		OK: false,
+		Checks: []HealthCheck{
+			{Name: "cron", OK: false, Error: errCronNotRunning.Error()},
+			{Name: "manager:/a", OK: false, Error: "unauthorized"},
+			{Name: "manager:/b", OK: true},
+		},
+	}, report)
+
+	// failed check is cached
+	assert.Equal(t, 1, mgr.checks)
+
+	mgr.authorized = true
+	c.startLeading()
+	c.healthChecks["manager:/a"].checkedAt = time.Now().Add(-healthCheckCacheTTL)
+
+	code, body = call("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok\n", body)
+
+	// credentials are not checked again once authenticated
+	mgr.authorized = false
+	code, _ = call("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, mgr.checks)
+}
+
+func TestController_monitoringHandler(t *testing.T) {
+	c := newTestController(t, &fakeExecutor{}, 0)
+	handler := c.monitoringHandler()
+
+	for _, path := range []string{constant.MetricsPath, constant.HealthzPath, constant.ReadyzPath} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+	}
+}
@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"arhat.dev/pkg/envhelper"
+	"arhat.dev/pkg/kubehelper"
+	"arhat.dev/pkg/log"
+
+	"arhat.dev/renovate-server/pkg/conf"
+)
+
+const (
+	defaultLeaderElectionLockName      = "renovate-server-leader-election"
+	defaultLeaderElectionRetryInterval = 5 * time.Second
+)
+
+// leaderElector calls back when this replica started leading, Run blocks until ctx
+// canceled or leadership lost
+type leaderElector interface {
+	Run(ctx context.Context)
+}
+
+func newLeaderElector(
+	config *conf.LeaderElectionConfig,
+	onElected func(ctx context.Context),
+	onEjected func(),
+) (leaderElector, error) {
+	switch {
+	case config.Kubernetes != nil:
+		client, _, err := config.Kubernetes.KubeClient.NewKubeClient(nil, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+		}
+
+		electionConfig := &kubehelper.LeaderElectionConfig{
+			Identity: config.Kubernetes.Identity,
+			Lock:     config.Kubernetes.Lock,
+			Lease:    config.Kubernetes.Lease,
+		}
+		setLeaderElectionDefaults(electionConfig)
+
+		return electionConfig.CreateElector("renovate-server", client, nil, onElected, onEjected, nil)
+	case config.File != nil:
+		if !fileLockSupported {
+			return nil, fmt.Errorf("file lock is not supported on %s", runtime.GOOS)
+		}
+
+		if config.File.Path == "" {
+			return nil, fmt.Errorf("lock file path is required")
+		}
+
+		retryInterval := config.File.RetryInterval
+		if retryInterval <= 0 {
+			retryInterval = defaultLeaderElectionRetryInterval
+		}
+
+		return &fileLockElector{
+			path:          config.File.Path,
+			retryInterval: retryInterval,
+			onElected:     onElected,
+		}, nil
+	default:
+		return nil, fmt.Errorf("no leader election method provided")
+	}
+}
+
+func setLeaderElectionDefaults(c *kubehelper.LeaderElectionConfig) {
+	if c.Identity == "" {
+		c.Identity = envhelper.ThisPodName()
+	}
+
+	if c.Lock.Type == "" {
+		c.Lock.Type = "leases"
+	}
+
+	if c.Lock.Name == "" {
+		c.Lock.Name = defaultLeaderElectionLockName
+	}
+
+	if c.Lock.Namespace == "" {
+		c.Lock.Namespace = envhelper.ThisPodNS()
+	}
+
+	if c.Lease.Expiration == 0 {
+		c.Lease.Expiration = 15 * time.Second
+	}
+
+	if c.Lease.RenewDeadline == 0 {
+		c.Lease.RenewDeadline = 10 * time.Second
+	}
+
+	if c.Lease.RenewInterval == 0 {
+		c.Lease.RenewInterval = 2 * time.Second
+	}
+
+	if c.Lease.ExpiryToleration == 0 {
+		c.Lease.ExpiryToleration = 10 * time.Second
+	}
+}
+
+// fileLockElector elects the replica holding the lock file as leader, the lock is
+// released by the system when the process exits
+type fileLockElector struct {
+	path          string
+	retryInterval time.Duration
+	onElected     func(ctx context.Context)
+}
+
+func (e *fileLockElector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.retryInterval)
+	defer ticker.Stop()
+
+	for {
+		f, err := os.OpenFile(e.path, os.O_CREATE|os.O_RDWR, 0600)
+		if err == nil {
+			err = lockFile(f)
+			if err == nil {
+				go e.onElected(ctx)
+
+				<-ctx.Done()
+				_ = f.Close()
+				return
+			}
+
+			_ = f.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Controller) isLeading() bool {
+	return c.elector == nil || atomic.LoadInt32(&c.leading) == 1
+}
+
+// LeadershipLost is closed when this replica is no longer the leader
+func (c *Controller) LeadershipLost() <-chan struct{} {
+	return c.leadershipLost
+}
+
+func (c *Controller) onElected(ctx context.Context) {
+	c.logger.I("started leading")
+	atomic.StoreInt32(&c.leading, 1)
+
+	c.startLeading()
+}
+
+func (c *Controller) onEjected() {
+	if c.ctx.Err() != nil {
+		// exiting
+		return
+	}
+
+	c.logger.I("leadership lost")
+	if atomic.CompareAndSwapInt32(&c.leading, 1, 0) {
+		close(c.leadershipLost)
+	}
+}
+
+// startLeading starts executing pending executions and cron jobs
+func (c *Controller) startLeading() {
+	err := c.syncPending()
+	if err != nil {
+		c.logger.I("failed to restore pending executions", log.Error(err))
+	}
+
+	go c.dispatch()
+
+	c.mu.Lock()
+	c.leaderStarted = true
+	if cronJob := c.settings().cronJob; cronJob != nil {
+		cronJob.Start()
+		atomic.StoreInt32(&c.cronRunning, 1)
+	}
+	c.mu.Unlock()
+
+	go func() {
+		c.logger.I("working on initial all repos check")
+
+		c.CheckAllRepos()
+
+		c.logger.I("all repos check finished")
+	}()
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/store"
+)
+
+func TestFileLockElector(t *testing.T) {
+	if !fileLockSupported {
+		t.Skip("file lock not supported")
+	}
+
+	config := &conf.LeaderElectionConfig{
+		File: &conf.FileLeaderElectionConfig{
+			Path:          filepath.Join(t.TempDir(), "leader.lock"),
+			RetryInterval: 10 * time.Millisecond,
+		},
+	}
+
+	var elected [2]int32
+	var cancels [2]context.CancelFunc
+	for i := range elected {
+		i := i
+		e, err := newLeaderElector(config, func(ctx context.Context) {
+			atomic.StoreInt32(&elected[i], 1)
+		}, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		var ctx context.Context
+		ctx, cancels[i] = context.WithCancel(context.Background())
+		defer cancels[i]()
+
+		go e.Run(ctx)
+		if i == 0 {
+			assert.Eventually(t, func() bool {
+				return atomic.LoadInt32(&elected[0]) == 1
+			}, 5*time.Second, 10*time.Millisecond)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	assert.EqualValues(t, 0, atomic.LoadInt32(&elected[1]))
+
+	// leader exited
+	cancels[0]()
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&elected[1]) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestController_Schedule_Follower(t *testing.T) {
+	queueStore, err := store.NewFileStore(&conf.FileStoreConfig{Dir: t.TempDir()})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	c := newTestController(t, &fakeExecutor{}, 0)
+	c.store = queueStore
+	c.elector = &fileLockElector{}
+	c.settings().delay = time.Hour
+	c.settings().addManager("/a", &fakeManager{}, &conf.PlatformConfig{})
+
+	// persisted only
+	assert.NoError(t, c.Schedule("/a", "foo/bar"))
+	assert.Empty(t, c.tq.Remains())
+
+	items, err := queueStore.List()
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+
+	atomic.StoreInt32(&c.leading, 1)
+	assert.NoError(t, c.syncPending())
+	assert.Len(t, c.tq.Remains(), 1)
+
+	// not queued twice
+	assert.NoError(t, c.Schedule("/a", "foo/baz"))
+	assert.NoError(t, c.syncPending())
+	if assert.Len(t, c.tq.Remains(), 1) {
+		assert.ElementsMatch(t, []string{"foo/bar", "foo/baz"}, c.tq.Remains()[0].Data.(*task).repos)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build windows || solaris
+// +build windows solaris
+
+package controller
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+const fileLockSupported = false
+
+func lockFile(f *os.File) error {
+	return fmt.Errorf("file lock is not supported on %s", runtime.GOOS)
+}
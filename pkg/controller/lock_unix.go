@@ -0,0 +1,16 @@
+//go:build !windows && !solaris
+// +build !windows,!solaris
+
+package controller
+
+import (
+	"os"
+	"syscall"
+)
+
+const fileLockSupported = true
+
+// lockFile acquires exclusive lock of f without blocking
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"arhat.dev/pkg/log"
+)
+
+// repoKey identifies a repo of a platform manager
+type repoKey struct {
+	manager string
+	repo    string
+}
+
+// FailedExecution is a repo failed permanently or exceeded max retry attempts
+type FailedExecution struct {
+	Manager  string    `json:"manager"`
+	Repo     string    `json:"repo"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failedAt"`
+}
+
+// FailedExecutions returns dead letters sorted by failure time
+func (c *Controller) FailedExecutions() []FailedExecution {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ret := make([]FailedExecution, 0, len(c.deadLetters))
+	for _, f := range c.deadLetters {
+		ret = append(ret, *f)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].FailedAt.Before(ret[j].FailedAt)
+	})
+
+	return ret
+}
+
+// backoff returns delay before the retry after n failed attempts
+func (c *Controller) backoff(n int) time.Duration {
+	retry := c.settings().retry
+	multiplier := retry.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(retry.InitialBackoff) * math.Pow(multiplier, float64(n-1))
+	if retry.MaxBackoff > 0 && delay > float64(retry.MaxBackoff) {
+		return retry.MaxBackoff
+	}
+
+	return time.Duration(delay)
+}
+
+// onExecutionSucceeded resets retry state of repos in the task
+func (c *Controller) onExecutionSucceeded(t *task) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, r := range t.repos {
+		k := repoKey{manager: t.manager, repo: r}
+		delete(c.attempts, k)
+		delete(c.deadLetters, k)
+	}
+}
+
+// onExecutionFailed reschedules repos in the task with backoff, repos exceeded max attempts
+// or failed permanently are moved to dead letters
+func (c *Controller) onExecutionFailed(logger log.Interface, t *task, err error, permanent bool) {
+	now := time.Now()
+	// attempts -> repos to retry
+	retries := make(map[int][]string)
+	var failed []string
+	retry := c.settings().retry
+
+	c.mu.Lock()
+	for _, r := range t.repos {
+		k := repoKey{manager: t.manager, repo: r}
+		n := c.attempts[k] + 1
+
+		if !permanent && (retry.MaxAttempts <= 0 || n < retry.MaxAttempts) {
+			c.attempts[k] = n
+			retries[n] = append(retries[n], r)
+			continue
+		}
+
+		delete(c.attempts, k)
+		failed = append(failed, r)
+		c.deadLetters[k] = &FailedExecution{
+			Manager:  t.manager,
+			Repo:     r,
+			Attempts: n,
+			Error:    err.Error(),
+			FailedAt: now,
+		}
+
+		logger.I("renovate execution failed, moved to dead letters",
+			log.String("repo", r),
+			log.Int("attempts", n),
+			log.Bool("permanent", permanent),
+		)
+	}
+	c.mu.Unlock()
+
+	c.unpersistFinished(t.manager, failed)
+
+	attempts := make([]int, 0, len(retries))
+	for n := range retries {
+		attempts = append(attempts, n)
+	}
+	// retries merged into the same task (token mode) wait for the longest backoff
+	sort.Ints(attempts)
+
+	// retries replace persisted pending executions with new due time
+	for _, n := range attempts {
+		delay := c.backoff(n)
+		logger.I("rescheduling failed renovate execution",
+			log.Strings("retry_repos", retries[n]),
+			log.Int("attempts", n),
+			log.Duration("backoff", delay),
+		)
+
+		err = c.scheduleReposAfter(t.manager, retries[n], delay)
+		if err != nil {
+			logger.I("failed to reschedule renovate execution", log.Error(err))
+		}
+	}
+}
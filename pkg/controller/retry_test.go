@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"arhat.dev/pkg/log"
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/constant"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+func TestController_backoff(t *testing.T) {
+	c := newTestController(t, &fakeExecutor{}, 0)
+
+	assert.Equal(t, time.Minute, c.backoff(1))
+	assert.Equal(t, 2*time.Minute, c.backoff(2))
+	assert.Equal(t, 4*time.Minute, c.backoff(3))
+	assert.Equal(t, 5*time.Minute, c.backoff(4))
+}
+
+func TestController_onExecutionFailed(t *testing.T) {
+	c := newTestController(t, &fakeExecutor{}, 0)
+	tk := &task{key: "/a", manager: "/a", repos: []string{"foo/bar", "foo/baz"}}
+	errFailed := errors.New("failed")
+
+	c.onExecutionFailed(log.NoOpLogger, tk, errFailed, false)
+	assert.Equal(t, 1, c.attempts[repoKey{manager: "/a", repo: "foo/bar"}])
+	retry, ok := c.tq.Find("/a")
+	if assert.True(t, ok) {
+		assert.Equal(t, []string{"foo/bar", "foo/baz"}, retry.(*task).repos)
+	}
+	_, _ = c.removeQueued("/a")
+
+	// foo/baz succeeded in another execution
+	c.onExecutionSucceeded(&task{manager: "/a", repos: []string{"foo/baz"}})
+	c.onExecutionFailed(log.NoOpLogger, tk, errFailed, false)
+	assert.Equal(t, 2, c.attempts[repoKey{manager: "/a", repo: "foo/bar"}])
+	assert.Equal(t, 1, c.attempts[repoKey{manager: "/a", repo: "foo/baz"}])
+	retry, ok = c.tq.Find("/a")
+	if assert.True(t, ok) {
+		// waits for the longest backoff
+		assert.ElementsMatch(t, []string{"foo/bar", "foo/baz"}, retry.(*task).repos)
+		assert.WithinDuration(t, time.Now().Add(c.backoff(2)), retry.(*task).dueAt, time.Second)
+	}
+
+	// max attempts reached
+	c.onExecutionFailed(log.NoOpLogger, &task{manager: "/a", repos: []string{"foo/bar"}}, errFailed, false)
+	assert.NotContains(t, c.attempts, repoKey{manager: "/a", repo: "foo/bar"})
+	failed := c.FailedExecutions()
+	if assert.Len(t, failed, 1) {
+		assert.Equal(t, "foo/bar", failed[0].Repo)
+		assert.Equal(t, 3, failed[0].Attempts)
+		assert.Equal(t, "failed", failed[0].Error)
+	}
+
+	// permanent error
+	c.onExecutionFailed(log.NoOpLogger, &task{manager: "/a", repos: []string{"foo/baz"}}, errFailed, true)
+	assert.Len(t, c.FailedExecutions(), 2)
+	assert.Empty(t, c.attempts)
+
+	c.onExecutionSucceeded(&task{manager: "/a", repos: []string{"foo/bar", "foo/baz"}})
+	assert.Empty(t, c.FailedExecutions())
+}
+
+func TestController_execute_deadlineExceeded(t *testing.T) {
+	exec := &fakeExecutor{
+		release: make(chan struct{}),
+		err:     fmt.Errorf("renovate job %q: %w", "foo", types.ErrExecutionDeadlineExceeded),
+	}
+	close(exec.release)
+
+	c := newTestController(t, exec, 0)
+	tk := &task{key: "/a", manager: "/a", repos: []string{"foo/bar"}}
+
+	// retried with backoff
+	c.execute(log.NoOpLogger, c.settings().executor, tk, types.ExecutionArgs{Repos: tk.repos})
+	assert.Equal(t, 1, c.attempts[repoKey{manager: "/a", repo: "foo/bar"}])
+	_, ok := c.tq.Find("/a")
+	assert.True(t, ok)
+	assert.Empty(t, c.FailedExecutions())
+}
+
+func TestController_onExecutionFailed_repoMode(t *testing.T) {
+	c := newTestController(t, &fakeExecutor{}, 0)
+	c.mode = constant.SchedulingModeRepo
+	tk := &task{key: "/a#batch-1", manager: "/a", repos: []string{"foo/bar", "foo/baz"}}
+
+	c.onExecutionFailed(log.NoOpLogger, tk, errors.New("failed"), false)
+	for _, r := range tk.repos {
+		retry, ok := c.tq.Find(repoTaskKey("/a", r))
+		if assert.True(t, ok) {
+			assert.Equal(t, []string{r}, retry.(*task).repos)
+		}
+	}
+
+	// retry state cleared when canceled
+	assert.Len(t, c.removePending(func(_, repo string) bool { return repo == "foo/bar" }), 1)
+	assert.NotContains(t, c.attempts, repoKey{manager: "/a", repo: "foo/bar"})
+	assert.Contains(t, c.attempts, repoKey{manager: "/a", repo: "foo/baz"})
+}
@@ -0,0 +1,313 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"arhat.dev/pkg/log"
+
+	"arhat.dev/renovate-server/pkg/constant"
+	"arhat.dev/renovate-server/pkg/metrics"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+// task is a pending renovate execution for repos of a platform manager
+type task struct {
+	// key of the task in queue
+	key string
+
+	// manager is the key of the platform manager (webhook path)
+	manager string
+	repos   []string
+
+	// scheduledAt is the time the oldest execution merged into the task was scheduled
+	scheduledAt time.Time
+	// dueAt is the time the task is due in queue
+	dueAt time.Time
+	// startedAt is the time the task started running
+	startedAt time.Time
+}
+
+// managerScheduler schedules executions on behalf of a platform manager
+type managerScheduler struct {
+	c       *Controller
+	manager string
+}
+
+func (s *managerScheduler) Schedule(repos ...string) error {
+	return s.c.Schedule(s.manager, repos...)
+}
+
+// ValidateConfig with the executor, only syntax of config files is checked by
+// platform managers if the executor is not a config validator
+func (s *managerScheduler) ValidateConfig(file string, data []byte) (string, error) {
+	v, ok := s.c.settings().executor.Executor.(types.ConfigValidator)
+	if !ok {
+		return "", nil
+	}
+
+	return v.ValidateConfig(file, data)
+}
+
+func (c *Controller) schedulerFor(manager string) types.Scheduler {
+	return &managerScheduler{c: c, manager: manager}
+}
+
+// Schedule renovate execution for repos of the platform manager after scheduling delay,
+// pending executions of the same manager (token mode) or the same repo (repo mode)
+// are merged and postponed
+func (c *Controller) Schedule(manager string, repos ...string) error {
+	return c.scheduleReposAfter(manager, repos, c.settings().delay)
+}
+
+// scheduleReposAfter queues repos after delay with task keys of the scheduling mode
+func (c *Controller) scheduleReposAfter(manager string, repos []string, delay time.Duration) error {
+	if c.mode != constant.SchedulingModeRepo {
+		return c.scheduleAfter(manager, manager, repos, delay)
+	}
+
+	for _, r := range repos {
+		err := c.scheduleAfter(repoTaskKey(manager, r), manager, []string{r}, delay)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// repoTaskKey is the task key of a single repo in repo mode
+func repoTaskKey(manager, repo string) string {
+	return manager + "#" + repo
+}
+
+// scheduleAfter queues task with key after delay, pending task with the same key is merged and postponed
+func (c *Controller) scheduleAfter(key, manager string, repos []string, delay time.Duration) error {
+	metrics.QueueItemsScheduled.WithLabelValues(manager).Add(float64(len(repos)))
+
+	dueAt := time.Now().Add(delay)
+	if !c.isLeading() {
+		// leader picks up persisted executions when syncing
+		return c.persistRepos(manager, repos, dueAt)
+	}
+
+	repos, merged, err := c.enqueue(key, manager, repos, dueAt)
+	if err != nil {
+		return err
+	}
+
+	if merged {
+		metrics.QueueItemsMerged.WithLabelValues(manager).Inc()
+	}
+
+	c.persist(manager, repos, dueAt)
+	return nil
+}
+
+// enqueue task with key due at specified time, returns repos of the task and whether
+// it's merged with a pending one
+func (c *Controller) enqueue(key, manager string, repos []string, dueAt time.Time) ([]string, bool, error) {
+	scheduledAt := time.Now()
+	oldTask, removed := c.removeQueued(key)
+	if removed {
+		repos = mergeRepos(repos, oldTask.repos)
+		scheduledAt = oldTask.scheduledAt
+	}
+
+	return repos, removed, c.offer(&task{
+		key:         key,
+		manager:     manager,
+		repos:       repos,
+		scheduledAt: scheduledAt,
+		dueAt:       dueAt,
+	})
+}
+
+// offer task to queue, the task is tracked as queued until taken or removed
+func (c *Controller) offer(t *task) error {
+	c.mu.Lock()
+	c.queued[t] = struct{}{}
+	c.mu.Unlock()
+
+	err := c.tq.OfferWithTime(t.key, t, t.dueAt)
+	if err != nil {
+		c.mu.Lock()
+		delete(c.queued, t)
+		c.mu.Unlock()
+	}
+
+	return err
+}
+
+// removeQueued removes task with key from queue if not due yet
+func (c *Controller) removeQueued(key string) (*task, bool) {
+	data, ok := c.tq.Remove(key)
+	if !ok {
+		return nil, false
+	}
+
+	t := data.(*task)
+	c.mu.Lock()
+	delete(c.queued, t)
+	c.mu.Unlock()
+
+	return t, true
+}
+
+// persist pending executions of repos to the queue store if configured
+func (c *Controller) persist(manager string, repos []string, dueAt time.Time) {
+	err := c.persistRepos(manager, repos, dueAt)
+	if err != nil {
+		c.logger.I("failed to persist pending executions", log.Error(err))
+	}
+}
+
+func (c *Controller) persistRepos(manager string, repos []string, dueAt time.Time) error {
+	if c.store == nil {
+		return nil
+	}
+
+	for _, r := range repos {
+		err := c.store.Put(types.PendingExecution{Manager: manager, Repo: r, DueAt: dueAt})
+		if err != nil {
+			return fmt.Errorf("failed to persist pending execution of %q: %w", r, err)
+		}
+	}
+
+	return nil
+}
+
+// unpersist removes pending executions of repos from the queue store if configured
+func (c *Controller) unpersist(manager string, repos []string) {
+	if c.store == nil {
+		return
+	}
+
+	for _, r := range repos {
+		err := c.store.Delete(manager, r)
+		if err != nil {
+			c.logger.I("failed to delete persisted pending execution", log.String("repo", r), log.Error(err))
+		}
+	}
+}
+
+// unpersistFinished removes persisted pending executions of repos after their execution
+// finished, repos scheduled again during the execution are kept
+func (c *Controller) unpersistFinished(manager string, repos []string) {
+	if c.store == nil || len(repos) == 0 {
+		return
+	}
+
+	pending := c.knownRepos(false)
+	var finished []string
+	for _, r := range repos {
+		if _, ok := pending[repoKey{manager: manager, repo: r}]; !ok {
+			finished = append(finished, r)
+		}
+	}
+
+	c.unpersist(manager, finished)
+}
+
+// syncPending queues pending executions in queue store not known to this controller
+// (persisted before restart or by other replicas)
+func (c *Controller) syncPending() error {
+	if c.store == nil {
+		return nil
+	}
+
+	items, err := c.store.List()
+	if err != nil {
+		return fmt.Errorf("failed to list pending executions: %w", err)
+	}
+
+	known := c.knownRepos(true)
+	count := 0
+	for _, item := range items {
+		if _, ok := c.settings().managers[item.Manager]; !ok {
+			// platform removed from config
+			c.unpersist(item.Manager, []string{item.Repo})
+			continue
+		}
+
+		if _, ok := known[repoKey{manager: item.Manager, repo: item.Repo}]; ok {
+			continue
+		}
+
+		key := item.Manager
+		if c.mode == constant.SchedulingModeRepo {
+			key = repoTaskKey(item.Manager, item.Repo)
+		}
+
+		_, _, err = c.enqueue(key, item.Manager, []string{item.Repo}, item.DueAt)
+		if err != nil {
+			return fmt.Errorf("failed to restore pending execution: %w", err)
+		}
+		count++
+	}
+
+	if count != 0 {
+		c.logger.I("restored pending executions", log.Int("count", count))
+	}
+
+	return nil
+}
+
+// knownRepos returns repos queued, batching or waiting in this controller, repos
+// running are included if running is true
+//
+// tasks are handed over before leaving previous state, states are checked in the
+// same order so no repo is missed when moving to next state
+func (c *Controller) knownRepos(running bool) map[repoKey]struct{} {
+	ret := make(map[repoKey]struct{})
+	add := func(t *task) {
+		for _, r := range t.repos {
+			ret[repoKey{manager: t.manager, repo: r}] = struct{}{}
+		}
+	}
+
+	c.mu.Lock()
+	for t := range c.queued {
+		add(t)
+	}
+	c.mu.Unlock()
+
+	if c.batcher != nil {
+		c.batcher.each(add)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, t := range c.waiting {
+		add(t)
+	}
+
+	if running {
+		for t := range c.running {
+			add(t)
+		}
+	}
+
+	return ret
+}
+
+// mergeRepos returns repos in a and repos in b but not in a
+func mergeRepos(a, b []string) []string {
+	ret := append(make([]string, 0, len(a)+len(b)), a...)
+	for _, r := range b {
+		found := false
+		for _, existing := range a {
+			if existing == r {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			ret = append(ret, r)
+		}
+	}
+
+	return ret
+}
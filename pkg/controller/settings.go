@@ -0,0 +1,314 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"arhat.dev/pkg/log"
+	"github.com/robfig/cron/v3"
+
+	"arhat.dev/renovate-server/pkg/azure"
+	"arhat.dev/renovate-server/pkg/bitbucketserver"
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/executor"
+	"arhat.dev/renovate-server/pkg/gitea"
+	"arhat.dev/renovate-server/pkg/github"
+	"arhat.dev/renovate-server/pkg/gitlab"
+	"arhat.dev/renovate-server/pkg/metrics"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+// settings of the controller reloadable with config, never modified once in use
+type settings struct {
+	config *conf.Config
+
+	managers      map[string]types.PlatformManager
+	managerLimits map[string]int
+	// handler serves webhooks of platform managers, metrics and health checks
+	handler http.Handler
+
+	executor *executorInstance
+
+	delay         time.Duration
+	maxConcurrent int
+	retry         conf.RetryConfig
+
+	cronJob *cron.Cron
+
+	// cancel stops platform managers
+	cancel context.CancelFunc
+	// requests and executions using platform managers of the settings
+	inflight *sync.WaitGroup
+}
+
+// executorInstance is shared by settings until executor config changed
+type executorInstance struct {
+	types.Executor
+
+	// name is the kind of the executor used as metrics label
+	name string
+	// cancel stops background jobs of the executor
+	cancel context.CancelFunc
+	// executions running with the executor
+	executions *sync.WaitGroup
+}
+
+func (s *settings) addManager(key string, mgr types.PlatformManager, config *conf.PlatformConfig) {
+	s.managers[key] = mgr
+	s.managerLimits[key] = config.MaxConcurrentExecutions
+}
+
+// acquireSettings returns current settings, platform managers of the settings are
+// not stopped until release called
+func (c *Controller) acquireSettings() (s *settings, release func()) {
+	// settings are replaced with lock held when reloading
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s = c.settings()
+	s.inflight.Add(1)
+	return s, s.inflight.Done
+}
+
+// newSettings creates settings with config, executor and cron scheduler of old
+// settings are reused if their config not changed
+func (c *Controller) newSettings(config *conf.Config, old *settings) (_ *settings, err error) {
+	s := &settings{
+		config: config,
+
+		managers:      make(map[string]types.PlatformManager),
+		managerLimits: make(map[string]int),
+
+		delay:         config.Server.Scheduling.Delay,
+		maxConcurrent: config.Server.Scheduling.MaxConcurrentExecutions,
+		retry:         config.Server.Scheduling.Retry,
+
+		inflight: new(sync.WaitGroup),
+	}
+
+	ctx, cancel := context.WithCancel(c.ctx)
+	s.cancel = cancel
+	defer func() {
+		if err != nil {
+			cancel()
+		}
+	}()
+
+	if old != nil && reflect.DeepEqual(old.config.Server.Executor, config.Server.Executor) {
+		s.executor = old.executor
+	} else {
+		s.executor, err = c.newExecutor(config)
+		if err != nil {
+			return nil, err
+		}
+
+		defer func() {
+			if err != nil {
+				s.executor.cancel()
+			}
+		}()
+	}
+
+	if old != nil &&
+		reflect.DeepEqual(old.config.Server.Scheduling.CronTabs, config.Server.Scheduling.CronTabs) &&
+		old.config.Server.Scheduling.Timezone == config.Server.Scheduling.Timezone {
+		s.cronJob = old.cronJob
+	} else {
+		s.cronJob, err = c.newCronJob(config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	platforms := []struct {
+		name       string
+		configs    []conf.PlatformConfig
+		newManager func(context.Context, *conf.PlatformConfig, types.Scheduler) (types.PlatformManager, error)
+	}{
+		{name: "github", configs: config.GitHub, newManager: github.NewManager},
+		{name: "gitlab", configs: config.GitLab, newManager: gitlab.NewManager},
+		{name: "gitea", configs: config.Gitea, newManager: gitea.NewManager},
+		{name: "bitbucket server", configs: config.BitbucketServer, newManager: bitbucketserver.NewManager},
+		{name: "azure", configs: config.Azure, newManager: azure.NewManager},
+	}
+
+	mux := http.NewServeMux()
+	for _, p := range platforms {
+		for i := range p.configs {
+			path := p.configs[i].Webhook.Path
+			if _, exists := s.managers[path]; exists {
+				return nil, fmt.Errorf("duplicate webhook path %q of %s manager, index %d", path, p.name, i)
+			}
+
+			mgr, err2 := p.newManager(ctx, &p.configs[i], c.schedulerFor(path))
+			if err2 != nil {
+				return nil, fmt.Errorf("failed to create %s manager, index %d: %w", p.name, i, err2)
+			}
+
+			s.addManager(path, mgr, &p.configs[i])
+			mux.Handle(path, metrics.InstrumentWebhook(path, mgr))
+		}
+	}
+
+	s.handler = mux
+
+	return s, nil
+}
+
+func (c *Controller) newExecutor(config *conf.Config) (*executorInstance, error) {
+	ctx, cancel := context.WithCancel(c.ctx)
+	ret := &executorInstance{
+		cancel:     cancel,
+		executions: new(sync.WaitGroup),
+	}
+
+	var err error
+	switch {
+	case config.Server.Executor.Kubernetes != nil:
+		ret.name = "kubernetes"
+		ret.Executor, err = executor.NewKubernetesExecutor(ctx, config.Server.Executor.Kubernetes)
+	case config.Server.Executor.Local != nil:
+		ret.name = "local"
+		ret.Executor, err = executor.NewLocalExecutor(ctx, config.Server.Executor.Local)
+	case config.Server.Executor.Docker != nil:
+		ret.name = "docker"
+		ret.Executor, err = executor.NewDockerExecutor(ctx, config.Server.Executor.Docker)
+	default:
+		err = fmt.Errorf("no executor provided")
+	}
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create executor: %w", err)
+	}
+
+	return ret, nil
+}
+
+// newCronJob creates cron scheduler checking all repos, nil if no cron tab configured
+func (c *Controller) newCronJob(config *conf.Config) (*cron.Cron, error) {
+	if len(config.Server.Scheduling.CronTabs) == 0 {
+		return nil, nil
+	}
+
+	location := time.UTC
+	if config.Server.Scheduling.Timezone != "" {
+		var err error
+		location, err = time.LoadLocation(config.Server.Scheduling.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse timezone: %w", err)
+		}
+	}
+
+	cronJob := cron.New(
+		cron.WithLocation(location),
+		cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)),
+		cron.WithParser(conf.CronTabParser),
+	)
+
+	for _, tab := range config.Server.Scheduling.CronTabs {
+		_, err := cronJob.AddFunc(tab, c.runCronJob)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron tab %q: %w", tab, err)
+		}
+	}
+
+	return cronJob, nil
+}
+
+func (c *Controller) runCronJob() {
+	c.logger.I("working on cron job")
+
+	startedAt := time.Now()
+	c.CheckAllRepos()
+	metrics.CronRunDuration.Observe(time.Since(startedAt).Seconds())
+
+	c.logger.I("cron job finished")
+}
+
+// Reload rebuilds platform managers, cron scheduler and executor with the new config,
+// pending and running executions are kept, current settings are kept if the new
+// config is invalid
+//
+// changes to listeners, admin api, queue store, leader election and scheduling mode
+// require restart
+func (c *Controller) Reload(config *conf.Config) error {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	old := c.settings()
+	if changed := restartRequired(old.config, config); len(changed) != 0 {
+		c.logger.I("config changes ignored until restart", log.Strings("changed", changed))
+	}
+
+	s, err := c.newSettings(config, old)
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	c.mu.Lock()
+	c.current.Store(s)
+	// credentials may have changed
+	c.authenticated = make(map[string]struct{})
+
+	if c.leaderStarted && s.cronJob != old.cronJob {
+		if old.cronJob != nil {
+			old.cronJob.Stop()
+		}
+
+		atomic.StoreInt32(&c.cronRunning, 0)
+		if s.cronJob != nil {
+			s.cronJob.Start()
+			atomic.StoreInt32(&c.cronRunning, 1)
+		}
+	}
+	c.mu.Unlock()
+
+	// no more requests or executions use old settings after replaced
+	go func() {
+		old.inflight.Wait()
+		old.cancel()
+	}()
+
+	if s.executor != old.executor {
+		go func() {
+			old.executor.executions.Wait()
+			old.executor.cancel()
+		}()
+	}
+
+	c.logger.I("config reloaded", log.Int("managers", len(s.managers)))
+
+	// concurrency limits may have changed
+	c.wake()
+	return nil
+}
+
+// restartRequired returns names of changed config not reloadable
+func restartRequired(old, config *conf.Config) []string {
+	var ret []string
+	for _, f := range []struct {
+		name     string
+		old, new interface{}
+	}{
+		{name: "server.log", old: old.Server.Log, new: config.Server.Log},
+		{name: "server.webhook", old: old.Server.Webhook, new: config.Server.Webhook},
+		{name: "server.admin", old: old.Server.Admin, new: config.Server.Admin},
+		{name: "server.monitoring", old: old.Server.Monitoring, new: config.Server.Monitoring},
+		{name: "server.store", old: old.Server.Store, new: config.Server.Store},
+		{name: "server.leaderElection", old: old.Server.LeaderElection, new: config.Server.LeaderElection},
+		{name: "server.scheduling.mode", old: old.Server.Scheduling.Mode, new: config.Server.Scheduling.Mode},
+		{name: "server.scheduling.batchWindow", old: old.Server.Scheduling.BatchWindow, new: config.Server.Scheduling.BatchWindow},
+		{name: "server.scheduling.maxBatchSize", old: old.Server.Scheduling.MaxBatchSize, new: config.Server.Scheduling.MaxBatchSize},
+	} {
+		if !reflect.DeepEqual(f.old, f.new) {
+			ret = append(ret, f.name)
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+)
+
+func newReloadTestConfig(t *testing.T, paths ...string) *conf.Config {
+	config := &conf.Config{}
+	config.Server.Executor.Local = &conf.LocalExecutorConfig{
+		Command: []string{"true"},
+		WorkDir: t.TempDir(),
+	}
+	config.Server.Scheduling.Delay = time.Hour
+
+	for _, p := range paths {
+		pc := conf.PlatformConfig{}
+		pc.API.OAuthToken = "test"
+		pc.Webhook.Path = p
+		config.Gitea = append(config.Gitea, pc)
+	}
+
+	return config
+}
+
+func TestController_Reload(t *testing.T) {
+	c := newTestController(t, &fakeExecutor{}, 0)
+
+	canceled := make(chan struct{})
+	old := c.settings()
+	old.executor.cancel = func() { close(canceled) }
+	old.delay = time.Hour
+
+	assert.NoError(t, c.Schedule("/a", "foo/bar"))
+
+	config := newReloadTestConfig(t, "/a", "/b")
+	config.Server.Scheduling.CronTabs = []string{"@every 1h"}
+	if !assert.NoError(t, c.Reload(config)) {
+		return
+	}
+
+	s := c.settings()
+	assert.Len(t, s.managers, 2)
+	assert.Equal(t, time.Hour, s.delay)
+	assert.NotNil(t, s.cronJob)
+	assert.Equal(t, "local", s.executor.name)
+	// pending executions are kept
+	assert.Len(t, c.tq.Remains(), 1)
+
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "old executor not stopped")
+	}
+
+	// executor and cron scheduler are reused if not changed
+	sameExecutor := newReloadTestConfig(t, "/a")
+	sameExecutor.Server.Executor = config.Server.Executor
+	sameExecutor.Server.Scheduling.CronTabs = config.Server.Scheduling.CronTabs
+	if !assert.NoError(t, c.Reload(sameExecutor)) {
+		return
+	}
+	assert.Len(t, c.settings().managers, 1)
+	assert.True(t, s.executor == c.settings().executor)
+	assert.True(t, s.cronJob == c.settings().cronJob)
+
+	// invalid config is rejected and current settings are kept
+	s = c.settings()
+	for _, invalid := range []*conf.Config{
+		newReloadTestConfig(t, "/a", "/a"),
+		func() *conf.Config {
+			config := newReloadTestConfig(t, "/a")
+			config.Server.Scheduling.CronTabs = []string{"invalid"}
+			return config
+		}(),
+	} {
+		assert.Error(t, c.Reload(invalid))
+		assert.True(t, s == c.settings())
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	old := &conf.Config{}
+	config := &conf.Config{}
+	config.Server.Scheduling.Delay = time.Minute
+	assert.Empty(t, restartRequired(old, config))
+
+	config.Server.Admin = &conf.AdminConfig{Listen: ":8081"}
+	config.Server.Scheduling.Mode = "repo"
+	assert.Equal(t, []string{"server.admin", "server.scheduling.mode"}, restartRequired(old, config))
+}
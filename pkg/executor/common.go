@@ -0,0 +1,99 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"arhat.dev/renovate-server/pkg/constant"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+type envVar struct {
+	name  string
+	value string
+}
+
+// renovateEnv generates environment variables shared by all executors
+//
+// api token is not included since executors may provide it in different ways
+func renovateEnv(args types.ExecutionArgs, logContext, baseDir string) []envVar {
+	return []envVar{
+		{name: "LOG_LEVEL", value: "debug"},
+		{name: "LOG_FORMAT", value: "json"},
+		{name: "LOG_CONTEXT", value: logContext},
+		{name: "RENOVATE_PLATFORM", value: strings.ToLower(args.Platform)},
+		{name: "RENOVATE_GIT_AUTHOR", value: fmt.Sprintf("%s <%s>", args.GitUser, args.GitEmail)},
+		{name: "RENOVATE_ONBOARDING", value: "false"},
+		{name: "RENOVATE_TRUST_LEVEL", value: "low"},
+		{name: "RENOVATE_BASE_DIR", value: baseDir},
+		{name: "RENOVATE_AUTODISCOVER", value: "false"},
+		{name: "RENOVATE_ENDPOINT", value: args.APIURL},
+		{name: "RENOVATE_BINARY_SOURCE", value: "global"},
+	}
+}
+
+// executionMeta generates name prefix, repo label value and repos description
+// for resources created to run renovate
+func executionMeta(repos []string) (namePrefix, repoLabel, reposValue string) {
+	if len(repos) == 1 {
+		return formatNamePrefix("renovate-", repos[0]),
+			sanitizeName(repos[0]),
+			repos[0]
+	}
+
+	reposJSON, _ := json.Marshal(repos)
+	return "renovate-batch-", "batch", string(reposJSON)
+}
+
+func formatNamePrefix(prefix, repo string) string {
+	// 253: max pod name length
+	// 11: length random suffix generated by kubernetes
+	// 1: suffix `-`
+	maxRepoSuffixLength := 253 - len(prefix) - 11 - 1
+
+	repoSuffix := strings.ToLower(repo)
+	for len(repoSuffix) > maxRepoSuffixLength {
+		parts := strings.SplitN(repoSuffix, "/", 2)
+		if len(parts) == 2 {
+			repoSuffix = parts[1]
+		} else {
+			repoSuffix = parts[0][:maxRepoSuffixLength]
+		}
+	}
+
+	return prefix + sanitizeName(repoSuffix) + "-"
+}
+
+// sanitizeName replaces characters not allowed in docker container names and
+// kubernetes object names (e.g. `/` and spaces) with `-`
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		default:
+			return '-'
+		}
+	}, strings.ToLower(name))
+}
+
+// envRenovateConfigData is the environment variable passing renovate config file
+// content to the config validator container
+const envRenovateConfigData = "RENOVATE_CONFIG_DATA"
+
+// configValidatorScript writes the config file (path in $0) with content in
+// envRenovateConfigData to a temporary dir, then runs the validator
+const configValidatorScript = `cd "$(mktemp -d)" && mkdir -p "$(dirname "$0")" && ` +
+	`printf '%s' "$` + envRenovateConfigData + `" > "$0" && exec "$@"`
+
+// configValidatorCommand generates command and args to validate renovate config file
+// in container, image entrypoint is overridden
+func configValidatorCommand(validator []string, file string) (command, args []string) {
+	if len(validator) == 0 {
+		validator = []string{constant.DefaultConfigValidatorCommand}
+	}
+
+	args = append(append([]string{file}, validator...), file)
+	return []string{"sh", "-c", configValidatorScript}, args
+}
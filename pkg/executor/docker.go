@@ -0,0 +1,526 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"arhat.dev/pkg/log"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/constant"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+const (
+	dockerPullAlways       = "always"
+	dockerPullIfNotPresent = "ifNotPresent"
+	dockerPullNever        = "never"
+
+	// interval of finished containers cleanup
+	dockerGCInterval = time.Minute
+)
+
+func NewDockerExecutor(ctx context.Context, config *conf.DockerExecutorConfig) (types.Executor, error) {
+	host := config.Host
+	if host == "" {
+		host = constant.DefaultDockerHost
+	}
+
+	hostURL, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host %q: %w", host, err)
+	}
+
+	if hostURL.Scheme != "unix" {
+		return nil, fmt.Errorf("unsupported docker host %q, only unix socket is supported", host)
+	}
+
+	var pullPolicy string
+	switch strings.ToLower(config.RenovateImagePullPolicy) {
+	case "always", "":
+		pullPolicy = dockerPullAlways
+	case "never":
+		pullPolicy = dockerPullNever
+	case "ifnotpresent", "if_not_present":
+		pullPolicy = dockerPullIfNotPresent
+	default:
+		return nil, fmt.Errorf("unsupported image pull policy: %s", config.RenovateImagePullPolicy)
+	}
+
+	image := config.RenovateImage
+	if image == "" {
+		image = constant.DefaultRenovateImage
+	}
+
+	baseURL := "http://docker"
+	if v := config.APIVersion; v != "" {
+		baseURL += "/" + v
+	}
+
+	sockPath := hostURL.Path
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	d := &DockerExecutor{
+		ctx: ctx,
+
+		logger: log.Log.WithName("docker-executor"),
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", sockPath)
+				},
+				MaxIdleConns:    10,
+				IdleConnTimeout: 90 * time.Second,
+			},
+		},
+		baseURL: baseURL,
+
+		image:      image,
+		pullPolicy: pullPolicy,
+
+		containerTTL:     config.ContainerTTL,
+		timeout:          config.Timeout,
+		validatorCommand: config.ConfigValidatorCommand,
+	}
+
+	go d.gcLoop()
+
+	return d, nil
+}
+
+type DockerExecutor struct {
+	ctx context.Context
+
+	logger  log.Interface
+	client  *http.Client
+	baseURL string
+
+	image      string
+	pullPolicy string
+
+	containerTTL     time.Duration
+	timeout          time.Duration
+	validatorCommand []string
+}
+
+type dockerContainerConfig struct {
+	Image      string            `json:"Image"`
+	Entrypoint []string          `json:"Entrypoint,omitempty"`
+	Cmd        []string          `json:"Cmd"`
+	Env        []string          `json:"Env"`
+	Labels     map[string]string `json:"Labels"`
+	Tty        bool              `json:"Tty"`
+	HostConfig dockerHostConfig  `json:"HostConfig"`
+}
+
+type dockerHostConfig struct {
+	CapDrop     []string `json:"CapDrop"`
+	SecurityOpt []string `json:"SecurityOpt"`
+}
+
+// CheckHealth pings docker engine
+func (d *DockerExecutor) CheckHealth(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.baseURL+"/_ping", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to ping docker engine: %w", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response %s when pinging docker engine", resp.Status)
+	}
+
+	return nil
+}
+
+func (d *DockerExecutor) Execute(args types.ExecutionArgs) error {
+	// defensive check to avoid unnecessary container
+	if len(args.Repos) == 0 {
+		return nil
+	}
+
+	err := d.ensureImage()
+	if err != nil {
+		return fmt.Errorf("failed to ensure renovate image: %w", dockerError(err))
+	}
+
+	namePrefix, repoLabel, reposValue := executionMeta(args.Repos)
+
+	var env []string
+	for _, e := range renovateEnv(args, "renovate-server:docker-executor", "/tmp/renovate") {
+		env = append(env, e.name+"="+e.value)
+	}
+	// there is no secret store in docker engine, token is visible when inspecting container
+	env = append(env, "RENOVATE_TOKEN="+args.APIToken)
+
+	var created struct {
+		ID string `json:"Id"`
+	}
+	err = d.do(http.MethodPost, "/containers/create",
+		url.Values{"name": {namePrefix + randomSuffix(5)}},
+		&dockerContainerConfig{
+			Image: d.image,
+			Cmd:   args.Repos,
+			Env:   env,
+			Labels: map[string]string{
+				constant.LabelRenovateRepo:       repoLabel,
+				constant.AnnotationRenovateRepos: reposValue,
+			},
+			Tty: true,
+			HostConfig: dockerHostConfig{
+				CapDrop:     []string{"ALL"},
+				SecurityOpt: []string{"no-new-privileges"},
+			},
+		},
+		&created,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create docker container: %w", dockerError(err))
+	}
+
+	err = d.do(http.MethodPost, "/containers/"+created.ID+"/start", nil, nil, nil)
+	if err != nil {
+		// container never started, gc will not remove it since it's not exited
+		_ = d.removeContainer(created.ID)
+		return fmt.Errorf("failed to start docker container: %w", dockerError(err))
+	}
+
+	exitCode, err := d.waitContainer(created.ID)
+	if err != nil {
+		return err
+	}
+
+	if exitCode != 0 {
+		return fmt.Errorf("renovate container %q exited with code %d", created.ID, exitCode)
+	}
+
+	return nil
+}
+
+// waitContainer waits until the container exits and returns its exit code, the container
+// is killed if still running after timeout
+func (d *DockerExecutor) waitContainer(id string) (int, error) {
+	var killed uint32
+	if d.timeout > 0 {
+		timer := time.AfterFunc(d.timeout, func() {
+			atomic.StoreUint32(&killed, 1)
+
+			err := d.do(http.MethodPost, "/containers/"+id+"/kill", nil, nil, nil)
+			if err != nil {
+				d.logger.I("failed to kill timed out container", log.String("id", id), log.Error(err))
+			}
+		})
+		defer timer.Stop()
+	}
+
+	var result struct {
+		StatusCode int `json:"StatusCode"`
+		Error      *struct {
+			Message string `json:"Message"`
+		} `json:"Error"`
+	}
+	err := d.do(http.MethodPost, "/containers/"+id+"/wait", nil, nil, &result)
+	switch {
+	case err != nil:
+		return 0, fmt.Errorf("failed to wait docker container: %w", err)
+	case result.Error != nil:
+		return 0, fmt.Errorf("failed to wait docker container: %s", result.Error.Message)
+	case atomic.LoadUint32(&killed) == 1:
+		return 0, fmt.Errorf("container %q killed after %v: %w", id, d.timeout, types.ErrExecutionDeadlineExceeded)
+	}
+
+	return result.StatusCode, nil
+}
+
+// ValidateConfig runs config validator in a container of the renovate image
+func (d *DockerExecutor) ValidateConfig(file string, data []byte) (string, error) {
+	err := d.ensureImage()
+	if err != nil {
+		return "", fmt.Errorf("failed to ensure renovate image: %w", err)
+	}
+
+	command, args := configValidatorCommand(d.validatorCommand, file)
+
+	var created struct {
+		ID string `json:"Id"`
+	}
+	err = d.do(http.MethodPost, "/containers/create",
+		url.Values{"name": {"renovate-config-" + randomSuffix(5)}},
+		&dockerContainerConfig{
+			Image:      d.image,
+			Entrypoint: command,
+			Cmd:        args,
+			Env:        []string{envRenovateConfigData + "=" + string(data)},
+			Labels: map[string]string{
+				constant.LabelRenovateConfigValidation: "true",
+			},
+			Tty: true,
+			HostConfig: dockerHostConfig{
+				CapDrop:     []string{"ALL"},
+				SecurityOpt: []string{"no-new-privileges"},
+			},
+		},
+		&created,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create docker container: %w", err)
+	}
+
+	defer func() {
+		err2 := d.removeContainer(created.ID)
+		if err2 != nil {
+			d.logger.I("failed to remove config validator container", log.String("id", created.ID), log.Error(err2))
+		}
+	}()
+
+	err = d.do(http.MethodPost, "/containers/"+created.ID+"/start", nil, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to start docker container: %w", err)
+	}
+
+	exitCode, err := d.waitContainer(created.ID)
+	switch {
+	case err != nil:
+		return "", err
+	case exitCode == 0:
+		return "", nil
+	}
+
+	// validator exits with non-zero code when config is invalid
+	resp, err := d.request(http.MethodGet, "/containers/"+created.ID+"/logs",
+		url.Values{"stdout": {"true"}, "stderr": {"true"}}, nil,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to get config validator output: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	output := &tailBuffer{max: maxCapturedOutputSize}
+	_, err = io.Copy(output, resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read config validator output: %w", err)
+	}
+
+	if len(output.buf) == 0 {
+		return fmt.Sprintf("config validator exited with code %d", exitCode), nil
+	}
+
+	return output.String(), nil
+}
+
+func (d *DockerExecutor) ensureImage() error {
+	switch d.pullPolicy {
+	case dockerPullNever:
+		return nil
+	case dockerPullIfNotPresent:
+		err := d.do(http.MethodGet, "/images/"+d.image+"/json", nil, nil, nil)
+		if err == nil {
+			return nil
+		}
+
+		if apiErr, ok := err.(*dockerAPIError); !ok || apiErr.status != http.StatusNotFound {
+			return err
+		}
+	}
+
+	resp, err := d.request(http.MethodPost, "/images/create", url.Values{"fromImage": {d.image}}, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	// progress messages are streamed, errors during pulling are reported in the stream
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &msg) == nil && msg.Error != "" {
+			return fmt.Errorf("failed to pull image %q: %s", d.image, msg.Error)
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (d *DockerExecutor) gcLoop() {
+	ticker := time.NewTicker(dockerGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+			err := d.removeExpiredContainers()
+			if err != nil {
+				d.logger.I("failed to remove expired containers", log.Error(err))
+			}
+		}
+	}
+}
+
+// removeExpiredContainers deletes renovate containers finished longer than container ttl
+func (d *DockerExecutor) removeExpiredContainers() error {
+	filters, _ := json.Marshal(map[string][]string{
+		"label":  {constant.LabelRenovateRepo},
+		"status": {"exited", "dead"},
+	})
+
+	var containers []struct {
+		ID string `json:"Id"`
+	}
+	err := d.do(http.MethodGet, "/containers/json",
+		url.Values{"all": {"true"}, "filters": {string(filters)}}, nil, &containers,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	for _, c := range containers {
+		var info struct {
+			State struct {
+				FinishedAt time.Time `json:"FinishedAt"`
+			} `json:"State"`
+		}
+
+		err = d.do(http.MethodGet, "/containers/"+c.ID+"/json", nil, nil, &info)
+		if err != nil {
+			d.logger.I("failed to inspect container", log.String("id", c.ID), log.Error(err))
+			continue
+		}
+
+		if time.Since(info.State.FinishedAt) < d.containerTTL {
+			continue
+		}
+
+		d.logger.D("removing expired container", log.String("id", c.ID))
+		err = d.removeContainer(c.ID)
+		if err != nil {
+			d.logger.I("failed to remove container", log.String("id", c.ID), log.Error(err))
+		}
+	}
+
+	return nil
+}
+
+func (d *DockerExecutor) removeContainer(id string) error {
+	return d.do(http.MethodDelete, "/containers/"+id, url.Values{"v": {"true"}, "force": {"true"}}, nil, nil)
+}
+
+type dockerAPIError struct {
+	status  int
+	message string
+}
+
+func (e *dockerAPIError) Error() string {
+	return fmt.Sprintf("docker api error (%d): %s", e.status, e.message)
+}
+
+// dockerError marks client errors of docker api as permanent, except conflicts and rate limiting
+func dockerError(err error) error {
+	var apiErr *dockerAPIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+
+	switch {
+	case apiErr.status == http.StatusConflict, apiErr.status == http.StatusTooManyRequests:
+		return err
+	case apiErr.status >= http.StatusBadRequest && apiErr.status < http.StatusInternalServerError:
+		return types.Permanent(err)
+	default:
+		return err
+	}
+}
+
+func (d *DockerExecutor) request(method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	u := d.baseURL + path
+	if len(query) != 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(d.ctx, method, u, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		defer func() { _ = resp.Body.Close() }()
+
+		var msg struct {
+			Message string `json:"message"`
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		if json.Unmarshal(data, &msg) != nil {
+			msg.Message = string(data)
+		}
+
+		return nil, &dockerAPIError{status: resp.StatusCode, message: msg.Message}
+	}
+
+	return resp, nil
+}
+
+// do sends request to docker engine and decodes json response into out if not nil
+func (d *DockerExecutor) do(method, path string, query url.Values, body, out interface{}) error {
+	resp, err := d.request(method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if out == nil {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func randomSuffix(n int) string {
+	const chars = "bcdfghjklmnpqrstvwxz2456789"
+
+	b := make([]byte, n)
+	for i := range b {
+		// nolint:gosec
+		b[i] = chars[rand.Intn(len(chars))]
+	}
+
+	return string(b)
+}
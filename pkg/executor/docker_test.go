@@ -0,0 +1,231 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/constant"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+type fakeDockerEngine struct {
+	mu       sync.Mutex
+	requests []string
+	created  dockerContainerConfig
+	name     string
+	removed  []string
+
+	finishedAt time.Time
+	exitCode   int
+	// container keeps running until killed if not nil
+	killCh chan struct{}
+}
+
+func (f *fakeDockerEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1.41/images/create":
+		_, _ = w.Write([]byte(`{"status":"Pulling from renovate/renovate"}` + "\n"))
+	case r.Method == http.MethodPost && r.URL.Path == "/v1.41/containers/create":
+		f.name = r.URL.Query().Get("name")
+		_ = json.NewDecoder(r.Body).Decode(&f.created)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"Id":"c1","Warnings":[]}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/v1.41/containers/c1/start":
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && r.URL.Path == "/v1.41/containers/c1/wait":
+		if ch := f.killCh; ch != nil {
+			f.mu.Unlock()
+			<-ch
+			f.mu.Lock()
+		}
+
+		_, _ = fmt.Fprintf(w, `{"StatusCode":%d}`, f.exitCode)
+	case r.Method == http.MethodPost && r.URL.Path == "/v1.41/containers/c1/kill":
+		f.exitCode = 137
+		close(f.killCh)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && r.URL.Path == "/v1.41/containers/c1/logs":
+		_, _ = w.Write([]byte("invalid config"))
+	case r.Method == http.MethodGet && r.URL.Path == "/v1.41/containers/json":
+		_, _ = w.Write([]byte(`[{"Id":"c1"},{"Id":"c2"}]`))
+	case r.Method == http.MethodGet && r.URL.Path == "/v1.41/containers/c1/json":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"State": map[string]interface{}{"FinishedAt": f.finishedAt},
+		})
+	case r.Method == http.MethodGet && r.URL.Path == "/v1.41/containers/c2/json":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"State": map[string]interface{}{"FinishedAt": time.Now()},
+		})
+	case r.Method == http.MethodDelete:
+		f.removed = append(f.removed, strings.TrimPrefix(r.URL.Path, "/v1.41/containers/"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"message":"not found"}`))
+	}
+}
+
+func newFakeDockerExecutor(t *testing.T, engine *fakeDockerEngine) *DockerExecutor {
+	sock := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", sock)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	srv := httptest.NewUnstartedServer(engine)
+	srv.Listener = l
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	e, err := NewDockerExecutor(ctx, &conf.DockerExecutorConfig{
+		Host:         "unix://" + sock,
+		APIVersion:   "v1.41",
+		ContainerTTL: time.Hour,
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return e.(*DockerExecutor)
+}
+
+func TestDockerExecutor_Execute(t *testing.T) {
+	engine := &fakeDockerEngine{}
+	e := newFakeDockerExecutor(t, engine)
+
+	err := e.Execute(types.ExecutionArgs{
+		Platform: "gitlab",
+		APIURL:   "https://gitlab.com/",
+		APIToken: "foo",
+		Repos:    []string{"foo/bar"},
+		GitUser:  "bot",
+		GitEmail: "bot@example.com",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{
+		"POST /v1.41/images/create",
+		"POST /v1.41/containers/create",
+		"POST /v1.41/containers/c1/start",
+		"POST /v1.41/containers/c1/wait",
+	}, engine.requests)
+
+	assert.True(t, strings.HasPrefix(engine.name, "renovate-foo-bar-"))
+	assert.Equal(t, constant.DefaultRenovateImage, engine.created.Image)
+	assert.Equal(t, []string{"foo/bar"}, engine.created.Cmd)
+	assert.Equal(t, "foo-bar", engine.created.Labels[constant.LabelRenovateRepo])
+	assert.Contains(t, engine.created.Env, "RENOVATE_PLATFORM=gitlab")
+	assert.Contains(t, engine.created.Env, "RENOVATE_ENDPOINT=https://gitlab.com/")
+	assert.Contains(t, engine.created.Env, "RENOVATE_TOKEN=foo")
+	assert.Contains(t, engine.created.Env, "LOG_CONTEXT=renovate-server:docker-executor")
+}
+
+func TestDockerExecutor_Execute_Timeout(t *testing.T) {
+	engine := &fakeDockerEngine{killCh: make(chan struct{})}
+	e := newFakeDockerExecutor(t, engine)
+	e.timeout = 100 * time.Millisecond
+
+	err := e.Execute(types.ExecutionArgs{Platform: "gitlab", Repos: []string{"foo/bar"}})
+	assert.ErrorIs(t, err, types.ErrExecutionDeadlineExceeded)
+	assert.Contains(t, engine.requests, "POST /v1.41/containers/c1/kill")
+}
+
+func TestDockerExecutor_ValidateConfig(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		exitCode int
+		problem  string
+		requests []string
+	}{
+		{
+			name: "Valid",
+			requests: []string{
+				"POST /v1.41/images/create",
+				"POST /v1.41/containers/create",
+				"POST /v1.41/containers/c1/start",
+				"POST /v1.41/containers/c1/wait",
+				"DELETE /v1.41/containers/c1",
+			},
+		},
+		{
+			name:     "Invalid",
+			exitCode: 1,
+			problem:  "invalid config",
+			requests: []string{
+				"POST /v1.41/images/create",
+				"POST /v1.41/containers/create",
+				"POST /v1.41/containers/c1/start",
+				"POST /v1.41/containers/c1/wait",
+				"GET /v1.41/containers/c1/logs",
+				"DELETE /v1.41/containers/c1",
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			engine := &fakeDockerEngine{exitCode: test.exitCode}
+			e := newFakeDockerExecutor(t, engine)
+
+			problem, err := e.ValidateConfig(".github/renovate.json", []byte(`{}`))
+			assert.NoError(t, err)
+			assert.Equal(t, test.problem, problem)
+			assert.Equal(t, test.requests, engine.requests)
+
+			assert.True(t, strings.HasPrefix(engine.name, "renovate-config-"))
+			assert.Equal(t, []string{"sh", "-c", configValidatorScript}, engine.created.Entrypoint)
+			assert.Equal(t, []string{
+				".github/renovate.json", constant.DefaultConfigValidatorCommand, ".github/renovate.json",
+			}, engine.created.Cmd)
+			assert.Equal(t, []string{envRenovateConfigData + "={}"}, engine.created.Env)
+		})
+	}
+}
+
+func TestDockerExecutor_RemoveExpiredContainers(t *testing.T) {
+	engine := &fakeDockerEngine{finishedAt: time.Now().Add(-2 * time.Hour)}
+	e := newFakeDockerExecutor(t, engine)
+
+	assert.NoError(t, e.removeExpiredContainers())
+	assert.Equal(t, []string{"c1"}, engine.removed)
+}
+
+func TestDockerError(t *testing.T) {
+	tests := []struct {
+		status    int
+		permanent bool
+	}{
+		{status: http.StatusUnauthorized, permanent: true},
+		{status: http.StatusNotFound, permanent: true},
+		{status: http.StatusConflict, permanent: false},
+		{status: http.StatusTooManyRequests, permanent: false},
+		{status: http.StatusInternalServerError, permanent: false},
+	}
+
+	for _, test := range tests {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			err := dockerError(&dockerAPIError{status: test.status})
+			assert.Equal(t, test.permanent, types.IsPermanent(err))
+		})
+	}
+}
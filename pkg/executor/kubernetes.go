@@ -1,32 +1,63 @@
 package executor
 
 import (
+	"bytes"
 	"context"
+	cryptorand "crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	"arhat.dev/pkg/envhelper"
-	"arhat.dev/pkg/hashhelper"
+	"arhat.dev/pkg/log"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
 	clientbatchv1 "k8s.io/client-go/kubernetes/typed/batch/v1"
 	clientcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
 
 	"arhat.dev/renovate-server/pkg/conf"
 	"arhat.dev/renovate-server/pkg/constant"
 	"arhat.dev/renovate-server/pkg/types"
 )
 
+// instanceID identifies jobs created by this process, it's shared by executors
+// recreated on config reload, so jobs of previous executors are still owned
+var instanceID = newInstanceID()
+
+func newInstanceID() string {
+	b := make([]byte, 8)
+	_, err := cryptorand.Read(b)
+	if err != nil {
+		panic(fmt.Errorf("failed to generate instance id: %w", err))
+	}
+
+	return hex.EncodeToString(b)
+}
+
 func NewKubernetesExecutor(ctx context.Context, config *conf.KubernetesExecutorConfig) (types.Executor, error) {
 	client, _, err := config.KubeClient.NewKubeClient(nil, true)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
 	}
 
+	return newKubernetesExecutor(ctx, config, client)
+}
+
+func newKubernetesExecutor(
+	ctx context.Context,
+	config *conf.KubernetesExecutorConfig,
+	client kubernetes.Interface,
+) (*KubernetesExecutor, error) {
 	var pullPolicy corev1.PullPolicy
 	switch strings.ToLower(config.RenovateImagePullPolicy) {
 	case "always":
@@ -43,29 +74,125 @@ func NewKubernetesExecutor(ctx context.Context, config *conf.KubernetesExecutorC
 		image = constant.DefaultRenovateImage
 	}
 
-	return &KubernetesExecutor{
+	var podTemplatePatch []byte
+	if len(config.PodTemplate) != 0 {
+		var err error
+		podTemplatePatch, err = json.Marshal(config.PodTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pod template: %w", err)
+		}
+
+		// validate early instead of failing every execution
+		dec := json.NewDecoder(bytes.NewReader(podTemplatePatch))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(new(corev1.PodTemplateSpec))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pod template: %w", err)
+		}
+
+		_, err = applyPodTemplatePatch(corev1.PodTemplateSpec{}, podTemplatePatch)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pod template: %w", err)
+		}
+	}
+
+	var activeDeadlineSeconds *int64
+	if config.JobActiveDeadline > 0 {
+		seconds := int64(config.JobActiveDeadline.Seconds())
+		activeDeadlineSeconds = &seconds
+	}
+
+	var renovateCache *kubernetesCache
+	if config.Cache != nil {
+		var err error
+		renovateCache, err = newKubernetesCache(config.Cache, client)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache config: %w", err)
+		}
+	}
+
+	jobClient := client.BatchV1().Jobs(envhelper.ThisPodNS())
+	k := &KubernetesExecutor{
 		ctx: ctx,
 
+		logger: log.Log.WithName("kubernetes-executor"),
+
 		image:           image,
 		imagePullPolicy: pullPolicy,
 
 		secretClient: client.CoreV1().Secrets(envhelper.ThisPodNS()),
-		jobClient:    client.BatchV1().Jobs(envhelper.ThisPodNS()),
+		podClient:    client.CoreV1().Pods(envhelper.ThisPodNS()),
+		jobClient:    jobClient,
+
+		jobTTLSeconds:         int32(config.JobTTL.Seconds()),
+		activeDeadlineSeconds: activeDeadlineSeconds,
+		podTemplatePatch:      podTemplatePatch,
+		cache:                 renovateCache,
+		validatorCommand:      config.ConfigValidatorCommand,
+		instanceID:            instanceID,
+
+		jobWaiters: make(map[string]chan error),
+		mu:         new(sync.Mutex),
+	}
 
-		jobTTLSeconds: int32(config.JobTTL.Seconds()),
-	}, nil
+	// watch all renovate jobs to report execution result
+	k.jobInformer = cache.NewSharedIndexInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = constant.LabelRenovateRepo
+			return jobClient.List(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = constant.LabelRenovateRepo
+			return jobClient.Watch(ctx, options)
+		},
+	}, &batchv1.Job{}, 0, cache.Indexers{})
+
+	k.jobInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: k.onJobUpdated,
+		UpdateFunc: func(_, newObj interface{}) {
+			k.onJobUpdated(newObj)
+		},
+		DeleteFunc: k.onJobDeleted,
+	})
+
+	go k.jobInformer.Run(ctx.Done())
+
+	return k, nil
 }
 
 type KubernetesExecutor struct {
 	ctx context.Context
 
+	logger log.Interface
+
 	image           string
 	imagePullPolicy corev1.PullPolicy
 
 	secretClient clientcorev1.SecretInterface
+	podClient    clientcorev1.PodInterface
 	jobClient    clientbatchv1.JobInterface
+	jobInformer  cache.SharedIndexInformer
 
-	jobTTLSeconds int32
+	jobTTLSeconds         int32
+	activeDeadlineSeconds *int64
+	podTemplatePatch      []byte
+	cache                 *kubernetesCache
+	validatorCommand      []string
+	instanceID            string
+
+	// job name -> channel to deliver job result
+	jobWaiters map[string]chan error
+	mu         *sync.Mutex
+}
+
+// CheckHealth checks jobs in this namespace are accessible
+func (k *KubernetesExecutor) CheckHealth(ctx context.Context) error {
+	_, err := k.jobClient.List(ctx, metav1.ListOptions{Limit: 1})
+	if err != nil {
+		return fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	return nil
 }
 
 func (k *KubernetesExecutor) Execute(args types.ExecutionArgs) error {
@@ -79,58 +206,105 @@ func (k *KubernetesExecutor) Execute(args types.ExecutionArgs) error {
 	zeroP := int64(0)
 	oneP := int32(1)
 
-	apiTokenBytes := []byte(args.APIToken)
-	secretName := fmt.Sprintf("renovate-%s", hex.EncodeToString(hashhelper.MD5Sum(apiTokenBytes)))
-
-	_, err := k.secretClient.Get(k.ctx, secretName, metav1.GetOptions{})
+	// one secret for each job, deleted with the job, tokens of github apps expire
+	// in an hour
+	secret, err := k.secretClient.Create(k.ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "renovate-token-",
+			Namespace:    envhelper.ThisPodNS(),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"RENOVATE_TOKEN": []byte(args.APIToken),
+		},
+	}, metav1.CreateOptions{})
 	if err != nil {
-		if !kubeerrors.IsNotFound(err) {
-			return fmt.Errorf("failed to check required secret: %w", err)
+		return fmt.Errorf("failed to create required secret: %w", kubeError(err))
+	}
+
+	secretOwned := false
+	defer func() {
+		if secretOwned {
+			return
 		}
 
-		_, err = k.secretClient.Create(k.ctx, &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      secretName,
-				Namespace: envhelper.ThisPodNS(),
-			},
-			Type: corev1.SecretTypeOpaque,
-			Data: map[string][]byte{
-				"RENOVATE_TOKEN": apiTokenBytes,
-			},
-		}, metav1.CreateOptions{})
-		if err != nil {
-			return fmt.Errorf("failed to create required secret: %w", err)
+		err2 := k.secretClient.Delete(k.ctx, secret.Name, metav1.DeleteOptions{})
+		if err2 != nil && !kubeerrors.IsNotFound(err2) {
+			k.logger.I("failed to delete unused secret", log.String("secret", secret.Name), log.Error(err2))
 		}
+	}()
+
+	genName, repoLabel, reposValue := executionMeta(args.Repos)
+	annotations := map[string]string{
+		constant.AnnotationRenovateRepos:    reposValue,
+		constant.AnnotationRenovateEndpoint: args.APIURL,
+		constant.AnnotationRenovateManager:  args.Manager,
 	}
+	labels := map[string]string{
+		constant.LabelRenovateRepo:     repoLabel,
+		constant.LabelRenovateInstance: k.instanceID,
+	}
+
+	var (
+		baseDir      = constant.DefaultRenovateBaseDir
+		volumes      []corev1.Volume
+		volumeMounts []corev1.VolumeMount
+		extraEnv     []corev1.EnvVar
+		fsGroup      *int64
+	)
+	if k.cache != nil {
+		key := cacheKey(args.Repos)
+		unlock, err2 := k.cache.lock(k.ctx, key)
+		if err2 != nil {
+			return fmt.Errorf("failed to lock renovate cache: %w", err2)
+		}
+		defer unlock()
 
-	genName := "renovate-batch-"
-	repoLabel := "batch"
-	annotations := make(map[string]string)
-	if len(args.Repos) == 1 {
-		genName = formatNamePrefix("renovate-", args.Repos[0])
-		repoLabel = strings.ToLower(strings.ReplaceAll(args.Repos[0], "/", "-"))
-		annotations[constant.AnnotationRenovateRepos] = args.Repos[0]
-	} else {
-		reposJSON, _ := json.Marshal(args.Repos)
-		annotations[constant.AnnotationRenovateRepos] = string(reposJSON)
+		// job using the cache may be created before restart
+		if k.isCacheInUse(key) {
+			return fmt.Errorf("renovate cache %q is being used by another job", key)
+		}
+
+		vol, mount, err2 := k.cache.prepare(k.ctx, key, repoLabel, reposValue)
+		if err2 != nil {
+			return fmt.Errorf("failed to prepare renovate cache: %w", err2)
+		}
+
+		labels[constant.LabelRenovateCache] = key
+		baseDir = k.cache.mountPath
+		volumes = append(volumes, vol)
+		volumeMounts = append(volumeMounts, mount)
+		extraEnv = append(extraEnv, corev1.EnvVar{Name: "RENOVATE_CACHE_DIR", Value: k.cache.cacheDir()})
+		fsGroup = &k.cache.fsGroup
+	}
+
+	var env []corev1.EnvVar
+	for _, e := range renovateEnv(args, "renovate-server:kubernetes-executor", baseDir) {
+		env = append(env, corev1.EnvVar{Name: e.name, Value: e.value})
+	}
+	env = append(env, extraEnv...)
+
+	podLabels := make(map[string]string, len(labels))
+	for name, value := range labels {
+		podLabels[name] = value
 	}
 
 	job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: genName,
 			Namespace:    envhelper.ThisPodNS(),
+			Labels:       labels,
+			Annotations:  annotations,
 		},
 		Spec: batchv1.JobSpec{
 			Parallelism:             &oneP,
 			Completions:             &oneP,
-			ActiveDeadlineSeconds:   nil,
+			ActiveDeadlineSeconds:   k.activeDeadlineSeconds,
 			BackoffLimit:            &oneP,
 			TTLSecondsAfterFinished: &k.jobTTLSeconds,
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						constant.LabelRenovateRepo: repoLabel,
-					},
+					Labels:      podLabels,
 					Annotations: annotations,
 				},
 				Spec: corev1.PodSpec{
@@ -145,60 +319,12 @@ func (k *KubernetesExecutor) Execute(args types.ExecutionArgs) error {
 						EnvFrom: []corev1.EnvFromSource{{
 							SecretRef: &corev1.SecretEnvSource{
 								LocalObjectReference: corev1.LocalObjectReference{
-									Name: secretName,
+									Name: secret.Name,
 								},
 							},
 						}},
-						Env: []corev1.EnvVar{
-							{
-								Name:  "LOG_LEVEL",
-								Value: "debug",
-							},
-							{
-								Name:  "LOG_FORMAT",
-								Value: "json",
-							},
-							{
-								Name:  "LOG_CONTEXT",
-								Value: "renovate-server:kubernetes-executor",
-							},
-							{
-								Name:  "RENOVATE_PLATFORM",
-								Value: strings.ToLower(args.Platform),
-							},
-							{
-								Name:  "RENOVATE_GIT_AUTHOR",
-								Value: fmt.Sprintf("%s <%s>", args.GitUser, args.GitEmail),
-							},
-							{
-								Name:  "RENOVATE_ONBOARDING",
-								Value: "false",
-							},
-							{
-								Name:  "RENOVATE_ONBOARDING",
-								Value: "false",
-							},
-							{
-								Name:  "RENOVATE_TRUST_LEVEL",
-								Value: "low",
-							},
-							{
-								Name:  "RENOVATE_BASE_DIR",
-								Value: "/tmp/renovate",
-							},
-							{
-								Name:  "RENOVATE_AUTODISCOVER",
-								Value: "false",
-							},
-							{
-								Name:  "RENOVATE_ENDPOINT",
-								Value: args.APIURL,
-							},
-							{
-								Name:  "RENOVATE_BINARY_SOURCE",
-								Value: "global",
-							},
-						},
+						Env:          env,
+						VolumeMounts: volumeMounts,
 						SecurityContext: &corev1.SecurityContext{
 							Capabilities: &corev1.Capabilities{
 								Add:  nil,
@@ -215,6 +341,7 @@ func (k *KubernetesExecutor) Execute(args types.ExecutionArgs) error {
 							ProcMount:                nil,
 						},
 					}},
+					Volumes:                       volumes,
 					RestartPolicy:                 corev1.RestartPolicyNever,
 					TerminationGracePeriodSeconds: &zeroP,
 					ActiveDeadlineSeconds:         nil,
@@ -226,7 +353,7 @@ func (k *KubernetesExecutor) Execute(args types.ExecutionArgs) error {
 						RunAsGroup:          nil,
 						RunAsNonRoot:        &trueP,
 						SupplementalGroups:  nil,
-						FSGroup:             nil,
+						FSGroup:             fsGroup,
 						Sysctls:             nil,
 						FSGroupChangePolicy: nil,
 					},
@@ -235,29 +362,349 @@ func (k *KubernetesExecutor) Execute(args types.ExecutionArgs) error {
 		},
 	}
 
-	_, err = k.jobClient.Create(k.ctx, job, metav1.CreateOptions{})
+	if len(k.podTemplatePatch) != 0 {
+		job.Spec.Template, err = applyPodTemplatePatch(job.Spec.Template, k.podTemplatePatch)
+		if err != nil {
+			return types.Permanent(fmt.Errorf("failed to apply pod template: %w", err))
+		}
+	}
+
+	job, err = k.jobClient.Create(k.ctx, job, metav1.CreateOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to create kubernetes job: %w", err)
+		return fmt.Errorf("failed to create kubernetes job: %w", kubeError(err))
 	}
 
-	return nil
+	secretOwned = k.ownSecret(secret, job)
+
+	return k.waitJob(job.Name)
+}
+
+// ownSecret sets owner of the secret to the job, so the secret is garbage collected
+// after the job deleted, returns false if failed to do so
+func (k *KubernetesExecutor) ownSecret(secret *corev1.Secret, job *batchv1.Job) bool {
+	secret.OwnerReferences = append(secret.OwnerReferences, metav1.OwnerReference{
+		APIVersion: batchv1.SchemeGroupVersion.String(),
+		Kind:       "Job",
+		Name:       job.Name,
+		UID:        job.UID,
+	})
+
+	_, err := k.secretClient.Update(k.ctx, secret, metav1.UpdateOptions{})
+	if err != nil {
+		k.logger.I("failed to set owner of secret, deleting after job finished",
+			log.String("secret", secret.Name), log.Error(err))
+		return false
+	}
+
+	return true
+}
+
+// ValidateConfig runs config validator in a job of the renovate image
+func (k *KubernetesExecutor) ValidateConfig(file string, data []byte) (string, error) {
+	trueP := true
+	falseP := false
+	zeroP := int32(0)
+	oneP := int32(1)
+
+	command, args := configValidatorCommand(k.validatorCommand, file)
+	labels := map[string]string{
+		// watched by job informer
+		constant.LabelRenovateRepo:             "",
+		constant.LabelRenovateConfigValidation: "true",
+		constant.LabelRenovateInstance:         k.instanceID,
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "renovate-config-",
+			Namespace:    envhelper.ThisPodNS(),
+			Labels:       labels,
+		},
+		Spec: batchv1.JobSpec{
+			Parallelism:           &oneP,
+			Completions:           &oneP,
+			ActiveDeadlineSeconds: k.activeDeadlineSeconds,
+			// invalid config fails the job
+			BackoffLimit:            &zeroP,
+			TTLSecondsAfterFinished: &k.jobTTLSeconds,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:            "renovate",
+						Image:           k.image,
+						ImagePullPolicy: k.imagePullPolicy,
+						Command:         command,
+						Args:            args,
+						Env: []corev1.EnvVar{{
+							Name:  envRenovateConfigData,
+							Value: string(data),
+						}},
+						// output of validator is reported as termination message
+						TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+						SecurityContext: &corev1.SecurityContext{
+							Capabilities: &corev1.Capabilities{
+								Drop: []corev1.Capability{"all"},
+							},
+							RunAsNonRoot:             &trueP,
+							AllowPrivilegeEscalation: &falseP,
+						},
+					}},
+					RestartPolicy: corev1.RestartPolicyNever,
+					SecurityContext: &corev1.PodSecurityContext{
+						RunAsNonRoot: &trueP,
+					},
+				},
+			},
+		},
+	}
+
+	var err error
+	if len(k.podTemplatePatch) != 0 {
+		job.Spec.Template, err = applyPodTemplatePatch(job.Spec.Template, k.podTemplatePatch)
+		if err != nil {
+			return "", fmt.Errorf("failed to apply pod template: %w", err)
+		}
+	}
+
+	job, err = k.jobClient.Create(k.ctx, job, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to create kubernetes job: %w", err)
+	}
+
+	defer func() {
+		background := metav1.DeletePropagationBackground
+		err2 := k.jobClient.Delete(k.ctx, job.Name, metav1.DeleteOptions{PropagationPolicy: &background})
+		if err2 != nil && !kubeerrors.IsNotFound(err2) {
+			k.logger.I("failed to delete config validator job", log.String("job", job.Name), log.Error(err2))
+		}
+	}()
+
+	err = k.waitJob(job.Name)
+	if err == nil {
+		return "", nil
+	}
+
+	if errors.Is(err, types.ErrExecutionDeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return "", err
+	}
+
+	// validator exits with non-zero code when config is invalid
+	pods, err2 := k.podClient.List(k.ctx, metav1.ListOptions{LabelSelector: "job-name=" + job.Name})
+	if err2 != nil {
+		return "", fmt.Errorf("%v, failed to list pods of job: %w", err, err2)
+	}
+
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			terminated := status.State.Terminated
+			if terminated == nil || terminated.ExitCode == 0 {
+				continue
+			}
+
+			if terminated.Message == "" {
+				return fmt.Sprintf("config validator exited with code %d", terminated.ExitCode), nil
+			}
+
+			return terminated.Message, nil
+		}
+	}
+
+	return "", err
+}
+
+// kubeError marks errors of requests unlikely to succeed on retry as permanent
+func kubeError(err error) error {
+	switch {
+	case kubeerrors.IsUnauthorized(err),
+		kubeerrors.IsForbidden(err),
+		kubeerrors.IsInvalid(err),
+		kubeerrors.IsBadRequest(err):
+		return types.Permanent(err)
+	default:
+		// server errors, conflicts and timeouts are retryable
+		return err
+	}
+}
+
+// applyPodTemplatePatch merges patch into tpl using strategic merge patch
+func applyPodTemplatePatch(tpl corev1.PodTemplateSpec, patch []byte) (corev1.PodTemplateSpec, error) {
+	original, err := json.Marshal(tpl)
+	if err != nil {
+		return tpl, err
+	}
+
+	merged, err := strategicpatch.StrategicMergePatch(original, patch, corev1.PodTemplateSpec{})
+	if err != nil {
+		return tpl, err
+	}
+
+	var ret corev1.PodTemplateSpec
+	err = json.Unmarshal(merged, &ret)
+	if err != nil {
+		return tpl, err
+	}
+
+	return ret, nil
 }
 
-func formatNamePrefix(prefix, repo string) string {
-	// 253: max pod name length
-	// 11: length random suffix generated by kubernetes
-	// 1: suffix `-`
-	maxRepoSuffixLength := 253 - len(prefix) - 11 - 1
-
-	repoSuffix := strings.ToLower(repo)
-	for len(repoSuffix) > maxRepoSuffixLength {
-		parts := strings.SplitN(repoSuffix, "/", 2)
-		if len(parts) == 2 {
-			repoSuffix = parts[1]
-		} else {
-			repoSuffix = parts[0][:maxRepoSuffixLength]
+// UntrackedExecutions counts unfinished jobs not created by this process, nothing
+// is counted until jobs are synced
+func (k *KubernetesExecutor) UntrackedExecutions() map[string]int {
+	if !k.jobInformer.HasSynced() {
+		return nil
+	}
+
+	ret := make(map[string]int)
+	for _, obj := range k.jobInformer.GetStore().List() {
+		job, ok := obj.(*batchv1.Job)
+		if !ok {
+			continue
+		}
+
+		// labeled before created, counted as running by controller
+		if job.Labels[constant.LabelRenovateInstance] == k.instanceID {
+			continue
+		}
+
+		if _, ok = job.Labels[constant.LabelRenovateConfigValidation]; ok {
+			continue
+		}
+
+		if finished, _ := jobResult(job); finished {
+			continue
+		}
+
+		ret[job.Annotations[constant.AnnotationRenovateManager]]++
+	}
+
+	return ret
+}
+
+// isCacheInUse checks whether there is unfinished job using the cache, the cache is
+// considered in use until jobs are synced
+func (k *KubernetesExecutor) isCacheInUse(key string) bool {
+	if !k.jobInformer.HasSynced() {
+		return true
+	}
+
+	for _, obj := range k.jobInformer.GetStore().List() {
+		job, ok := obj.(*batchv1.Job)
+		if !ok || job.Labels[constant.LabelRenovateCache] != key {
+			continue
+		}
+
+		if finished, _ := jobResult(job); !finished {
+			return true
+		}
+	}
+
+	return false
+}
+
+// waitJob blocks until the job finished
+func (k *KubernetesExecutor) waitJob(name string) error {
+	logger := k.logger.WithFields(log.String("job", name))
+
+	resultCh := make(chan error, 1)
+
+	k.mu.Lock()
+	k.jobWaiters[name] = resultCh
+	k.mu.Unlock()
+
+	defer func() {
+		k.mu.Lock()
+		delete(k.jobWaiters, name)
+		k.mu.Unlock()
+	}()
+
+	// job may have finished before we started waiting
+	obj, exists, err := k.jobInformer.GetStore().GetByKey(envhelper.ThisPodNS() + "/" + name)
+	if err == nil && exists {
+		if finished, err2 := jobResult(obj.(*batchv1.Job)); finished {
+			return err2
+		}
+	}
+
+	logger.D("waiting for job to finish")
+
+	select {
+	case <-k.ctx.Done():
+		return k.ctx.Err()
+	case err = <-resultCh:
+		return err
+	}
+}
+
+func (k *KubernetesExecutor) onJobUpdated(obj interface{}) {
+	job, ok := obj.(*batchv1.Job)
+	if !ok {
+		return
+	}
+
+	finished, err := jobResult(job)
+	if !finished {
+		return
+	}
+
+	k.notifyJobWaiter(job.Name, err)
+}
+
+func (k *KubernetesExecutor) onJobDeleted(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	job, ok := obj.(*batchv1.Job)
+	if !ok {
+		return
+	}
+
+	finished, err := jobResult(job)
+	if !finished {
+		err = fmt.Errorf("renovate job %q deleted before finished", job.Name)
+	}
+
+	k.notifyJobWaiter(job.Name, err)
+}
+
+func (k *KubernetesExecutor) notifyJobWaiter(name string, err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	ch, ok := k.jobWaiters[name]
+	if !ok {
+		return
+	}
+
+	select {
+	case ch <- err:
+	default:
+		// result already delivered
+	}
+}
+
+// jobResult checks job conditions, returns true and the execution error (nil if succeeded)
+// if the job has finished
+func jobResult(job *batchv1.Job) (bool, error) {
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+
+		switch c.Type {
+		case batchv1.JobComplete:
+			return true, nil
+		case batchv1.JobFailed:
+			if c.Reason == "DeadlineExceeded" {
+				return true, fmt.Errorf("renovate job %q: %s: %w", job.Name, c.Message, types.ErrExecutionDeadlineExceeded)
+			}
+
+			return true, fmt.Errorf("renovate job %q failed: %s: %s", job.Name, c.Reason, c.Message)
 		}
 	}
 
-	return prefix + strings.ReplaceAll(repoSuffix, "/", "-") + "-"
+	return false, nil
 }
@@ -0,0 +1,195 @@
+package executor
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sync"
+
+	"arhat.dev/pkg/envhelper"
+	"arhat.dev/pkg/hashhelper"
+	corev1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	clientcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/constant"
+)
+
+const cacheVolumeName = "renovate-cache"
+
+func newKubernetesCache(config *conf.KubernetesCacheConfig, client kubernetes.Interface) (*kubernetesCache, error) {
+	mountPath := config.MountPath
+	if mountPath == "" {
+		mountPath = constant.DefaultRenovateBaseDir
+	}
+
+	fsGroup := int64(constant.DefaultRenovateGroupID)
+	if config.FSGroup != nil {
+		fsGroup = *config.FSGroup
+	}
+
+	c := &kubernetesCache{
+		mountPath: mountPath,
+		claimName: config.ClaimName,
+		fsGroup:   fsGroup,
+
+		pvcClient: client.CoreV1().PersistentVolumeClaims(envhelper.ThisPodNS()),
+
+		locks: make(map[string]chan struct{}),
+		mu:    new(sync.Mutex),
+	}
+
+	if c.claimName != "" {
+		return c, nil
+	}
+
+	// pvc created on demand
+	if config.Size == "" {
+		return nil, fmt.Errorf("cache size is required when no claim name provided")
+	}
+
+	var err error
+	c.size, err = resource.ParseQuantity(config.Size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache size %q: %w", config.Size, err)
+	}
+
+	if config.StorageClassName != "" {
+		storageClassName := config.StorageClassName
+		c.storageClassName = &storageClassName
+	}
+
+	for _, m := range config.AccessModes {
+		c.accessModes = append(c.accessModes, corev1.PersistentVolumeAccessMode(m))
+	}
+	if len(c.accessModes) == 0 {
+		c.accessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	}
+
+	return c, nil
+}
+
+type kubernetesCache struct {
+	mountPath string
+	claimName string
+	// group owning the cache volume to make it writable by non-root renovate
+	fsGroup int64
+
+	storageClassName *string
+	size             resource.Quantity
+	accessModes      []corev1.PersistentVolumeAccessMode
+
+	pvcClient clientcorev1.PersistentVolumeClaimInterface
+
+	// cache key -> lock
+	locks map[string]chan struct{}
+	mu    *sync.Mutex
+}
+
+// cacheKey returns the key of cache used by repos, all batch executions share the same cache
+func cacheKey(repos []string) string {
+	key := "batch"
+	if len(repos) == 1 {
+		key = repos[0]
+	}
+
+	return hex.EncodeToString(hashhelper.MD5Sum([]byte(key)))
+}
+
+// lock blocks until no other execution in this process is using the cache
+func (c *kubernetesCache) lock(ctx context.Context, key string) (unlock func(), _ error) {
+	c.mu.Lock()
+	l, ok := c.locks[key]
+	if !ok {
+		l = make(chan struct{}, 1)
+		c.locks[key] = l
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case l <- struct{}{}:
+		return func() { <-l }, nil
+	}
+}
+
+// prepare ensures the cache volume exists and returns volume and volume mount for the job
+func (c *kubernetesCache) prepare(
+	ctx context.Context,
+	key, repoLabel, reposValue string,
+) (corev1.Volume, corev1.VolumeMount, error) {
+	mount := corev1.VolumeMount{
+		Name:      cacheVolumeName,
+		MountPath: c.mountPath,
+	}
+
+	claimName := c.claimName
+	if claimName != "" {
+		// shared pvc
+		mount.SubPath = key
+	} else {
+		claimName = "renovate-cache-" + key
+		err := c.ensurePVC(ctx, claimName, key, repoLabel, reposValue)
+		if err != nil {
+			return corev1.Volume{}, corev1.VolumeMount{}, err
+		}
+	}
+
+	return corev1.Volume{
+		Name: cacheVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: claimName,
+			},
+		},
+	}, mount, nil
+}
+
+func (c *kubernetesCache) ensurePVC(ctx context.Context, name, key, repoLabel, reposValue string) error {
+	_, err := c.pvcClient.Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+
+	if !kubeerrors.IsNotFound(err) {
+		return fmt.Errorf("failed to check cache pvc: %w", kubeError(err))
+	}
+
+	_, err = c.pvcClient.Create(ctx, &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: envhelper.ThisPodNS(),
+			Labels: map[string]string{
+				constant.LabelRenovateRepo:  repoLabel,
+				constant.LabelRenovateCache: key,
+			},
+			Annotations: map[string]string{
+				constant.AnnotationRenovateRepos: reposValue,
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: c.accessModes,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: c.size,
+				},
+			},
+			StorageClassName: c.storageClassName,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil && !kubeerrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create cache pvc: %w", kubeError(err))
+	}
+
+	return nil
+}
+
+func (c *kubernetesCache) cacheDir() string {
+	return path.Join(c.mountPath, "cache")
+}
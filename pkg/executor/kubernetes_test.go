@@ -1,51 +1,472 @@
 package executor
 
 import (
-	"strings"
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	kubetesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/constant"
+	"arhat.dev/renovate-server/pkg/types"
 )
 
-func TestFormatNamePrefix(t *testing.T) {
-	const maxRepoNameLen = 10
-	prefix := strings.Repeat("p", 253-11-1-maxRepoNameLen-1) + "-"
+func newFakeKubernetesExecutor(t *testing.T, config *conf.KubernetesExecutorConfig) (*KubernetesExecutor, *fake.Clientset) {
+	client := fake.NewSimpleClientset()
+	// fake clientset doesn't generate names
+	client.PrependReactor("create", "jobs", func(action kubetesting.Action) (bool, runtime.Object, error) {
+		job := action.(kubetesting.CreateAction).GetObject().(*batchv1.Job)
+		if job.Name == "" {
+			job.Name = job.GenerateName + "test"
+		}
+		return false, nil, nil
+	})
+	client.PrependReactor("create", "secrets", func(action kubetesting.Action) (bool, runtime.Object, error) {
+		secret := action.(kubetesting.CreateAction).GetObject().(*corev1.Secret)
+		if secret.Name == "" {
+			secret.Name = secret.GenerateName + "test"
+		}
+		return false, nil, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	k, err := newKubernetesExecutor(ctx, config, client)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	if !cache.WaitForCacheSync(ctx.Done(), k.jobInformer.HasSynced) {
+		t.FailNow()
+	}
+
+	return k, client
+}
+
+// executeAndFinish runs Execute and updates the created job with condition once it's created
+func executeAndFinish(t *testing.T, k *KubernetesExecutor, client *fake.Clientset, cond batchv1.JobCondition) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- k.Execute(types.ExecutionArgs{
+			Platform: "github",
+			APIURL:   "https://api.github.com/",
+			APIToken: "foo",
+			Repos:    []string{"foo/bar"},
+		})
+	}()
+
+	jobClient := client.BatchV1().Jobs(metav1.NamespaceDefault)
+	var job *batchv1.Job
+	assert.Eventually(t, func() bool {
+		var err error
+		job, err = jobClient.Get(context.TODO(), "renovate-foo-bar-test", metav1.GetOptions{})
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	select {
+	case err := <-errCh:
+		t.Fatalf("execute returned before job finished: %v", err)
+	default:
+	}
 
+	assert.Equal(t, "foo-bar", job.Labels[constant.LabelRenovateRepo])
+
+	job.Status.Conditions = append(job.Status.Conditions, cond)
+	_, err := jobClient.UpdateStatus(context.TODO(), job, metav1.UpdateOptions{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	select {
+	case err = <-errCh:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("execute not returned after job finished")
+		return nil
+	}
+}
+
+func TestKubernetesExecutor_Execute(t *testing.T) {
 	tests := []struct {
-		name     string
-		repo     string
-		expected string
+		name      string
+		condition batchv1.JobCondition
+		check     func(t *testing.T, err error)
 	}{
 		{
-			name:     "Normal Repo Name",
-			repo:     strings.Repeat("r", maxRepoNameLen-4) + "/foo",
-			expected: prefix + strings.Repeat("r", maxRepoNameLen-4) + "-foo-",
+			name: "Complete",
+			condition: batchv1.JobCondition{
+				Type:   batchv1.JobComplete,
+				Status: corev1.ConditionTrue,
+			},
+			check: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
 		},
 		{
-			name:     "Normal Multi-Org Repo Name",
-			repo:     strings.Repeat("/r", maxRepoNameLen/2),
-			expected: prefix + strings.Repeat("-r", maxRepoNameLen/2) + "-",
+			name: "Failed",
+			condition: batchv1.JobCondition{
+				Type:   batchv1.JobFailed,
+				Status: corev1.ConditionTrue,
+				Reason: "BackoffLimitExceeded",
+			},
+			check: func(t *testing.T, err error) {
+				if assert.Error(t, err) {
+					assert.NotErrorIs(t, err, types.ErrExecutionDeadlineExceeded)
+					assert.Contains(t, err.Error(), "BackoffLimitExceeded")
+				}
+			},
 		},
 		{
-			name:     "Long Repo Name with Normal Name",
-			repo:     strings.Repeat("r", maxRepoNameLen*2) + "/" + strings.Repeat("r", maxRepoNameLen),
-			expected: prefix + strings.Repeat("r", maxRepoNameLen) + "-",
-		},
-		{
-			name:     "Long Repo Name with Long Name",
-			repo:     strings.Repeat("r", maxRepoNameLen*2) + "/" + strings.Repeat("r", maxRepoNameLen*2),
-			expected: prefix + strings.Repeat("r", maxRepoNameLen) + "-",
-		},
-		{
-			name:     "Long Multi-Org Repo Name",
-			repo:     strings.Repeat("r/", maxRepoNameLen*2),
-			expected: prefix + strings.Repeat("r-", maxRepoNameLen/2) + "-",
+			name: "Deadline Exceeded",
+			condition: batchv1.JobCondition{
+				Type:   batchv1.JobFailed,
+				Status: corev1.ConditionTrue,
+				Reason: "DeadlineExceeded",
+			},
+			check: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, types.ErrExecutionDeadlineExceeded)
+				// retried by controller
+				assert.False(t, types.IsPermanent(err))
+			},
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			assert.Equal(t, test.expected, formatNamePrefix(prefix, test.repo))
+			k, client := newFakeKubernetesExecutor(t, &conf.KubernetesExecutorConfig{
+				JobTTL:            time.Hour,
+				JobActiveDeadline: time.Hour,
+			})
+
+			test.check(t, executeAndFinish(t, k, client, test.condition))
+
+			// token secret is garbage collected with the job
+			secret, err := client.CoreV1().Secrets(metav1.NamespaceDefault).Get(
+				context.TODO(), "renovate-token-test", metav1.GetOptions{},
+			)
+			if assert.NoError(t, err) {
+				assert.Equal(t, []byte("foo"), secret.Data["RENOVATE_TOKEN"])
+				if assert.Len(t, secret.OwnerReferences, 1) {
+					assert.Equal(t, "Job", secret.OwnerReferences[0].Kind)
+					assert.Equal(t, "renovate-foo-bar-test", secret.OwnerReferences[0].Name)
+				}
+			}
 		})
 	}
 }
+
+func TestKubernetesExecutor_Execute_JobCreationFailed(t *testing.T) {
+	k, client := newFakeKubernetesExecutor(t, &conf.KubernetesExecutorConfig{})
+	client.PrependReactor("create", "jobs", func(action kubetesting.Action) (bool, runtime.Object, error) {
+		return true, nil, kubeerrors.NewServiceUnavailable("etcd unavailable")
+	})
+
+	assert.Error(t, k.Execute(types.ExecutionArgs{Platform: "github", Repos: []string{"foo/bar"}}))
+
+	// unused secret is deleted
+	secrets, err := client.CoreV1().Secrets(metav1.NamespaceDefault).List(context.TODO(), metav1.ListOptions{})
+	if assert.NoError(t, err) {
+		assert.Empty(t, secrets.Items)
+	}
+}
+
+func TestApplyPodTemplatePatch(t *testing.T) {
+	tpl := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{constant.LabelRenovateRepo: "foo-bar"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "renovate",
+				Image: constant.DefaultRenovateImage,
+				Env:   []corev1.EnvVar{{Name: "RENOVATE_PLATFORM", Value: "github"}},
+			}},
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}
+
+	var patch map[string]interface{}
+	err := yaml.Unmarshal([]byte(`
+metadata:
+  labels:
+    team: infra
+spec:
+  serviceAccountName: renovate
+  nodeSelector:
+    dedicated: renovate
+  imagePullSecrets:
+  - name: registry
+  containers:
+  - name: renovate
+    env:
+    - name: NPM_CONFIG_REGISTRY
+      value: https://npm.example.com
+    resources:
+      limits:
+        memory: 2Gi
+`), &patch)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	k, _ := newFakeKubernetesExecutor(t, &conf.KubernetesExecutorConfig{PodTemplate: patch})
+
+	ret, err := applyPodTemplatePatch(tpl, k.podTemplatePatch)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, map[string]string{constant.LabelRenovateRepo: "foo-bar", "team": "infra"}, ret.Labels)
+	assert.Equal(t, "renovate", ret.Spec.ServiceAccountName)
+	assert.Equal(t, map[string]string{"dedicated": "renovate"}, ret.Spec.NodeSelector)
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "registry"}}, ret.Spec.ImagePullSecrets)
+	assert.Equal(t, corev1.RestartPolicyNever, ret.Spec.RestartPolicy)
+
+	if assert.Len(t, ret.Spec.Containers, 1) {
+		c := ret.Spec.Containers[0]
+		assert.Equal(t, constant.DefaultRenovateImage, c.Image)
+		assert.ElementsMatch(t, []corev1.EnvVar{
+			{Name: "RENOVATE_PLATFORM", Value: "github"},
+			{Name: "NPM_CONFIG_REGISTRY", Value: "https://npm.example.com"},
+		}, c.Env)
+		assert.Equal(t, "2Gi", c.Resources.Limits.Memory().String())
+	}
+}
+
+func TestNewKubernetesExecutor_InvalidPodTemplate(t *testing.T) {
+	_, err := newKubernetesExecutor(context.TODO(), &conf.KubernetesExecutorConfig{
+		PodTemplate: map[string]interface{}{
+			"spec": map[string]interface{}{"nodeSelectr": map[string]interface{}{"foo": "bar"}},
+		},
+	}, fake.NewSimpleClientset())
+	assert.Error(t, err)
+}
+
+func TestKubernetesExecutor_Execute_Cache(t *testing.T) {
+	k, client := newFakeKubernetesExecutor(t, &conf.KubernetesExecutorConfig{
+		Cache: &conf.KubernetesCacheConfig{
+			Size:             "1Gi",
+			StorageClassName: "fast",
+		},
+	})
+
+	key := cacheKey([]string{"foo/bar"})
+	jobClient := client.BatchV1().Jobs(metav1.NamespaceDefault)
+
+	// unfinished job created by previous instance
+	oldJob, err := jobClient.Create(context.TODO(), &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "renovate-foo-bar-old",
+			Labels: map[string]string{
+				constant.LabelRenovateRepo:  "foo-bar",
+				constant.LabelRenovateCache: key,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Eventually(t, func() bool {
+		return len(k.jobInformer.GetStore().List()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	err = k.Execute(types.ExecutionArgs{Platform: "github", Repos: []string{"foo/bar"}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "being used")
+	}
+
+	oldJob.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	_, err = jobClient.UpdateStatus(context.TODO(), oldJob, metav1.UpdateOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Eventually(t, func() bool {
+		return !k.isCacheInUse(key)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, executeAndFinish(t, k, client, batchv1.JobCondition{
+		Type:   batchv1.JobComplete,
+		Status: corev1.ConditionTrue,
+	}))
+
+	pvc, err := client.CoreV1().PersistentVolumeClaims(metav1.NamespaceDefault).
+		Get(context.TODO(), "renovate-cache-"+key, metav1.GetOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "fast", *pvc.Spec.StorageClassName)
+	assert.Equal(t, "1Gi", pvc.Spec.Resources.Requests.Storage().String())
+
+	job, err := jobClient.Get(context.TODO(), "renovate-foo-bar-test", metav1.GetOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	spec := job.Spec.Template.Spec
+	assert.Equal(t, key, job.Labels[constant.LabelRenovateCache])
+	if assert.Len(t, spec.Volumes, 1) {
+		assert.Equal(t, "renovate-cache-"+key, spec.Volumes[0].PersistentVolumeClaim.ClaimName)
+	}
+	assert.Equal(t, []corev1.VolumeMount{{Name: cacheVolumeName, MountPath: constant.DefaultRenovateBaseDir}},
+		spec.Containers[0].VolumeMounts)
+	assert.Contains(t, spec.Containers[0].Env, corev1.EnvVar{Name: "RENOVATE_CACHE_DIR", Value: "/tmp/renovate/cache"})
+	if assert.NotNil(t, spec.SecurityContext.FSGroup) {
+		assert.EqualValues(t, constant.DefaultRenovateGroupID, *spec.SecurityContext.FSGroup)
+	}
+}
+
+func TestKubernetesExecutor_Execute_PermanentError(t *testing.T) {
+	k, client := newFakeKubernetesExecutor(t, &conf.KubernetesExecutorConfig{})
+	client.PrependReactor("create", "secrets", func(action kubetesting.Action) (bool, runtime.Object, error) {
+		return true, nil, kubeerrors.NewForbidden(corev1.Resource("secrets"), "", nil)
+	})
+
+	err := k.Execute(types.ExecutionArgs{Platform: "github", Repos: []string{"foo/bar"}})
+	assert.True(t, types.IsPermanent(err))
+
+	client.PrependReactor("create", "secrets", func(action kubetesting.Action) (bool, runtime.Object, error) {
+		return true, nil, kubeerrors.NewServiceUnavailable("etcd unavailable")
+	})
+
+	err = k.Execute(types.ExecutionArgs{Platform: "github", Repos: []string{"foo/bar"}})
+	if assert.Error(t, err) {
+		assert.False(t, types.IsPermanent(err))
+	}
+}
+
+func TestKubernetesExecutor_CheckHealth(t *testing.T) {
+	k, client := newFakeKubernetesExecutor(t, &conf.KubernetesExecutorConfig{})
+	assert.NoError(t, k.CheckHealth(context.TODO()))
+
+	client.PrependReactor("list", "jobs", func(action kubetesting.Action) (bool, runtime.Object, error) {
+		return true, nil, kubeerrors.NewForbidden(batchv1.Resource("jobs"), "", nil)
+	})
+	assert.Error(t, k.CheckHealth(context.TODO()))
+}
+
+func TestKubernetesExecutor_ValidateConfig(t *testing.T) {
+	k, client := newFakeKubernetesExecutor(t, &conf.KubernetesExecutorConfig{
+		ConfigValidatorCommand: []string{"validate"},
+	})
+
+	type result struct {
+		problem string
+		err     error
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		problem, err := k.ValidateConfig("renovate.json", []byte(`{"extends": [}`))
+		resultCh <- result{problem: problem, err: err}
+	}()
+
+	jobClient := client.BatchV1().Jobs(metav1.NamespaceDefault)
+	var job *batchv1.Job
+	assert.Eventually(t, func() bool {
+		var err error
+		job, err = jobClient.Get(context.TODO(), "renovate-config-test", metav1.GetOptions{})
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// not counted as renovate execution
+	assert.Empty(t, k.UntrackedExecutions())
+
+	container := job.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, []string{"sh", "-c", configValidatorScript}, container.Command)
+	assert.Equal(t, []string{"renovate.json", "validate", "renovate.json"}, container.Args)
+	assert.Equal(t, []corev1.EnvVar{{Name: envRenovateConfigData, Value: `{"extends": [}`}}, container.Env)
+
+	_, err := client.CoreV1().Pods(metav1.NamespaceDefault).Create(context.TODO(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "renovate-config-test-pod",
+			Labels: map[string]string{"job-name": job.Name},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: "renovate",
+				State: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Message: "invalid json5"},
+				},
+			}},
+		},
+	}, metav1.CreateOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+		Type:   batchv1.JobFailed,
+		Status: corev1.ConditionTrue,
+		Reason: "BackoffLimitExceeded",
+	})
+	_, err = jobClient.UpdateStatus(context.TODO(), job, metav1.UpdateOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	select {
+	case r := <-resultCh:
+		assert.NoError(t, r.err)
+		assert.Equal(t, "invalid json5", r.problem)
+	case <-time.After(5 * time.Second):
+		t.Fatal("validation not finished after job failed")
+	}
+
+	// job is deleted after validation
+	_, err = jobClient.Get(context.TODO(), job.Name, metav1.GetOptions{})
+	assert.True(t, kubeerrors.IsNotFound(err))
+}
+
+func TestKubernetesExecutor_UntrackedExecutions(t *testing.T) {
+	k, client := newFakeKubernetesExecutor(t, &conf.KubernetesExecutorConfig{})
+
+	jobClient := client.BatchV1().Jobs(metav1.NamespaceDefault)
+	for _, job := range []*batchv1.Job{
+		{ObjectMeta: metav1.ObjectMeta{
+			Name:        "renovate-a",
+			Labels:      map[string]string{constant.LabelRenovateRepo: "a"},
+			Annotations: map[string]string{constant.AnnotationRenovateManager: "/a"},
+		}},
+		{ObjectMeta: metav1.ObjectMeta{
+			Name:        "renovate-b",
+			Labels:      map[string]string{constant.LabelRenovateRepo: "b"},
+			Annotations: map[string]string{constant.AnnotationRenovateManager: "/b"},
+		}, Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{
+			Type:   batchv1.JobComplete,
+			Status: corev1.ConditionTrue,
+		}}}},
+		// created by this process, not waited yet or waited by executor before reload
+		{ObjectMeta: metav1.ObjectMeta{
+			Name: "renovate-c",
+			Labels: map[string]string{
+				constant.LabelRenovateRepo:     "c",
+				constant.LabelRenovateInstance: instanceID,
+			},
+			Annotations: map[string]string{constant.AnnotationRenovateManager: "/a"},
+		}},
+	} {
+		_, err := jobClient.Create(context.TODO(), job, metav1.CreateOptions{})
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	assert.Eventually(t, func() bool {
+		return len(k.jobInformer.GetStore().List()) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// finished jobs and jobs of this process are not counted
+	assert.Equal(t, map[string]int{"/a": 1}, k.UntrackedExecutions())
+}
@@ -0,0 +1,331 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"arhat.dev/pkg/log"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/constant"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+// max size of stdout/stderr kept for each renovate execution
+const maxCapturedOutputSize = 64 * 1024
+
+// environment variables of renovate-server passed to local commands, others (e.g.
+// credentials of renovate-server) are not inherited
+var inheritedEnv = []string{
+	"PATH", "HOME", "TMPDIR",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY",
+	"http_proxy", "https_proxy", "no_proxy",
+	// required by networking on windows
+	"SYSTEMROOT",
+}
+
+func NewLocalExecutor(ctx context.Context, config *conf.LocalExecutorConfig) (types.Executor, error) {
+	command := config.Command
+	if len(command) == 0 {
+		command = []string{constant.DefaultRenovateCommand}
+	}
+
+	bin, err := exec.LookPath(command[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to find renovate command %q: %w", command[0], err)
+	}
+
+	workDir := config.WorkDir
+	if workDir == "" {
+		workDir = os.TempDir()
+	}
+
+	err = os.MkdirAll(workDir, 0750)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ensure work dir %q: %w", workDir, err)
+	}
+
+	var extraEnv []string
+	for k, v := range config.Env {
+		extraEnv = append(extraEnv, k+"="+v)
+	}
+	sort.Strings(extraEnv)
+
+	var (
+		validatorBin  string
+		validatorArgs []string
+	)
+	if len(config.ConfigValidatorCommand) != 0 {
+		validatorBin, err = exec.LookPath(config.ConfigValidatorCommand[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to find config validator command %q: %w",
+				config.ConfigValidatorCommand[0], err)
+		}
+		validatorArgs = config.ConfigValidatorCommand[1:]
+	}
+
+	var sem chan struct{}
+	if config.MaxConcurrency > 0 {
+		sem = make(chan struct{}, config.MaxConcurrency)
+	}
+
+	return &LocalExecutor{
+		ctx: ctx,
+
+		logger: log.Log.WithName("local-executor"),
+
+		bin:         bin,
+		binArgs:     command[1:],
+		workDir:     workDir,
+		keepWorkDir: config.KeepWorkDir,
+		timeout:     config.Timeout,
+		extraEnv:    extraEnv,
+
+		validatorBin:  validatorBin,
+		validatorArgs: validatorArgs,
+
+		sem: sem,
+	}, nil
+}
+
+type LocalExecutor struct {
+	ctx context.Context
+
+	logger log.Interface
+
+	bin         string
+	binArgs     []string
+	workDir     string
+	keepWorkDir bool
+	timeout     time.Duration
+	extraEnv    []string
+
+	// config validator command, empty if not configured
+	validatorBin  string
+	validatorArgs []string
+
+	// sem limits concurrent executions, nil means no limit
+	sem chan struct{}
+}
+
+// CheckHealth checks the renovate command is still executable
+func (l *LocalExecutor) CheckHealth(context.Context) error {
+	_, err := exec.LookPath(l.bin)
+	if err != nil {
+		return fmt.Errorf("failed to find renovate command: %w", err)
+	}
+
+	return nil
+}
+
+func (l *LocalExecutor) Execute(args types.ExecutionArgs) error {
+	// defensive check to avoid unnecessary execution
+	if len(args.Repos) == 0 {
+		return nil
+	}
+
+	if l.sem != nil {
+		select {
+		case <-l.ctx.Done():
+			return l.ctx.Err()
+		case l.sem <- struct{}{}:
+		}
+
+		defer func() { <-l.sem }()
+	}
+
+	prefix, _, _ := executionMeta(args.Repos)
+	workDir, err := ioutil.TempDir(l.workDir, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to create work dir: %w", err)
+	}
+
+	logger := l.logger.WithFields(log.Strings("repos", args.Repos), log.String("workDir", workDir))
+	if !l.keepWorkDir {
+		defer func() {
+			err2 := os.RemoveAll(workDir)
+			if err2 != nil {
+				logger.I("failed to remove work dir", log.Error(err2))
+			}
+		}()
+	}
+
+	ctx := l.ctx
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+
+	cmdArgs := make([]string, 0, len(l.binArgs)+len(args.Repos))
+	cmdArgs = append(cmdArgs, l.binArgs...)
+	cmdArgs = append(cmdArgs, args.Repos...)
+
+	// nolint:gosec
+	cmd := exec.Command(l.bin, cmdArgs...)
+	cmd.Dir = workDir
+	cmd.Env = l.buildEnv(args, workDir)
+
+	stdout := &tailBuffer{max: maxCapturedOutputSize}
+	stderr := &tailBuffer{max: maxCapturedOutputSize}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	logger.D("running renovate")
+	err = runCommand(ctx, cmd)
+	logger.D("renovate exited",
+		log.String("stdout", stdout.String()),
+		log.String("stderr", stderr.String()),
+	)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("renovate execution timed out after %v: %w", l.timeout, types.ErrExecutionDeadlineExceeded)
+		}
+
+		return fmt.Errorf("failed to run renovate: %w, stderr: %s", err, stderr.String())
+	}
+
+	return nil
+}
+
+func (l *LocalExecutor) buildEnv(args types.ExecutionArgs, workDir string) []string {
+	// later values override earlier ones with the same key
+	env := inheritEnv()
+	for _, e := range renovateEnv(args, "renovate-server:local-executor", workDir) {
+		env = append(env, e.name+"="+e.value)
+	}
+
+	env = append(env, "RENOVATE_TOKEN="+args.APIToken)
+	return append(env, l.extraEnv...)
+}
+
+// inheritEnv returns allowed environment variables of current process
+func inheritEnv() []string {
+	var env []string
+	for _, k := range inheritedEnv {
+		if v, ok := os.LookupEnv(k); ok {
+			env = append(env, k+"="+v)
+		}
+	}
+
+	return env
+}
+
+// runCommand runs cmd in a new process group, the whole group is killed when ctx is
+// done, so processes spawned by cmd won't outlive it
+func runCommand(ctx context.Context, cmd *exec.Cmd) error {
+	setProcessGroup(cmd)
+
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = killProcessGroup(cmd)
+		case <-exited:
+		}
+	}()
+
+	err = cmd.Wait()
+	close(exited)
+
+	return err
+}
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if n >= b.max {
+		b.buf = append(b.buf[:0], p[n-b.max:]...)
+		return n, nil
+	}
+
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.max; over > 0 {
+		b.buf = b.buf[:copy(b.buf, b.buf[over:])]
+	}
+
+	return n, nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.buf)
+}
+
+func (l *LocalExecutor) ValidateConfig(file string, data []byte) (string, error) {
+	if l.validatorBin == "" {
+		return "", nil
+	}
+
+	workDir, err := ioutil.TempDir(l.workDir, "renovate-config-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create work dir: %w", err)
+	}
+
+	defer func() {
+		err2 := os.RemoveAll(workDir)
+		if err2 != nil {
+			l.logger.I("failed to remove work dir", log.String("workDir", workDir), log.Error(err2))
+		}
+	}()
+
+	configFile := filepath.Join(workDir, filepath.FromSlash(file))
+	err = os.MkdirAll(filepath.Dir(configFile), 0750)
+	if err != nil {
+		return "", fmt.Errorf("failed to create config dir: %w", err)
+	}
+
+	err = ioutil.WriteFile(configFile, data, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	ctx := l.ctx
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+
+	cmdArgs := make([]string, 0, len(l.validatorArgs)+1)
+	cmdArgs = append(cmdArgs, l.validatorArgs...)
+	cmdArgs = append(cmdArgs, file)
+
+	// nolint:gosec
+	cmd := exec.Command(l.validatorBin, cmdArgs...)
+	cmd.Dir = workDir
+	cmd.Env = append(inheritEnv(), l.extraEnv...)
+
+	output := &tailBuffer{max: maxCapturedOutputSize}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	err = runCommand(ctx, cmd)
+	if err == nil {
+		return "", nil
+	}
+
+	var exitErr *exec.ExitError
+	if ctx.Err() == nil && errors.As(err, &exitErr) {
+		// validator exits with non-zero code when config is invalid
+		return output.String(), nil
+	}
+
+	return "", fmt.Errorf("failed to run config validator: %w, output: %s", err, output.String())
+}
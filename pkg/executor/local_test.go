@@ -0,0 +1,162 @@
+package executor
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+func newFakeRenovate(t *testing.T, script string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake renovate script requires posix shell")
+	}
+
+	bin := filepath.Join(t.TempDir(), "renovate")
+	if !assert.NoError(t, ioutil.WriteFile(bin, []byte("#!/bin/sh\n"+script), 0700)) {
+		t.FailNow()
+	}
+
+	return bin
+}
+
+func TestLocalExecutor_Execute(t *testing.T) {
+	outFile := filepath.Join(t.TempDir(), "out")
+	bin := newFakeRenovate(t, `
+echo "args=$*" > "$OUT_FILE"
+env | grep -E '^(RENOVATE_|LOG_CONTEXT=|PATH=|LEAKED_SECRET=)' >> "$OUT_FILE"
+pwd >> "$OUT_FILE"
+`)
+
+	// not inherited
+	assert.NoError(t, os.Setenv("LEAKED_SECRET", "foo"))
+	defer func() { _ = os.Unsetenv("LEAKED_SECRET") }()
+
+	workDir := t.TempDir()
+	e, err := NewLocalExecutor(context.TODO(), &conf.LocalExecutorConfig{
+		Command: []string{bin, "--dry-run=full"},
+		WorkDir: workDir,
+		Env:     map[string]string{"OUT_FILE": outFile},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = e.Execute(types.ExecutionArgs{
+		Platform: "GitHub",
+		APIURL:   "https://api.github.com/",
+		APIToken: "foo",
+		Repos:    []string{"foo/bar", "foo/baz"},
+		GitUser:  "bot",
+		GitEmail: "bot@example.com",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	out, err := ioutil.ReadFile(outFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	assert.Equal(t, "args=--dry-run=full foo/bar foo/baz", lines[0])
+	assert.Contains(t, lines, "RENOVATE_PLATFORM=github")
+	assert.Contains(t, lines, "RENOVATE_ENDPOINT=https://api.github.com/")
+	assert.Contains(t, lines, "RENOVATE_TOKEN=foo")
+	assert.Contains(t, lines, "RENOVATE_GIT_AUTHOR=bot <bot@example.com>")
+	assert.Contains(t, lines, "LOG_CONTEXT=renovate-server:local-executor")
+	assert.Contains(t, lines, "PATH="+os.Getenv("PATH"))
+	assert.NotContains(t, lines, "LEAKED_SECRET=foo")
+
+	runDir := lines[len(lines)-1]
+	assert.Contains(t, lines, "RENOVATE_BASE_DIR="+runDir)
+	assert.Equal(t, workDir, filepath.Dir(runDir))
+	assert.NoDirExists(t, runDir)
+}
+
+func TestLocalExecutor_Execute_Failure(t *testing.T) {
+	bin := newFakeRenovate(t, `
+echo "invalid token" >&2
+exit 1
+`)
+
+	e, err := NewLocalExecutor(context.TODO(), &conf.LocalExecutorConfig{
+		Command: []string{bin},
+		WorkDir: t.TempDir(),
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = e.Execute(types.ExecutionArgs{Platform: "gitlab", Repos: []string{"foo/bar"}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid token")
+	}
+}
+
+func TestLocalExecutor_Execute_Timeout(t *testing.T) {
+	// child processes keep stdout open
+	bin := newFakeRenovate(t, `sleep 10 & sleep 10`)
+
+	e, err := NewLocalExecutor(context.TODO(), &conf.LocalExecutorConfig{
+		Command: []string{bin},
+		WorkDir: t.TempDir(),
+		Timeout: 100 * time.Millisecond,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	start := time.Now()
+	err = e.Execute(types.ExecutionArgs{Platform: "gitlab", Repos: []string{"foo/bar"}})
+	assert.ErrorIs(t, err, types.ErrExecutionDeadlineExceeded)
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{max: 4}
+	_, _ = b.Write([]byte("ab"))
+	assert.Equal(t, "ab", b.String())
+	_, _ = b.Write([]byte("cde"))
+	assert.Equal(t, "bcde", b.String())
+	_, _ = b.Write([]byte("123456"))
+	assert.Equal(t, "3456", b.String())
+}
+
+func TestLocalExecutor_ValidateConfig(t *testing.T) {
+	bin := newFakeRenovate(t, `
+grep -q '"extends"' "$1" && exit 0
+echo "invalid config in $1"
+exit 1
+`)
+	fakeRenovate := newFakeRenovate(t, "")
+
+	e, err := NewLocalExecutor(context.TODO(), &conf.LocalExecutorConfig{
+		Command:                []string{fakeRenovate},
+		WorkDir:                t.TempDir(),
+		ConfigValidatorCommand: []string{bin},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	v := e.(types.ConfigValidator)
+
+	problem, err := v.ValidateConfig(".github/renovate.json", []byte(`{"extends":["config:base"]}`))
+	assert.NoError(t, err)
+	assert.Empty(t, problem)
+
+	problem, err = v.ValidateConfig(".github/renovate.json", []byte(`{"foo":"bar"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "invalid config in .github/renovate.json\n", problem)
+}
@@ -0,0 +1,15 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package executor
+
+import (
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the process of cmd only, child processes are not tracked
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
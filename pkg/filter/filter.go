@@ -0,0 +1,163 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/util"
+)
+
+// Repo is the platform independent metadata of a repo
+type Repo struct {
+	// Name is the full name of the repo, including owner (namespace)
+	Name string
+
+	Topics     []string
+	Visibility string
+	Fork       bool
+	Archived   bool
+	Empty      bool
+
+	// AccessLevel of the user to the repo (gitlab access level), 0 if unknown
+	AccessLevel int
+}
+
+func New(config *conf.RepoFilterConfig) (*Filter, error) {
+	f := &Filter{
+		skipForks:       config.SkipForks,
+		includeArchived: config.IncludeArchived,
+		skipEmpty:       config.SkipEmpty,
+	}
+
+	var err error
+	if config.IncludeNameMatch != "" {
+		f.includeNameMatch, err = regexp.Compile(config.IncludeNameMatch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile include repo match: %w", err)
+		}
+	}
+
+	for _, o := range config.Owners {
+		f.owners = append(f.owners, strings.ToLower(strings.Trim(o, "/")))
+	}
+
+	for _, t := range config.Topics {
+		f.topics = append(f.topics, strings.ToLower(t))
+	}
+
+	if len(config.Visibility) != 0 {
+		f.visibility = make(map[string]struct{})
+		for _, v := range config.Visibility {
+			f.visibility[strings.ToLower(v)] = struct{}{}
+		}
+	}
+
+	if l := config.MinAccessLevel; l != "" {
+		var ok bool
+		f.minAccessLevel, ok = util.ParseAccessLevel(l)
+		if !ok {
+			return nil, fmt.Errorf("invalid min access level %q", l)
+		}
+	}
+
+	return f, nil
+}
+
+// Filter of repos
+type Filter struct {
+	includeNameMatch *regexp.Regexp
+	owners           []string
+	topics           []string
+	visibility       map[string]struct{}
+	skipForks        bool
+	includeArchived  bool
+	skipEmpty        bool
+	minAccessLevel   int
+}
+
+// NeedsMetadata returns true when repo metadata other than name is checked, the
+// metadata should be fetched from platform api for webhook events
+//
+// archived state is not considered since archived repos are read-only and
+// do not generate webhook events we care about
+func (f *Filter) NeedsMetadata() bool {
+	return len(f.topics) != 0 || f.visibility != nil ||
+		f.skipForks || f.skipEmpty || f.minAccessLevel > 0
+}
+
+// MatchName is Match but only checks conditions related to the repo name
+func (f *Filter) MatchName(name string) string {
+	if f.includeNameMatch != nil && !f.includeNameMatch.MatchString(name) {
+		return "name not included"
+	}
+
+	if len(f.owners) == 0 {
+		return ""
+	}
+
+	owner := ""
+	if idx := strings.LastIndexByte(name, '/'); idx >= 0 {
+		owner = strings.ToLower(name[:idx])
+	}
+
+	for _, o := range f.owners {
+		if owner == o || strings.HasPrefix(owner, o+"/") {
+			return ""
+		}
+	}
+
+	return "owner not included"
+}
+
+// IncludeArchived returns true if archived repos are included
+func (f *Filter) IncludeArchived() bool {
+	return f.includeArchived
+}
+
+// MinAccessLevel returns the required access level, 0 means no requirement
+func (f *Filter) MinAccessLevel() int {
+	return f.minAccessLevel
+}
+
+// Match returns empty string if the repo is included, otherwise the reason why
+// it's excluded
+func (f *Filter) Match(r *Repo) string {
+	if reason := f.MatchName(r.Name); reason != "" {
+		return reason
+	}
+
+	for _, t := range f.topics {
+		found := false
+		for _, rt := range r.Topics {
+			if strings.ToLower(rt) == t {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Sprintf("topic %q missing", t)
+		}
+	}
+
+	if f.visibility != nil {
+		if _, ok := f.visibility[strings.ToLower(r.Visibility)]; !ok {
+			return fmt.Sprintf("visibility %q not included", r.Visibility)
+		}
+	}
+
+	switch {
+	case f.skipForks && r.Fork:
+		return "fork"
+	case !f.includeArchived && r.Archived:
+		return "archived"
+	case f.skipEmpty && r.Empty:
+		return "empty"
+	case f.minAccessLevel > 0 && r.AccessLevel > 0 && r.AccessLevel < f.minAccessLevel:
+		return "insufficient access level"
+	}
+
+	return ""
+}
@@ -0,0 +1,113 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+)
+
+func TestFilter_Match(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   conf.RepoFilterConfig
+		repo     Repo
+		excluded bool
+	}{
+		{
+			name: "Default",
+			repo: Repo{Name: "foo/bar", Fork: true, Empty: true},
+		},
+		{
+			name:     "Default Archived",
+			repo:     Repo{Name: "foo/bar", Archived: true},
+			excluded: true,
+		},
+		{
+			name:   "Include Archived",
+			config: conf.RepoFilterConfig{IncludeArchived: true},
+			repo:   Repo{Name: "foo/bar", Archived: true},
+		},
+		{
+			name:     "Name Not Included",
+			config:   conf.RepoFilterConfig{IncludeNameMatch: "^foo/renovate-"},
+			repo:     Repo{Name: "foo/bar"},
+			excluded: true,
+		},
+		{
+			name:   "Subgroup Owner",
+			config: conf.RepoFilterConfig{Owners: []string{"Foo"}},
+			repo:   Repo{Name: "foo/sub/bar"},
+		},
+		{
+			name:     "Owner Prefix Only",
+			config:   conf.RepoFilterConfig{Owners: []string{"foo"}},
+			repo:     Repo{Name: "foobar/bar"},
+			excluded: true,
+		},
+		{
+			name:   "Topics",
+			config: conf.RepoFilterConfig{Topics: []string{"renovate", "go"}},
+			repo:   Repo{Name: "foo/bar", Topics: []string{"Go", "renovate", "other"}},
+		},
+		{
+			name:     "Topic Missing",
+			config:   conf.RepoFilterConfig{Topics: []string{"renovate", "go"}},
+			repo:     Repo{Name: "foo/bar", Topics: []string{"go"}},
+			excluded: true,
+		},
+		{
+			name:     "Visibility",
+			config:   conf.RepoFilterConfig{Visibility: []string{"private", "internal"}},
+			repo:     Repo{Name: "foo/bar", Visibility: "public"},
+			excluded: true,
+		},
+		{
+			name:     "Fork",
+			config:   conf.RepoFilterConfig{SkipForks: true},
+			repo:     Repo{Name: "foo/bar", Fork: true},
+			excluded: true,
+		},
+		{
+			name:     "Empty",
+			config:   conf.RepoFilterConfig{SkipEmpty: true},
+			repo:     Repo{Name: "foo/bar", Empty: true},
+			excluded: true,
+		},
+		{
+			name:     "Access Level",
+			config:   conf.RepoFilterConfig{MinAccessLevel: "developer"},
+			repo:     Repo{Name: "foo/bar", AccessLevel: 20},
+			excluded: true,
+		},
+		{
+			name:   "Access Level Unknown",
+			config: conf.RepoFilterConfig{MinAccessLevel: "developer"},
+			repo:   Repo{Name: "foo/bar"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := New(&test.config)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			if test.excluded {
+				assert.NotEmpty(t, f.Match(&test.repo))
+			} else {
+				assert.Empty(t, f.Match(&test.repo))
+			}
+		})
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New(&conf.RepoFilterConfig{MinAccessLevel: "admin"})
+	assert.Error(t, err)
+
+	_, err = New(&conf.RepoFilterConfig{IncludeNameMatch: "("})
+	assert.Error(t, err)
+}
@@ -0,0 +1,172 @@
+package filter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"arhat.dev/renovate-server/pkg/conf"
+)
+
+// git ref prefixes
+const (
+	branchRefPrefix = "refs/heads/"
+	tagRefPrefix    = "refs/tags/"
+)
+
+// default globs of renovate config and common package manifests, globs without `/`
+// match file names in any directory
+var defaultManifestFiles = []string{
+	// renovate config
+	"/renovate.json",
+	"/renovate.json5",
+	"/.renovaterc",
+	"/.renovaterc.json",
+	".github/renovate.json",
+	".github/renovate.json5",
+	".gitlab/renovate.json",
+	".gitlab/renovate.json5",
+
+	// package manifests
+	"package.json",
+	"go.mod",
+	"Cargo.toml",
+	"pom.xml",
+	"build.gradle",
+	"build.gradle.kts",
+	"requirements*.txt",
+	"setup.py",
+	"pyproject.toml",
+	"Pipfile",
+	"Gemfile",
+	"composer.json",
+	"*.csproj",
+	"Chart.yaml",
+	"requirements.yaml",
+	"Dockerfile",
+	"*.dockerfile",
+	"docker-compose*.yml",
+	"docker-compose*.yaml",
+	".gitlab-ci.yml",
+	".github/workflows/*.yml",
+	".github/workflows/*.yaml",
+}
+
+// Push is the platform independent info of a push event
+type Push struct {
+	// Ref is the full git ref pushed, e.g. refs/heads/master
+	Ref string
+
+	// DefaultBranch of the repo, empty if unknown
+	DefaultBranch string
+
+	// Users pushed the commits (login, name or email)
+	Users []string
+
+	// ChangedFiles in pushed commits, nil if unknown (e.g. commit list truncated)
+	ChangedFiles []string
+}
+
+func NewPushFilter(config *conf.PushFilterConfig, git *conf.GitConfig) (*PushFilter, error) {
+	f := &PushFilter{
+		branches:       config.Branches,
+		ignoreBranches: config.IgnoreBranches,
+		includeTags:    config.IncludeTags,
+	}
+
+	for _, u := range append([]string{git.User, git.Email}, config.IgnoreUsers...) {
+		if u != "" {
+			f.ignoreUsers = append(f.ignoreUsers, u)
+		}
+	}
+
+	if config.ManifestChangesOnly {
+		f.manifestFiles = config.ManifestFiles
+		if len(f.manifestFiles) == 0 {
+			f.manifestFiles = defaultManifestFiles
+		}
+	}
+
+	for _, patterns := range [][]string{f.branches, f.ignoreBranches, f.manifestFiles} {
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, fmt.Errorf("invalid glob %q: %w", p, err)
+			}
+		}
+	}
+
+	return f, nil
+}
+
+// PushFilter of push events
+type PushFilter struct {
+	branches       []string
+	ignoreBranches []string
+	ignoreUsers    []string
+	includeTags    bool
+
+	// nil means any change
+	manifestFiles []string
+}
+
+// Match returns empty string if the push should trigger renovate, otherwise the
+// reason why it's ignored
+func (f *PushFilter) Match(p *Push) string {
+	for _, u := range p.Users {
+		for _, ignored := range f.ignoreUsers {
+			if u != "" && strings.EqualFold(u, ignored) {
+				return fmt.Sprintf("pushed by %q", u)
+			}
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(p.Ref, tagRefPrefix):
+		if !f.includeTags {
+			return "tag push"
+		}
+	default:
+		branch := strings.TrimPrefix(p.Ref, branchRefPrefix)
+		if matchAny(f.ignoreBranches, branch) {
+			return fmt.Sprintf("branch %q ignored", branch)
+		}
+
+		switch {
+		case len(f.branches) != 0:
+			if !matchAny(f.branches, branch) {
+				return fmt.Sprintf("branch %q not included", branch)
+			}
+		case p.DefaultBranch != "" && branch != p.DefaultBranch:
+			return fmt.Sprintf("branch %q is not the default branch", branch)
+		}
+	}
+
+	if f.manifestFiles == nil || p.ChangedFiles == nil {
+		return ""
+	}
+
+	for _, file := range p.ChangedFiles {
+		for _, pattern := range f.manifestFiles {
+			name := file
+			if !strings.Contains(pattern, "/") {
+				name = path.Base(file)
+			}
+
+			if ok, _ := path.Match(strings.TrimPrefix(pattern, "/"), name); ok {
+				return ""
+			}
+		}
+	}
+
+	return "no manifest changed"
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
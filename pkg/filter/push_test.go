@@ -0,0 +1,123 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+)
+
+func TestPushFilter_Match(t *testing.T) {
+	git := conf.GitConfig{User: "renovate-bot", Email: "bot@example.com"}
+
+	tests := []struct {
+		name    string
+		config  conf.PushFilterConfig
+		push    Push
+		ignored bool
+	}{
+		{
+			name: "Default Branch",
+			push: Push{Ref: "refs/heads/master", DefaultBranch: "master", Users: []string{"dev"}},
+		},
+		{
+			name:    "Other Branch",
+			push:    Push{Ref: "refs/heads/renovate/foo-1.x", DefaultBranch: "master"},
+			ignored: true,
+		},
+		{
+			name: "Default Branch Unknown",
+			push: Push{Ref: "refs/heads/feature"},
+		},
+		{
+			name:    "Git User",
+			push:    Push{Ref: "refs/heads/master", DefaultBranch: "master", Users: []string{"Renovate-Bot"}},
+			ignored: true,
+		},
+		{
+			name:    "Git Email",
+			push:    Push{Ref: "refs/heads/master", Users: []string{"", "bot@example.com"}},
+			ignored: true,
+		},
+		{
+			name:    "Ignored User",
+			config:  conf.PushFilterConfig{IgnoreUsers: []string{"dependabot[bot]"}},
+			push:    Push{Ref: "refs/heads/master", Users: []string{"dependabot[bot]"}},
+			ignored: true,
+		},
+		{
+			name:    "Tag",
+			push:    Push{Ref: "refs/tags/v1.0.0", DefaultBranch: "master"},
+			ignored: true,
+		},
+		{
+			name:   "Include Tags",
+			config: conf.PushFilterConfig{IncludeTags: true},
+			push:   Push{Ref: "refs/tags/v1.0.0", DefaultBranch: "master"},
+		},
+		{
+			name:   "Branches",
+			config: conf.PushFilterConfig{Branches: []string{"master", "release-*"}},
+			push:   Push{Ref: "refs/heads/release-1.0", DefaultBranch: "master"},
+		},
+		{
+			name: "Ignore Branches",
+			config: conf.PushFilterConfig{
+				Branches:       []string{"*"},
+				IgnoreBranches: []string{"renovate/*"},
+			},
+			push:    Push{Ref: "refs/heads/renovate/foo", DefaultBranch: "master"},
+			ignored: true,
+		},
+		{
+			name:   "Manifest Changed",
+			config: conf.PushFilterConfig{ManifestChangesOnly: true},
+			push:   Push{Ref: "refs/heads/master", ChangedFiles: []string{"README.md", "web/package.json"}},
+		},
+		{
+			name:   "Renovate Config Changed",
+			config: conf.PushFilterConfig{ManifestChangesOnly: true},
+			push:   Push{Ref: "refs/heads/master", ChangedFiles: []string{".github/renovate.json5"}},
+		},
+		{
+			name:    "No Manifest Changed",
+			config:  conf.PushFilterConfig{ManifestChangesOnly: true},
+			push:    Push{Ref: "refs/heads/master", ChangedFiles: []string{"main.go", "docs/renovate.json"}},
+			ignored: true,
+		},
+		{
+			name:   "Changed Files Unknown",
+			config: conf.PushFilterConfig{ManifestChangesOnly: true},
+			push:   Push{Ref: "refs/heads/master"},
+		},
+		{
+			name: "Custom Manifest Files",
+			config: conf.PushFilterConfig{
+				ManifestChangesOnly: true,
+				ManifestFiles:       []string{"deps/*.txt"},
+			},
+			push: Push{Ref: "refs/heads/master", ChangedFiles: []string{"deps/tools.txt"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := NewPushFilter(&test.config, &git)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			if test.ignored {
+				assert.NotEmpty(t, f.Match(&test.push))
+			} else {
+				assert.Empty(t, f.Match(&test.push))
+			}
+		})
+	}
+}
+
+func TestNewPushFilter_Invalid(t *testing.T) {
+	_, err := NewPushFilter(&conf.PushFilterConfig{Branches: []string{"["}}, &conf.GitConfig{})
+	assert.Error(t, err)
+}
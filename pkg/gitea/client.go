@@ -0,0 +1,80 @@
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// max page size allowed by gitea by default
+const listPageSize = 50
+
+// client is a minimal gitea api v1 client
+type client struct {
+	apiURL *url.URL
+	token  string
+	http   *http.Client
+}
+
+type repository struct {
+	FullName string `json:"full_name"`
+	Archived bool   `json:"archived"`
+}
+
+// listRepos lists all repos accessible to the token owner, pages may be smaller than
+// requested (limited by MAX_RESPONSE_ITEMS of the server), so listing stops at the
+// first empty page
+func (c *client) listRepos(ctx context.Context) ([]repository, error) {
+	var ret []repository
+	for page := 1; ; page++ {
+		var repos []repository
+		err := c.get(ctx, "user/repos", url.Values{
+			"page":  {strconv.FormatInt(int64(page), 10)},
+			"limit": {strconv.FormatInt(listPageSize, 10)},
+		}, &repos)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(repos) == 0 {
+			return ret, nil
+		}
+
+		ret = append(ret, repos...)
+	}
+}
+
+func (c *client) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	u := c.apiURL.ResolveReference(&url.URL{Path: path, RawQuery: query.Encode()})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "token "+c.token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to request %s: %w", u.Path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected response %s for %s: %s", resp.Status, u.Path, msg)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("failed to decode response of %s: %w", u.Path, err)
+	}
+
+	return nil
+}
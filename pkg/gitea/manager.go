@@ -0,0 +1,186 @@
+package gitea
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"arhat.dev/pkg/log"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/constant"
+	"arhat.dev/renovate-server/pkg/filter"
+	"arhat.dev/renovate-server/pkg/types"
+	"arhat.dev/renovate-server/pkg/util"
+)
+
+// NewManager creates platform manager for gitea and forgejo
+func NewManager(
+	ctx context.Context,
+	config *conf.PlatformConfig,
+	scheduler types.Scheduler,
+) (types.PlatformManager, error) {
+	var (
+		err error
+
+		disabledRepoNameMatch *regexp.Regexp
+	)
+	if config.DisabledRepoNameMatch != "" {
+		disabledRepoNameMatch, err = regexp.Compile(config.DisabledRepoNameMatch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile disabled repo match: %w", err)
+		}
+	}
+
+	httpClient, err := config.API.Client.NewClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http client")
+	}
+
+	baseURL := config.API.BaseURL
+	if baseURL == "" {
+		baseURL = constant.DefaultGiteaAPIBaseURL
+	}
+
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+
+	// renovate expects the api endpoint of gitea
+	apiURL := baseURL + "api/v1/"
+	parsedAPIURL, err := url.Parse(apiURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gitea base url: %w", err)
+	}
+
+	if config.API.OAuthToken == "" {
+		return nil, fmt.Errorf("no oauth token provided")
+	}
+
+	pushFilter, err := filter.NewPushFilter(&config.Push, &config.Git)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push filter: %w", err)
+	}
+
+	dashboardTitles := make(map[string]string)
+	disabledRepos := make(map[string]struct{})
+	for _, p := range config.Projects {
+		dashboardTitles[p.Name] = p.DashboardIssueTitle
+		if p.Disabled {
+			disabledRepos[p.Name] = struct{}{}
+		}
+	}
+
+	return &Manager{
+		ctx: ctx,
+
+		logger: log.Log.WithName("gitea").WithFields(
+			log.String("path", config.Webhook.Path),
+			log.String("api", config.API.BaseURL),
+		),
+		client: &client{
+			apiURL: parsedAPIURL,
+			token:  config.API.OAuthToken,
+			http:   httpClient,
+		},
+		scheduler: scheduler,
+
+		disabledRepoNameMatch: disabledRepoNameMatch,
+		defaultDashboardTitle: config.DashboardIssueTitle,
+		dashboardTitles:       dashboardTitles,
+		disabledRepos:         disabledRepos,
+		pushFilter:            pushFilter,
+
+		apiURL:   apiURL,
+		apiToken: config.API.OAuthToken,
+		gitUser:  config.Git.User,
+		gitEmail: config.Git.Email,
+
+		webhookSecret: []byte(config.Webhook.Secret),
+	}, nil
+}
+
+type Manager struct {
+	ctx context.Context
+
+	logger    log.Interface
+	client    *client
+	scheduler types.Scheduler
+
+	disabledRepoNameMatch *regexp.Regexp
+	defaultDashboardTitle string
+	dashboardTitles       map[string]string
+	disabledRepos         map[string]struct{}
+	pushFilter            *filter.PushFilter
+
+	apiURL   string
+	apiToken string
+	gitUser  string
+	gitEmail string
+
+	webhookSecret []byte
+}
+
+func (m *Manager) getDashboardTitle(repo string) string {
+	return util.GetOrDefault(m.dashboardTitles, repo, m.defaultDashboardTitle)
+}
+
+func (m *Manager) ListRepos() ([]string, error) {
+	repos, err := m.client.listRepos(m.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list all repos: %w", err)
+	}
+
+	var ret []string
+	for _, repo := range repos {
+		if repo.Archived {
+			continue
+		}
+
+		name := repo.FullName
+		if m.disabled(name) {
+			continue
+		}
+
+		ret = append(ret, name)
+	}
+
+	return ret, nil
+}
+
+// disabled checks whether the repo is disabled by name match or project config
+func (m *Manager) disabled(repo string) bool {
+	if m.disabledRepoNameMatch != nil && m.disabledRepoNameMatch.MatchString(repo) {
+		return true
+	}
+
+	_, disabled := m.disabledRepos[repo]
+	return disabled
+}
+
+// CheckHealth checks credentials with gitea api
+func (m *Manager) CheckHealth(ctx context.Context) error {
+	err := m.client.get(ctx, "user", nil, &struct{}{})
+	if err != nil {
+		return fmt.Errorf("failed to authenticate with gitea api: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Manager) APIURL() string {
+	return m.apiURL
+}
+
+func (m *Manager) ExecutionArgs(repos ...string) (types.ExecutionArgs, error) {
+	return types.ExecutionArgs{
+		Platform: "gitea",
+		APIURL:   m.apiURL,
+		APIToken: m.apiToken,
+		Repos:    repos,
+		GitUser:  m.gitUser,
+		GitEmail: m.gitEmail,
+	}, nil
+}
@@ -0,0 +1,240 @@
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+type fakeScheduler struct {
+	repos []string
+}
+
+func (s *fakeScheduler) Schedule(repos ...string) error {
+	s.repos = append(s.repos, repos...)
+	return nil
+}
+
+func newTestManager(t *testing.T, baseURL string, scheduler types.Scheduler) *Manager {
+	mgr, err := NewManager(context.TODO(), &conf.PlatformConfig{
+		API: conf.APIConfig{
+			BaseURL:    baseURL,
+			OAuthToken: "test-token",
+		},
+		Git: conf.GitConfig{
+			User:  "renovate-bot",
+			Email: "bot@gitea.example.com",
+		},
+		Webhook: conf.WebhookConfig{
+			Secret: "test-secret",
+		},
+		DashboardIssueTitle:   "Dependency Dashboard",
+		DisabledRepoNameMatch: "^ignored/",
+		Projects: []conf.ProjectConfig{
+			{Name: "foo/disabled", Disabled: true},
+		},
+	}, scheduler)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return mgr.(*Manager)
+}
+
+func TestManager_ListRepos(t *testing.T) {
+	var allRepos []repository
+	for i := 0; i < listPageSize+10; i++ {
+		allRepos = append(allRepos, repository{FullName: fmt.Sprintf("foo/%d", i)})
+	}
+	allRepos = append(allRepos,
+		repository{FullName: "foo/archived", Archived: true},
+		repository{FullName: "foo/disabled"},
+		repository{FullName: "ignored/bar"},
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/user/repos" || r.Header.Get("Authorization") != "token test-token" {
+			http.Error(w, "unexpected request", http.StatusForbidden)
+			return
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		// MAX_RESPONSE_ITEMS of the server is less than requested
+		if limit > 20 {
+			limit = 20
+		}
+		start, end := (page-1)*limit, page*limit
+		if start > len(allRepos) {
+			start = len(allRepos)
+		}
+		if end > len(allRepos) {
+			end = len(allRepos)
+		}
+
+		_ = json.NewEncoder(w).Encode(allRepos[start:end])
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, srv.URL, &fakeScheduler{})
+	assert.Equal(t, srv.URL+"/api/v1/", m.APIURL())
+
+	repos, err := m.ListRepos()
+	assert.NoError(t, err)
+	assert.Len(t, repos, listPageSize+10)
+	assert.NotContains(t, repos, "foo/archived")
+	assert.NotContains(t, repos, "foo/disabled")
+	assert.NotContains(t, repos, "ignored/bar")
+}
+
+func TestManager_ServeHTTP(t *testing.T) {
+	sign := func(payload []byte) string {
+		mac := hmac.New(sha256.New, []byte("test-secret"))
+		_, _ = mac.Write(payload)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name      string
+		event     string
+		forgejo   bool
+		payload   string
+		signature string
+
+		expectedCode int
+		expectedRepo string
+	}{
+		{
+			name:         "Push",
+			event:        "push",
+			payload:      `{"repository":{"full_name":"foo/bar"}}`,
+			expectedCode: http.StatusOK,
+			expectedRepo: "foo/bar",
+		},
+		{
+			name:         "Push Forgejo",
+			event:        "push",
+			forgejo:      true,
+			payload:      `{"repository":{"full_name":"foo/bar"}}`,
+			expectedCode: http.StatusOK,
+			expectedRepo: "foo/bar",
+		},
+		{
+			name:         "Invalid Signature",
+			event:        "push",
+			payload:      `{"repository":{"full_name":"foo/bar"}}`,
+			signature:    sign([]byte("other")),
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:  "Dashboard Checked",
+			event: "issues",
+			payload: `{"action":"edited","repository":{"full_name":"foo/bar"},` +
+				`"changes":{"body":{"from":"- [ ] a"}},` +
+				`"issue":{"title":"Dependency Dashboard","body":"- [x] a"}}`,
+			expectedCode: http.StatusOK,
+			expectedRepo: "foo/bar",
+		},
+		{
+			name:  "Other Issue",
+			event: "issues",
+			payload: `{"action":"edited","repository":{"full_name":"foo/bar"},` +
+				`"changes":{"body":{"from":"- [ ] a"}},` +
+				`"issue":{"title":"Bug","body":"- [x] a"}}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:  "Pull Request Checked",
+			event: "pull_request",
+			payload: `{"action":"edited","repository":{"full_name":"foo/bar"},` +
+				`"changes":{"body":{"from":"- [ ] rebase"}},` +
+				`"pull_request":{"body":"- [x] rebase"}}`,
+			expectedCode: http.StatusOK,
+			expectedRepo: "foo/bar",
+		},
+		{
+			name:         "Pull Request Opened",
+			event:        "pull_request",
+			payload:      `{"action":"opened","repository":{"full_name":"foo/bar"},"pull_request":{"body":""}}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Disabled Repo",
+			event:        "push",
+			payload:      `{"repository":{"full_name":"foo/disabled"}}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Disabled Repo Name Match",
+			event:        "push",
+			payload:      `{"repository":{"full_name":"ignored/bar"}}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:  "Push Default Branch",
+			event: "push",
+			payload: `{"ref":"refs/heads/main","repository":{"full_name":"foo/bar","default_branch":"main"},` +
+				`"pusher":{"login":"dev","email":"dev@gitea.example.com"}}`,
+			expectedCode: http.StatusOK,
+			expectedRepo: "foo/bar",
+		},
+		{
+			name:  "Push Renovate Branch",
+			event: "push",
+			payload: `{"ref":"refs/heads/renovate/foo-1.x","repository":{"full_name":"foo/bar","default_branch":"main"},` +
+				`"pusher":{"login":"dev","email":"dev@gitea.example.com"}}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:  "Push By Renovate",
+			event: "push",
+			payload: `{"ref":"refs/heads/main","repository":{"full_name":"foo/bar","default_branch":"main"},` +
+				`"pusher":{"login":"bot","email":"bot@gitea.example.com"}}`,
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scheduler := &fakeScheduler{}
+			m := newTestManager(t, "https://gitea.example.com", scheduler)
+
+			sig := test.signature
+			if sig == "" {
+				sig = sign([]byte(test.payload))
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(test.payload)))
+			if test.forgejo {
+				req.Header.Set(headerForgejoEvent, test.event)
+				req.Header.Set(headerForgejoSignature, sig)
+			} else {
+				req.Header.Set(headerGiteaEvent, test.event)
+				req.Header.Set(headerGiteaSignature, sig)
+			}
+
+			rec := httptest.NewRecorder()
+			m.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.expectedCode, rec.Code)
+			if test.expectedRepo == "" {
+				assert.Empty(t, scheduler.repos)
+			} else {
+				assert.Equal(t, []string{test.expectedRepo}, scheduler.repos)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package gitea
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"arhat.dev/pkg/log"
+
+	"arhat.dev/renovate-server/pkg/filter"
+	"arhat.dev/renovate-server/pkg/util"
+)
+
+// webhook headers, forgejo sends both its own headers and gitea compatible ones
+const (
+	headerGiteaEvent       = "X-Gitea-Event"
+	headerGiteaSignature   = "X-Gitea-Signature"
+	headerForgejoEvent     = "X-Forgejo-Event"
+	headerForgejoSignature = "X-Forgejo-Signature"
+)
+
+type webhookRepository struct {
+	FullName      string `json:"full_name"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+type webhookUser struct {
+	Login    string `json:"login"`
+	UserName string `json:"username"`
+	FullName string `json:"full_name"`
+	Email    string `json:"email"`
+}
+
+type webhookCommit struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+}
+
+type webhookChanges struct {
+	Body *struct {
+		From string `json:"from"`
+	} `json:"body"`
+}
+
+type webhookPayload struct {
+	Action     string            `json:"action"`
+	Repository webhookRepository `json:"repository"`
+	Changes    *webhookChanges   `json:"changes"`
+
+	Issue *struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+	} `json:"issue"`
+
+	PullRequest *struct {
+		Body string `json:"body"`
+	} `json:"pull_request"`
+
+	// push event
+	Ref          string          `json:"ref"`
+	Commits      []webhookCommit `json:"commits"`
+	TotalCommits int             `json:"total_commits"`
+	Pusher       *webhookUser    `json:"pusher"`
+	Sender       *webhookUser    `json:"sender"`
+}
+
+func (m *Manager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	logger := m.logger.WithFields()
+
+	defer func() {
+		err := recover()
+		if err != nil {
+			logger.E("recovered", log.Any("panic", err))
+		}
+	}()
+
+	logger.D("event received")
+
+	payload, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		logger.I("failed to read payload", log.Error(err))
+		http.Error(w, "failed to read payload", http.StatusBadRequest)
+		return
+	}
+
+	err = m.validateSignature(req.Header, payload)
+	if err != nil {
+		logger.I("signature invalid", log.Error(err))
+		http.Error(w, "invalid hmac signature", http.StatusBadRequest)
+		return
+	}
+
+	evt := &webhookPayload{}
+	err = json.Unmarshal(payload, evt)
+	if err != nil {
+		logger.I("payload invalid", log.Error(err))
+		http.Error(w, "invalid webhook payload", http.StatusBadRequest)
+		return
+	}
+
+	eventType := req.Header.Get(headerGiteaEvent)
+	if eventType == "" {
+		eventType = req.Header.Get(headerForgejoEvent)
+	}
+
+	repo := func() string {
+		repo := evt.Repository.FullName
+		logger = logger.WithFields(log.String("repo", repo))
+
+		switch eventType {
+		case "issues":
+			logger.V("received issue event")
+
+			if evt.Issue == nil {
+				return ""
+			}
+
+			expectedTitle := m.getDashboardTitle(repo)
+			if expectedTitle == "" {
+				// no dashboard issue title provided, we may assume any issue with any title can trigger
+				// if they have checkbox (todo list)
+			} else if actualTitle := evt.Issue.Title; expectedTitle != actualTitle {
+				logger.D("issue event is not related to renovate dashboard issue",
+					log.String("expected", expectedTitle),
+					log.String("actual", actualTitle),
+				)
+				return ""
+			}
+
+			switch evt.Action {
+			case "edited":
+				logger.V("event is issue edited")
+			case "deleted", "closed", "reopened":
+				// dashboard issue state changed, ensure open
+				return repo
+			default:
+				return ""
+			}
+
+			if evt.Changes == nil || evt.Changes.Body == nil {
+				logger.V("issue body not changed")
+				return ""
+			}
+
+			logger.D("issue body changed, checking issue checkbox state")
+			if util.ItemChecked(evt.Changes.Body.From, evt.Issue.Body) {
+				return repo
+			}
+			return ""
+		case "pull_request":
+			logger.V("received pull request event")
+
+			if evt.PullRequest == nil {
+				return ""
+			}
+
+			switch evt.Action {
+			case "edited":
+				logger.V("event is pull request edited")
+			case "closed", "reopened":
+				return repo
+			default:
+				return ""
+			}
+
+			if evt.Changes == nil || evt.Changes.Body == nil {
+				logger.V("pull request body unchanged")
+				return ""
+			}
+
+			logger.V("pull request body changed")
+			if util.ItemChecked(evt.Changes.Body.From, evt.PullRequest.Body) {
+				return repo
+			}
+			return ""
+		case "push":
+			logger.V("received push event")
+
+			if reason := m.pushFilter.Match(pushInfo(evt)); reason != "" {
+				logger.D("push ignored", log.String("reason", reason))
+				return ""
+			}
+
+			return repo
+		default:
+			logger.V("ignored event", log.String("event", eventType))
+			return ""
+		}
+	}()
+	if repo == "" {
+		logger.I("no execution triggered")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if m.disabled(repo) {
+		logger.I("execution ignored")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	logger.I("scheduling renovate execution")
+
+	// run renovate against this repo
+	err = m.scheduler.Schedule(repo)
+	if err != nil {
+		logger.I("failed to schedule renovate execution", log.Error(err))
+		http.Error(w, "failed to execute renovate", http.StatusInternalServerError)
+		return
+	}
+
+	logger.I("scheduled renovate execution")
+	w.WriteHeader(http.StatusOK)
+}
+
+func pushInfo(evt *webhookPayload) *filter.Push {
+	p := &filter.Push{
+		Ref:           evt.Ref,
+		DefaultBranch: evt.Repository.DefaultBranch,
+	}
+
+	for _, u := range []*webhookUser{evt.Pusher, evt.Sender} {
+		if u != nil {
+			p.Users = append(p.Users, u.Login, u.UserName, u.FullName, u.Email)
+		}
+	}
+
+	// commit list is truncated when there are too many commits, changed files
+	// are unknown in that case
+	if len(evt.Commits) == 0 || evt.TotalCommits > len(evt.Commits) {
+		return p
+	}
+
+	p.ChangedFiles = []string{}
+	for _, c := range evt.Commits {
+		p.ChangedFiles = append(p.ChangedFiles, c.Added...)
+		p.ChangedFiles = append(p.ChangedFiles, c.Removed...)
+		p.ChangedFiles = append(p.ChangedFiles, c.Modified...)
+	}
+
+	return p
+}
+
+// validateSignature checks hex encoded hmac-sha256 signature of the payload
+func (m *Manager) validateSignature(header http.Header, payload []byte) error {
+	if len(m.webhookSecret) == 0 {
+		return nil
+	}
+
+	sig := header.Get(headerGiteaSignature)
+	if sig == "" {
+		sig = header.Get(headerForgejoSignature)
+	}
+
+	if sig == "" {
+		return fmt.Errorf("missing signature")
+	}
+
+	actual, err := hex.DecodeString(sig)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, m.webhookSecret)
+	_, _ = mac.Write(payload)
+	if !hmac.Equal(mac.Sum(nil), actual) {
+		return fmt.Errorf("signature mismatch")
+	}
+
+	return nil
+}
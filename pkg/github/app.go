@@ -0,0 +1,197 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v36/github"
+	"golang.org/x/oauth2"
+
+	"arhat.dev/renovate-server/pkg/conf"
+)
+
+const (
+	// github rejects jwt valid for more than 10 minutes
+	appJWTLifetime = 9 * time.Minute
+
+	// tolerate clock drift between us and github
+	appJWTClockSkew = time.Minute
+
+	// refresh installation token before it expires (valid for 1 hour)
+	installationTokenEarlyRefresh = 5 * time.Minute
+)
+
+// newInstallationTokenSource creates a token source minting installation access tokens
+// of the github app, tokens are cached and refreshed before expiry
+func newInstallationTokenSource(
+	ctx context.Context,
+	config *conf.GitHubAppConfig,
+	baseURL string,
+	client *http.Client,
+) (oauth2.TokenSource, error) {
+	if config.AppID == 0 {
+		return nil, fmt.Errorf("github app id is required")
+	}
+
+	pemBytes, err := ioutil.ReadFile(config.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read github app private key: %w", err)
+	}
+
+	key, err := parseRSAPrivateKey(pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid github app private key: %w", err)
+	}
+
+	ts := &appJWTSource{
+		appID: config.AppID,
+		key:   key,
+		mu:    new(sync.Mutex),
+	}
+
+	appClient, err := github.NewEnterpriseClient(baseURL, "", &http.Client{
+		Transport: &oauth2.Transport{
+			Source: ts,
+			Base:   client.Transport,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create github app api client: %w", err)
+	}
+	appClient.BaseURL, _ = url.Parse(baseURL)
+
+	return oauth2.ReuseTokenSource(nil, &installationTokenSource{
+		ctx:            ctx,
+		client:         appClient,
+		installationID: config.InstallationID,
+	}), nil
+}
+
+type installationTokenSource struct {
+	ctx    context.Context
+	client *github.Client
+
+	installationID int64
+}
+
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	if s.installationID == 0 {
+		installations, _, err := s.client.Apps.ListInstallations(s.ctx, &github.ListOptions{PerPage: 2})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list github app installations: %w", err)
+		}
+
+		if len(installations) != 1 {
+			return nil, fmt.Errorf("installation id is required, github app has %d installations", len(installations))
+		}
+
+		s.installationID = installations[0].GetID()
+	}
+
+	token, _, err := s.client.Apps.CreateInstallationToken(s.ctx, s.installationID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create github app installation token: %w", err)
+	}
+
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		Expiry:      token.GetExpiresAt().Add(-installationTokenEarlyRefresh),
+	}, nil
+}
+
+// appJWTSource signs jwt to authenticate as the github app
+type appJWTSource struct {
+	appID int64
+	key   *rsa.PrivateKey
+
+	token *oauth2.Token
+	mu    *sync.Mutex
+}
+
+func (s *appJWTSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.Valid() {
+		return s.token, nil
+	}
+
+	now := time.Now()
+	jwt, err := signRS256JWT(s.key, map[string]interface{}{
+		"iat": now.Add(-appJWTClockSkew).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": strconv.FormatInt(s.appID, 10),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.token = &oauth2.Token{
+		AccessToken: jwt,
+		// renew well before expiry
+		Expiry: now.Add(appJWTLifetime / 2),
+	}
+
+	return s.token, nil
+}
+
+func signRS256JWT(key *rsa.PrivateKey, claims map[string]interface{}) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign jwt: %w", err)
+	}
+
+	return signingInput + "." + enc.EncodeToString(sig), nil
+}
+
+// parseRSAPrivateKey parses pkcs1 or pkcs8 pem encoded rsa private key
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no pem block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	return rsaKey, nil
+}
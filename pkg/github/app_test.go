@@ -0,0 +1,167 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+)
+
+type fakeGitHubAppAPI struct {
+	t   *testing.T
+	key *rsa.PublicKey
+
+	tokenTTL time.Duration
+
+	mu           sync.Mutex
+	tokensIssued int
+}
+
+func (f *fakeGitHubAppAPI) verifyJWT(r *http.Request) bool {
+	parts := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ".")
+	if len(parts) != 3 {
+		return false
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(f.key, crypto.SHA256, digest[:], sig) != nil {
+		return false
+	}
+
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims map[string]interface{}
+	_ = json.Unmarshal(payload, &claims)
+	return claims["iss"] == "1234"
+}
+
+func (f *fakeGitHubAppAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/app/installations":
+		if !f.verifyJWT(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"id":42}]`))
+	case r.Method == http.MethodPost && r.URL.Path == "/app/installations/42/access_tokens":
+		if !f.verifyJWT(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		f.tokensIssued++
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"token":      fmt.Sprintf("ghs_%d", f.tokensIssued),
+			"expires_at": time.Now().Add(f.tokenTTL),
+		})
+	case r.URL.Path == "/installation/repositories":
+		if r.Header.Get("Authorization") != fmt.Sprintf("Bearer ghs_%d", f.tokensIssued) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Query().Get("page") == "2" {
+			_, _ = w.Write([]byte(`{"total_count":2,"repositories":[{"full_name":"foo/baz"}]}`))
+			return
+		}
+
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s/installation/repositories?page=2>; rel="next"`, r.Host))
+		_, _ = w.Write([]byte(`{"total_count":2,"repositories":[{"full_name":"foo/bar"}]}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newFakeGitHubApp(t *testing.T, tokenTTL time.Duration) (*fakeGitHubAppAPI, *conf.PlatformConfig) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "app.pem")
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	api := &fakeGitHubAppAPI{t: t, key: &key.PublicKey, tokenTTL: tokenTTL}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	return api, &conf.PlatformConfig{
+		API: conf.APIConfig{
+			BaseURL: srv.URL + "/",
+			App: &conf.GitHubAppConfig{
+				AppID:          1234,
+				PrivateKeyFile: keyFile,
+			},
+		},
+	}
+}
+
+func TestManager_GitHubApp(t *testing.T) {
+	api, config := newFakeGitHubApp(t, time.Hour)
+
+	mgr, err := NewManager(context.TODO(), config, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	repos, err := mgr.ListRepos()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"foo/bar", "foo/baz"}, repos)
+
+	args, err := mgr.ExecutionArgs("foo/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "ghs_1", args.APIToken)
+
+	// cached
+	assert.Equal(t, 1, api.tokensIssued)
+}
+
+func TestManager_GitHubApp_Refresh(t *testing.T) {
+	// expires within early refresh period
+	api, config := newFakeGitHubApp(t, installationTokenEarlyRefresh-time.Minute)
+
+	mgr, err := NewManager(context.TODO(), config, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for i := 1; i <= 2; i++ {
+		args, err := mgr.ExecutionArgs("foo/bar")
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("ghs_%d", i), args.APIToken)
+	}
+
+	assert.Equal(t, 2, api.tokensIssued)
+}
@@ -0,0 +1,155 @@
+package github
+
+import (
+	"fmt"
+
+	"arhat.dev/pkg/log"
+	"github.com/google/go-github/v36/github"
+
+	"arhat.dev/renovate-server/pkg/command"
+	"arhat.dev/renovate-server/pkg/constant"
+)
+
+// default roles allowed to use slash commands
+var defaultCommandRoles = []string{"admin", "write"}
+
+// handleCommand handles slash command in issue comment, returns the repo to run
+// renovate against and the func to acknowledge the command once scheduled, the
+// returned repo has been checked not ignored
+func (m *Manager) handleCommand(logger log.Interface, evt *github.IssueCommentEvent) (string, func()) {
+	if evt.GetAction() != "created" || evt.GetComment().GetUser().GetType() == "Bot" {
+		return "", nil
+	}
+
+	cmd, ok := command.Parse(m.commandPrefix, evt.GetComment().GetBody())
+	if !ok {
+		logger.V("no command in comment")
+		return "", nil
+	}
+
+	var (
+		repo   = evt.GetRepo().GetFullName()
+		owner  = evt.GetRepo().GetOwner().GetLogin()
+		name   = evt.GetRepo().GetName()
+		number = evt.GetIssue().GetNumber()
+		user   = evt.GetComment().GetUser().GetLogin()
+	)
+
+	logger = logger.WithFields(
+		log.String("repo", repo),
+		log.String("command", cmd),
+		log.String("user", user),
+	)
+	logger.V("received command")
+
+	reply := func(msg string) {
+		_, _, err := m.client.Issues.CreateComment(m.ctx, owner, name, number, &github.IssueComment{
+			Body: github.String(fmt.Sprintf("@%s %s", user, msg)),
+		})
+		if err != nil {
+			logger.I("failed to reply to command", log.Error(err))
+		}
+	}
+
+	// commands in ignored repos are not answered and never change anything
+	ignored, err := m.ignored(logger, repo)
+	if err != nil || ignored {
+		return "", nil
+	}
+
+	perm, _, err := m.client.Repositories.GetPermissionLevel(m.ctx, owner, name, user)
+	if err != nil {
+		logger.I("failed to get user permission", log.Error(err))
+		reply("failed to check your permission, please try again later")
+		return "", nil
+	}
+
+	if !command.Allowed(perm.GetPermission(), m.commandRoles) {
+		logger.I("command not allowed", log.String("permission", perm.GetPermission()))
+		reply(fmt.Sprintf("you are not allowed to use `%s` commands", m.commandPrefix))
+		return "", nil
+	}
+
+	if !command.Supported(cmd) {
+		reply(fmt.Sprintf("unknown command `%s`, supported commands are `%s`, `%s` and `%s`",
+			cmd, command.Run, command.Rebase, command.Recreate))
+		return "", nil
+	}
+
+	switch cmd {
+	case command.Rebase, command.Recreate:
+		if !evt.GetIssue().IsPullRequest() {
+			reply(fmt.Sprintf("`%s` is only available in renovate pull requests", cmd))
+			return "", nil
+		}
+
+		if cmd == command.Rebase {
+			err = m.checkRebase(owner, name, number, evt.GetIssue().GetBody())
+		} else {
+			err = m.checkRecreate(owner, name, number)
+		}
+
+		if err != nil {
+			logger.I("failed to apply command", log.Error(err))
+			reply(fmt.Sprintf("failed to %s: %v", cmd, err))
+			return "", nil
+		}
+	}
+
+	return repo, func() {
+		_, _, err2 := m.client.Reactions.CreateIssueCommentReaction(
+			m.ctx, owner, name, evt.GetComment().GetID(), "+1",
+		)
+		if err2 != nil {
+			logger.I("failed to acknowledge command", log.Error(err2))
+		}
+	}
+}
+
+// checkRebase checks the rebase checkbox of the renovate pull request
+func (m *Manager) checkRebase(owner, repo string, number int, body string) error {
+	body, ok := command.CheckRebase(body)
+	if !ok {
+		return fmt.Errorf("not a renovate pull request")
+	}
+
+	_, _, err := m.client.Issues.Edit(m.ctx, owner, repo, number, &github.IssueRequest{Body: &body})
+	return err
+}
+
+// checkRecreate checks the recreate checkbox of the pull request branch in
+// the dependency dashboard
+func (m *Manager) checkRecreate(owner, repo string, number int) error {
+	pr, _, err := m.client.PullRequests.Get(m.ctx, owner, repo, number)
+	if err != nil {
+		return fmt.Errorf("failed to get pull request: %w", err)
+	}
+
+	branch := pr.GetHead().GetRef()
+	opts := &github.IssueListByRepoOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: constant.MaxAPIPageSize},
+	}
+	for {
+		issues, resp, err := m.client.Issues.ListByRepo(m.ctx, owner, repo, opts)
+		if err != nil {
+			return fmt.Errorf("failed to list issues: %w", err)
+		}
+
+		for _, issue := range issues {
+			body, ok := command.CheckRecreate(issue.GetBody(), branch)
+			if !ok {
+				continue
+			}
+
+			_, _, err = m.client.Issues.Edit(m.ctx, owner, repo, issue.GetNumber(), &github.IssueRequest{Body: &body})
+			return err
+		}
+
+		if resp.NextPage == 0 {
+			return fmt.Errorf("branch %q not found in dependency dashboard", branch)
+		}
+
+		opts.Page = resp.NextPage
+	}
+}
@@ -0,0 +1,192 @@
+package github
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+)
+
+// fakeCommandAPI records requests made when handling slash commands
+type fakeCommandAPI struct {
+	permission string
+
+	mu       sync.Mutex
+	requests []string
+	bodies   []string
+}
+
+func (f *fakeCommandAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, _ := io.ReadAll(r.Body)
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	f.bodies = append(f.bodies, string(data))
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/permission"):
+		_, _ = w.Write([]byte(`{"permission":"` + f.permission + `"}`))
+	default:
+		_, _ = w.Write([]byte(`{}`))
+	}
+}
+
+func TestManager_ServeHTTP_Command(t *testing.T) {
+	const prBody = " - [ ] <!-- rebase-check -->If you want to rebase, check this box"
+
+	tests := []struct {
+		name       string
+		permission string
+		comment    string
+		pr         bool
+
+		disabled         bool
+		includeNameMatch string
+
+		scheduled bool
+		requests  []string
+		body      string
+	}{
+		{
+			name:       "Run",
+			permission: "write",
+			comment:    "/renovate",
+			scheduled:  true,
+			requests: []string{
+				"GET /repos/foo/bar/collaborators/alice/permission",
+				"POST /repos/foo/bar/issues/comments/2/reactions",
+			},
+		},
+		{
+			name:       "Rebase",
+			permission: "admin",
+			comment:    "/renovate rebase",
+			pr:         true,
+			scheduled:  true,
+			requests: []string{
+				"GET /repos/foo/bar/collaborators/alice/permission",
+				"PATCH /repos/foo/bar/issues/1",
+				"POST /repos/foo/bar/issues/comments/2/reactions",
+			},
+			body: "[x] <!-- rebase-check -->",
+		},
+		{
+			name:       "Rebase Issue",
+			permission: "write",
+			comment:    "/renovate rebase",
+			requests: []string{
+				"GET /repos/foo/bar/collaborators/alice/permission",
+				"POST /repos/foo/bar/issues/1/comments",
+			},
+			body: "only available in renovate pull requests",
+		},
+		{
+			name:       "Not Allowed",
+			permission: "read",
+			comment:    "/renovate run",
+			requests: []string{
+				"GET /repos/foo/bar/collaborators/alice/permission",
+				"POST /repos/foo/bar/issues/1/comments",
+			},
+			body: "you are not allowed",
+		},
+		{
+			name:       "Unknown",
+			permission: "write",
+			comment:    "/renovate merge",
+			requests: []string{
+				"GET /repos/foo/bar/collaborators/alice/permission",
+				"POST /repos/foo/bar/issues/1/comments",
+			},
+			body: "unknown command `merge`",
+		},
+		{
+			name:       "Unknown Not Allowed",
+			permission: "read",
+			comment:    "/renovate merge",
+			requests: []string{
+				"GET /repos/foo/bar/collaborators/alice/permission",
+				"POST /repos/foo/bar/issues/1/comments",
+			},
+			body: "you are not allowed",
+		},
+		{
+			name:       "Disabled",
+			permission: "write",
+			comment:    "/renovate rebase",
+			pr:         true,
+			disabled:   true,
+		},
+		{
+			name:             "Excluded",
+			permission:       "write",
+			comment:          "/renovate rebase",
+			pr:               true,
+			includeNameMatch: "^other/",
+		},
+		{
+			name:    "No Command",
+			comment: "lgtm",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api := &fakeCommandAPI{permission: test.permission}
+			srv := httptest.NewServer(api)
+			defer srv.Close()
+
+			scheduler := &fakeScheduler{}
+			config := &conf.PlatformConfig{
+				API: conf.APIConfig{
+					BaseURL:    srv.URL + "/",
+					OAuthToken: "test-token",
+				},
+				Projects: []conf.ProjectConfig{{Name: "foo/bar", Disabled: test.disabled}},
+			}
+			config.Filter.IncludeNameMatch = test.includeNameMatch
+
+			mgr, err := NewManager(context.TODO(), config, scheduler)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			pr := ""
+			if test.pr {
+				pr = `"pull_request":{"url":"foo"},`
+			}
+
+			payload := `{"action":"created",` +
+				`"repository":{"full_name":"foo/bar","name":"bar","owner":{"login":"foo"}},` +
+				`"issue":{` + pr + `"number":1,"body":"` + prBody + `"},` +
+				`"comment":{"id":2,"body":"` + test.comment + `","user":{"login":"alice","type":"User"}}}`
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-GitHub-Event", "issue_comment")
+
+			rec := httptest.NewRecorder()
+			mgr.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			if test.scheduled {
+				assert.Equal(t, []string{"foo/bar"}, scheduler.repos)
+			} else {
+				assert.Empty(t, scheduler.repos)
+			}
+
+			assert.Equal(t, test.requests, api.requests)
+			if test.body != "" {
+				assert.Contains(t, strings.Join(api.bodies, "\n"), test.body)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"strings"
 
 	"arhat.dev/pkg/log"
 	"github.com/google/go-github/v36/github"
@@ -13,8 +14,11 @@ import (
 
 	"arhat.dev/renovate-server/pkg/conf"
 	"arhat.dev/renovate-server/pkg/constant"
+	"arhat.dev/renovate-server/pkg/filter"
+	"arhat.dev/renovate-server/pkg/metrics"
 	"arhat.dev/renovate-server/pkg/types"
 	"arhat.dev/renovate-server/pkg/util"
+	"arhat.dev/renovate-server/pkg/validator"
 )
 
 func NewManager(
@@ -34,20 +38,27 @@ func NewManager(
 		}
 	}
 
-	client, err := config.API.Client.NewClient()
+	repoFilter, err := filter.New(&config.Filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create http client")
+		return nil, err
 	}
 
-	var transport http.RoundTripper
-	if o := config.API.OAuthToken; o != "" {
-		ts := oauth2.StaticTokenSource(&oauth2.Token{
-			AccessToken: o,
-		})
+	pushFilter, err := filter.NewPushFilter(&config.Push, &config.Git)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push filter: %w", err)
+	}
 
-		transport = oauth2.NewClient(context.WithValue(ctx, oauth2.HTTPClient, client), ts).Transport
-	} else {
-		return nil, fmt.Errorf("no oauth token provided")
+	statusContext := config.ConfigValidation.StatusContext
+	if statusContext == "" {
+		statusContext = constant.DefaultConfigValidationStatusContext
+	}
+
+	// executors may validate config with renovate-config-validator
+	externalValidator, _ := scheduler.(types.ConfigValidator)
+
+	client, err := config.API.Client.NewClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http client")
 	}
 
 	baseURL := config.API.BaseURL
@@ -55,6 +66,25 @@ func NewManager(
 		baseURL = constant.DefaultGitHubAPIBaseURL
 	}
 
+	var ts oauth2.TokenSource
+	switch {
+	case config.API.App != nil:
+		ts, err = newInstallationTokenSource(ctx, config.API.App, baseURL, client)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create github app token source: %w", err)
+		}
+	case config.API.OAuthToken != "":
+		ts = oauth2.StaticTokenSource(&oauth2.Token{
+			AccessToken: config.API.OAuthToken,
+		})
+	default:
+		return nil, fmt.Errorf("no oauth token or github app provided")
+	}
+
+	transport := metrics.RateLimitTransport("github", baseURL,
+		oauth2.NewClient(context.WithValue(ctx, oauth2.HTTPClient, client), ts).Transport,
+	)
+
 	ghClient, err := github.NewEnterpriseClient(baseURL, "", &http.Client{
 		Transport:     transport,
 		CheckRedirect: nil,
@@ -67,6 +97,16 @@ func NewManager(
 
 	ghClient.BaseURL, _ = url.Parse(baseURL)
 
+	commandPrefix := config.Commands.Prefix
+	if commandPrefix == "" {
+		commandPrefix = constant.DefaultCommandPrefix
+	}
+
+	commandRoles := config.Commands.AllowedRoles
+	if len(commandRoles) == 0 {
+		commandRoles = defaultCommandRoles
+	}
+
 	dashboardTitles := make(map[string]string)
 	disabledRepos := make(map[string]struct{})
 	for _, p := range config.Projects {
@@ -90,13 +130,24 @@ func NewManager(
 		defaultDashboardTitle: config.DashboardIssueTitle,
 		dashboardTitles:       dashboardTitles,
 		disabledRepos:         disabledRepos,
+		filter:                repoFilter,
+		pushFilter:            pushFilter,
+
+		validateConfig:   config.ConfigValidation.Enabled,
+		validator:        validator.New(externalValidator),
+		statusContext:    statusContext,
+		commentOnFailure: config.ConfigValidation.CommentOnFailure,
 
-		apiURL:   baseURL,
-		apiToken: config.API.OAuthToken,
-		gitUser:  config.Git.User,
-		gitEmail: config.Git.Email,
+		apiURL:      baseURL,
+		tokenSource: ts,
+		isApp:       config.API.App != nil,
+		gitUser:     config.Git.User,
+		gitEmail:    config.Git.Email,
 
 		webhookSecret: []byte(config.Webhook.Secret),
+
+		commandPrefix: commandPrefix,
+		commandRoles:  commandRoles,
 	}, nil
 }
 
@@ -111,13 +162,24 @@ type Manager struct {
 	defaultDashboardTitle string
 	dashboardTitles       map[string]string
 	disabledRepos         map[string]struct{}
+	filter                *filter.Filter
+	pushFilter            *filter.PushFilter
+
+	validateConfig   bool
+	validator        *validator.Validator
+	statusContext    string
+	commentOnFailure bool
 
-	apiURL   string
-	apiToken string
-	gitUser  string
-	gitEmail string
+	apiURL      string
+	tokenSource oauth2.TokenSource
+	isApp       bool
+	gitUser     string
+	gitEmail    string
 
 	webhookSecret []byte
+
+	commandPrefix string
+	commandRoles  []string
 }
 
 func (m *Manager) getDashboardTitle(repo string) string {
@@ -125,17 +187,15 @@ func (m *Manager) getDashboardTitle(repo string) string {
 }
 
 func (m *Manager) ListRepos() ([]string, error) {
-	repos, _, err := m.client.Repositories.List(m.ctx, "", &github.RepositoryListOptions{
-		Visibility:  "",
-		Affiliation: "",
-		Type:        "",
-		Sort:        "",
-		Direction:   "",
-		ListOptions: github.ListOptions{
-			Page:    0,
-			PerPage: 0,
-		},
-	})
+	var (
+		repos []*github.Repository
+		err   error
+	)
+	if m.isApp {
+		repos, err = m.listInstallationRepos()
+	} else {
+		repos, err = m.listUserRepos()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to list all repos: %w", err)
 	}
@@ -153,19 +213,144 @@ func (m *Manager) ListRepos() ([]string, error) {
 			continue
 		}
 
+		if reason := m.filter.Match(repoInfo(repo)); reason != "" {
+			m.logger.V("repo excluded", log.String("repo", name), log.String("reason", reason))
+			continue
+		}
+
 		ret = append(ret, name)
 	}
 
 	return ret, nil
 }
 
-func (m *Manager) ExecutionArgs(repos ...string) types.ExecutionArgs {
+// listUserRepos lists repos accessible to the authenticated user
+func (m *Manager) listUserRepos() ([]*github.Repository, error) {
+	var ret []*github.Repository
+	opts := &github.RepositoryListOptions{
+		ListOptions: github.ListOptions{PerPage: constant.MaxAPIPageSize},
+	}
+	for {
+		var (
+			repos []*github.Repository
+			resp  *github.Response
+		)
+		err := m.withRateLimitRetry(func() (err error) {
+			repos, resp, err = m.client.Repositories.List(m.ctx, "", opts)
+			return
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, repos...)
+		if resp.NextPage == 0 {
+			return ret, nil
+		}
+
+		opts.Page = resp.NextPage
+	}
+}
+
+// listInstallationRepos lists repos accessible to the github app installation
+func (m *Manager) listInstallationRepos() ([]*github.Repository, error) {
+	var ret []*github.Repository
+	opts := &github.ListOptions{PerPage: constant.MaxAPIPageSize}
+	for {
+		var (
+			result *github.ListRepositories
+			resp   *github.Response
+		)
+		err := m.withRateLimitRetry(func() (err error) {
+			result, resp, err = m.client.Apps.ListRepos(m.ctx, opts)
+			return
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, result.Repositories...)
+		if resp.NextPage == 0 {
+			return ret, nil
+		}
+
+		opts.Page = resp.NextPage
+	}
+}
+
+// excluded returns the reason why the repo is excluded by the filter, empty if included
+func (m *Manager) excluded(repo string) (string, error) {
+	if reason := m.filter.MatchName(repo); reason != "" || !m.filter.NeedsMetadata() {
+		return reason, nil
+	}
+
+	parts := strings.SplitN(repo, "/", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid repo name %q", repo)
+	}
+
+	var r *github.Repository
+	err := m.withRateLimitRetry(func() (err error) {
+		r, _, err = m.client.Repositories.Get(m.ctx, parts[0], parts[1])
+		return
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get repo: %w", err)
+	}
+
+	return m.filter.Match(repoInfo(r)), nil
+}
+
+func repoInfo(r *github.Repository) *filter.Repo {
+	visibility := r.GetVisibility()
+	if visibility == "" {
+		visibility = "public"
+		if r.GetPrivate() {
+			visibility = "private"
+		}
+	}
+
+	return &filter.Repo{
+		Name:       r.GetFullName(),
+		Topics:     r.Topics,
+		Visibility: visibility,
+		Fork:       r.GetFork(),
+		Archived:   r.GetArchived(),
+		Empty:      r.GetSize() == 0,
+	}
+}
+
+// CheckHealth checks credentials with github api
+func (m *Manager) CheckHealth(ctx context.Context) error {
+	var err error
+	if m.isApp {
+		_, _, err = m.client.Apps.ListRepos(ctx, &github.ListOptions{PerPage: 1})
+	} else {
+		_, _, err = m.client.Users.Get(ctx, "")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to authenticate with github api: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Manager) APIURL() string {
+	return m.apiURL
+}
+
+func (m *Manager) ExecutionArgs(repos ...string) (types.ExecutionArgs, error) {
+	token, err := m.tokenSource.Token()
+	if err != nil {
+		return types.ExecutionArgs{}, fmt.Errorf("failed to get api token: %w", err)
+	}
+
 	return types.ExecutionArgs{
 		Platform: "github",
 		APIURL:   m.apiURL,
-		APIToken: m.apiToken,
+		APIToken: token.AccessToken,
 		Repos:    repos,
 		GitUser:  m.gitUser,
 		GitEmail: m.gitEmail,
-	}
+	}, nil
 }
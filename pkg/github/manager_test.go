@@ -0,0 +1,243 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v36/github"
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+)
+
+type fakeScheduler struct {
+	repos []string
+}
+
+func (s *fakeScheduler) Schedule(repos ...string) error {
+	s.repos = append(s.repos, repos...)
+	return nil
+}
+
+// fakeReposAPI serves /user/repos in pages, rate limits requests as configured
+type fakeReposAPI struct {
+	total int
+
+	mu sync.Mutex
+	// responds with rate limit error to the next request
+	rateLimitReset time.Time
+	abuseRateLimit bool
+	requests       int
+}
+
+func (f *fakeReposAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests++
+	if r.URL.Path != "/user/repos" {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case !f.rateLimitReset.IsZero():
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(f.rateLimitReset.Unix(), 10))
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"message":"API rate limit exceeded"}`))
+		if time.Until(f.rateLimitReset) < time.Hour {
+			f.rateLimitReset = time.Time{}
+		}
+		return
+	case f.abuseRateLimit:
+		f.abuseRateLimit = false
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"message":"slow down",` +
+			`"documentation_url":"https://docs.github.com/rest/overview/resources-in-the-rest-api#abuse-rate-limits"}`))
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page == 0 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+
+	start, end := (page-1)*perPage, page*perPage
+	if end >= f.total {
+		end = f.total
+	} else {
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?page=%d&per_page=%d>; rel="next"`,
+			r.Host, r.URL.Path, page+1, perPage))
+	}
+
+	var repos []map[string]string
+	for i := start; i < end; i++ {
+		repos = append(repos, map[string]string{"full_name": fmt.Sprintf("foo/%d", i)})
+	}
+	_ = json.NewEncoder(w).Encode(repos)
+}
+
+func newTestManager(t *testing.T, baseURL string) *Manager {
+	mgr, err := NewManager(context.TODO(), &conf.PlatformConfig{
+		API: conf.APIConfig{
+			BaseURL:    baseURL,
+			OAuthToken: "test-token",
+		},
+		DisabledRepoNameMatch: "^foo/1$",
+	}, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return mgr.(*Manager)
+}
+
+func TestManager_ListRepos(t *testing.T) {
+	t.Run("Pages", func(t *testing.T) {
+		api := &fakeReposAPI{total: 250}
+		srv := httptest.NewServer(api)
+		defer srv.Close()
+
+		repos, err := newTestManager(t, srv.URL+"/").ListRepos()
+		assert.NoError(t, err)
+		assert.Len(t, repos, 249)
+		assert.NotContains(t, repos, "foo/1")
+		assert.Contains(t, repos, "foo/249")
+		assert.Equal(t, 3, api.requests)
+	})
+
+	t.Run("Wait For Reset", func(t *testing.T) {
+		api := &fakeReposAPI{total: 10, rateLimitReset: time.Now(), abuseRateLimit: true}
+		srv := httptest.NewServer(api)
+		defer srv.Close()
+
+		repos, err := newTestManager(t, srv.URL+"/").ListRepos()
+		assert.NoError(t, err)
+		assert.Len(t, repos, 9)
+		assert.Equal(t, 3, api.requests)
+	})
+
+	t.Run("Reset Too Late", func(t *testing.T) {
+		api := &fakeReposAPI{total: 10, rateLimitReset: time.Now().Add(2 * time.Hour)}
+		srv := httptest.NewServer(api)
+		defer srv.Close()
+
+		_, err := newTestManager(t, srv.URL+"/").ListRepos()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "rate limit exceeded, resets in")
+		}
+		assert.Equal(t, 1, api.requests)
+	})
+}
+
+func TestManager_ServeHTTP_Filter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/foo/bar":
+			_, _ = w.Write([]byte(`{"full_name":"foo/bar","size":1,"topics":["renovate"]}`))
+		case "/repos/foo/baz":
+			_, _ = w.Write([]byte(`{"full_name":"foo/baz","size":1,"topics":["other"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	scheduler := &fakeScheduler{}
+	mgr, err := NewManager(context.TODO(), &conf.PlatformConfig{
+		API: conf.APIConfig{
+			BaseURL:    srv.URL + "/",
+			OAuthToken: "test-token",
+		},
+		Filter: conf.RepoFilterConfig{
+			Owners: []string{"foo"},
+			Topics: []string{"renovate"},
+		},
+	}, scheduler)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, repo := range []string{"foo/bar", "foo/baz", "other/bar"} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"repository":{"full_name":"`+repo+`"}}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", "push")
+
+		rec := httptest.NewRecorder()
+		mgr.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	assert.Equal(t, []string{"foo/bar"}, scheduler.repos)
+}
+
+func TestManager_ServeHTTP_Push(t *testing.T) {
+	scheduler := &fakeScheduler{}
+	mgr, err := NewManager(context.TODO(), &conf.PlatformConfig{
+		API: conf.APIConfig{OAuthToken: "test-token"},
+		Git: conf.GitConfig{User: "renovate-bot"},
+	}, scheduler)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, payload := range []string{
+		// push to default branch
+		`{"ref":"refs/heads/main","repository":{"full_name":"foo/bar","default_branch":"main"},` +
+			`"pusher":{"name":"dev"},"sender":{"login":"dev"}}`,
+		// push to renovate branch
+		`{"ref":"refs/heads/renovate/foo-1.x","repository":{"full_name":"foo/baz","default_branch":"main"},` +
+			`"pusher":{"name":"dev"},"sender":{"login":"dev"}}`,
+		// push by renovate
+		`{"ref":"refs/heads/main","repository":{"full_name":"foo/baz","default_branch":"main"},` +
+			`"pusher":{"name":"renovate-bot"},"sender":{"login":"renovate-bot"}}`,
+		// tag push
+		`{"ref":"refs/tags/v1.0.0","repository":{"full_name":"foo/baz","default_branch":"main"},` +
+			`"pusher":{"name":"dev"},"sender":{"login":"dev"}}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", "push")
+
+		rec := httptest.NewRecorder()
+		mgr.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	assert.Equal(t, []string{"foo/bar"}, scheduler.repos)
+}
+
+func TestPushInfo(t *testing.T) {
+	commit := func(id string) *github.HeadCommit {
+		return &github.HeadCommit{ID: github.String(id), Modified: []string{id + ".json"}}
+	}
+
+	evt := &github.PushEvent{
+		Commits:    []*github.HeadCommit{commit("a"), commit("b")},
+		HeadCommit: commit("b"),
+	}
+	assert.Equal(t, []string{"a.json", "b.json"}, pushInfo(evt).ChangedFiles)
+
+	// head commit not in the list
+	evt.HeadCommit = commit("c")
+	assert.Nil(t, pushInfo(evt).ChangedFiles)
+
+	// list at the payload limit
+	evt.Commits = nil
+	for i := 0; i < maxPushEventCommits; i++ {
+		evt.Commits = append(evt.Commits, commit(strconv.Itoa(i)))
+	}
+	evt.HeadCommit = evt.Commits[len(evt.Commits)-1]
+	assert.Nil(t, pushInfo(evt).ChangedFiles)
+}
@@ -0,0 +1,69 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"arhat.dev/pkg/log"
+	"github.com/google/go-github/v36/github"
+
+	"arhat.dev/renovate-server/pkg/constant"
+)
+
+// max retries of a single request after waiting for rate limit reset
+const rateLimitRetries = 3
+
+// withRateLimitRetry calls fn and retries when rate limited
+func (m *Manager) withRateLimitRetry(fn func() error) error {
+	for i := 0; ; i++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		if i >= rateLimitRetries {
+			return err
+		}
+
+		err = m.waitRateLimit(err)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// waitRateLimit waits until the rate limit resets if err is caused by rate limit
+// and the reset is soon, otherwise returns an error
+func (m *Manager) waitRateLimit(err error) error {
+	var (
+		wait time.Duration
+
+		rateLimitErr      *github.RateLimitError
+		abuseRateLimitErr *github.AbuseRateLimitError
+	)
+	switch {
+	case errors.As(err, &rateLimitErr):
+		wait = time.Until(rateLimitErr.Rate.Reset.Time)
+	case errors.As(err, &abuseRateLimitErr):
+		wait = abuseRateLimitErr.GetRetryAfter()
+	default:
+		return err
+	}
+
+	if wait > constant.MaxAPIRateLimitWait {
+		return fmt.Errorf("github api rate limit exceeded, resets in %s: %w", wait.Round(time.Second), err)
+	}
+
+	m.logger.I("github api rate limit exceeded, waiting for reset", log.Duration("wait", wait))
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-m.ctx.Done():
+		return m.ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
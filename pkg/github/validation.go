@@ -0,0 +1,105 @@
+package github
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"arhat.dev/pkg/log"
+	"github.com/google/go-github/v36/github"
+
+	"arhat.dev/renovate-server/pkg/validator"
+)
+
+// checkConfigChanges validates renovate config files changed by the push in background
+func (m *Manager) checkConfigChanges(logger log.Interface, evt *github.PushEvent, changedFiles []string) {
+	if !m.validateConfig || evt.GetDeleted() || strings.HasPrefix(evt.GetRef(), "refs/tags/") {
+		return
+	}
+
+	files := validator.ChangedConfigFiles(changedFiles)
+	if len(files) == 0 {
+		return
+	}
+
+	logger.V("renovate config changed", log.Strings("files", files))
+	go m.validateConfigFiles(logger,
+		evt.GetRepo().GetOwner().GetLogin(), evt.GetRepo().GetName(), evt.GetAfter(), files,
+	)
+}
+
+// validateConfigFiles validates renovate config files at the commit, reports the result
+// as commit status
+func (m *Manager) validateConfigFiles(logger log.Interface, owner, repo, sha string, files []string) {
+	setStatus := func(state, description string) {
+		_, _, err := m.client.Repositories.CreateStatus(m.ctx, owner, repo, sha, &github.RepoStatus{
+			State:       github.String(state),
+			Description: github.String(description),
+			Context:     github.String(m.statusContext),
+		})
+		if err != nil {
+			logger.I("failed to set commit status", log.Error(err))
+		}
+	}
+
+	report := &validator.Report{}
+	for _, f := range files {
+		content, _, resp, err := m.client.Repositories.GetContents(m.ctx, owner, repo, f,
+			&github.RepositoryContentGetOptions{Ref: sha},
+		)
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				// removed
+				continue
+			}
+
+			logger.I("failed to get renovate config file", log.String("file", f), log.Error(err))
+			setStatus("error", "failed to get renovate config")
+			return
+		}
+
+		data, err := content.GetContent()
+		if err != nil {
+			logger.I("failed to decode renovate config file", log.String("file", f), log.Error(err))
+			setStatus("error", "failed to get renovate config")
+			return
+		}
+
+		problem, err := m.validator.Validate(f, []byte(data))
+		if err != nil {
+			if errors.Is(err, validator.ErrNoConfig) {
+				continue
+			}
+
+			logger.I("failed to validate renovate config", log.String("file", f), log.Error(err))
+			setStatus("error", "failed to validate renovate config")
+			return
+		}
+
+		report.Add(f, problem)
+	}
+
+	if report.Empty() {
+		return
+	}
+
+	if report.Valid() {
+		logger.V("renovate config valid")
+		setStatus("success", report.Summary())
+		return
+	}
+
+	logger.I("renovate config invalid", log.String("summary", report.Summary()))
+	setStatus("failure", report.Summary())
+
+	if !m.commentOnFailure {
+		return
+	}
+
+	_, _, err := m.client.Repositories.CreateComment(m.ctx, owner, repo, sha, &github.RepositoryComment{
+		Body: github.String(report.Details()),
+	})
+	if err != nil {
+		logger.I("failed to comment on commit", log.Error(err))
+	}
+}
@@ -0,0 +1,113 @@
+package github
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"arhat.dev/pkg/log"
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+)
+
+// fakeContentsAPI serves file contents and records other requests
+type fakeContentsAPI struct {
+	files map[string]string
+
+	mu       sync.Mutex
+	requests []string
+	bodies   []string
+}
+
+func (f *fakeContentsAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if file := strings.TrimPrefix(r.URL.Path, "/repos/foo/bar/contents/"); file != r.URL.Path {
+		content, ok := f.files[file]
+		if !ok || r.URL.Query().Get("ref") != "abc" {
+			http.NotFound(w, r)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"type":"file","encoding":"base64","content":"` +
+			base64.StdEncoding.EncodeToString([]byte(content)) + `"}`))
+		return
+	}
+
+	data, _ := io.ReadAll(r.Body)
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	f.bodies = append(f.bodies, string(data))
+	_, _ = w.Write([]byte(`{}`))
+}
+
+func TestManager_validateConfigFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+
+		requests []string
+		state    string
+	}{
+		{
+			name:     "Valid",
+			files:    map[string]string{"renovate.json5": `{extends: ['config:base']}`},
+			requests: []string{"POST /repos/foo/bar/statuses/abc"},
+			state:    `"state":"success"`,
+		},
+		{
+			name:  "Invalid",
+			files: map[string]string{"renovate.json5": `{extends: }`},
+			requests: []string{
+				"POST /repos/foo/bar/statuses/abc",
+				"POST /repos/foo/bar/commits/abc/comments",
+			},
+			state: `"state":"failure"`,
+		},
+		{
+			name:  "No Config",
+			files: map[string]string{"package.json": `{"name":"bar"}`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api := &fakeContentsAPI{files: test.files}
+			srv := httptest.NewServer(api)
+			defer srv.Close()
+
+			mgr, err := NewManager(context.TODO(), &conf.PlatformConfig{
+				API: conf.APIConfig{
+					BaseURL:    srv.URL + "/",
+					OAuthToken: "test-token",
+				},
+				ConfigValidation: conf.ConfigValidationConfig{
+					Enabled:          true,
+					CommentOnFailure: true,
+				},
+			}, nil)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			var files []string
+			for f := range test.files {
+				files = append(files, f)
+			}
+
+			mgr.(*Manager).validateConfigFiles(log.NoOpLogger, "foo", "bar", "abc", files)
+
+			assert.Equal(t, test.requests, api.requests)
+			if test.state != "" {
+				assert.Contains(t, api.bodies[0], test.state)
+				assert.Contains(t, api.bodies[0], `"context":"renovate-server/config"`)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"arhat.dev/pkg/log"
 	"github.com/google/go-github/v36/github"
 
+	"arhat.dev/renovate-server/pkg/filter"
 	"arhat.dev/renovate-server/pkg/util"
 )
 
@@ -35,8 +36,24 @@ func (m *Manager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	var (
+		// acknowledges slash command after scheduled
+		ack func()
+		// repo already checked not ignored
+		checked bool
+		// validates changed renovate config files if the push is not ignored
+		validate func()
+	)
+
 	repo := func() string {
 		switch evt := ev.(type) {
+		case *github.IssueCommentEvent:
+			logger.V("received issue comment event")
+
+			var repo string
+			repo, ack = m.handleCommand(logger, evt)
+			checked = true
+			return repo
 		case *github.IssuesEvent:
 			repo := evt.GetRepo().GetFullName()
 			logger = logger.WithFields(log.String("repo", repo))
@@ -115,6 +132,16 @@ func (m *Manager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			logger = logger.WithFields(log.String("repo", repo))
 			logger.V("received push event")
 
+			push := pushInfo(evt)
+			if reason := m.pushFilter.Match(push); reason != "" {
+				logger.D("push ignored", log.String("reason", reason))
+				return ""
+			}
+
+			validate = func() {
+				m.checkConfigChanges(logger, evt, push.ChangedFiles)
+			}
+
 			return repo
 		default:
 			logger.V("ignored event")
@@ -127,16 +154,27 @@ func (m *Manager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if _, disabled := m.disabledRepos[repo]; disabled {
-		logger.I("execution ignored")
-		w.WriteHeader(http.StatusOK)
-		return
+	if !checked {
+		ignored, err2 := m.ignored(logger, repo)
+		if err2 != nil {
+			http.Error(w, "failed to check repo", http.StatusInternalServerError)
+			return
+		}
+
+		if ignored {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+
+	if validate != nil {
+		validate()
 	}
 
 	logger.I("scheduling renovate execution")
 
 	// run renovate against this repo
-	err = m.scheduler.Schedule(m.ExecutionArgs(repo))
+	err = m.scheduler.Schedule(repo)
 	if err != nil {
 		logger.I("failed to schedule renovate execution", log.Error(err))
 		http.Error(w, "failed to execute renovate", http.StatusInternalServerError)
@@ -144,5 +182,76 @@ func (m *Manager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 
 	logger.I("scheduled renovate execution")
+	if ack != nil {
+		ack()
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
+
+// ignored checks whether the repo is disabled or excluded by repo filter
+func (m *Manager) ignored(logger log.Interface, repo string) (bool, error) {
+	if _, disabled := m.disabledRepos[repo]; disabled {
+		logger.I("execution ignored")
+		return true, nil
+	}
+
+	reason, err := m.excluded(repo)
+	if err != nil {
+		logger.I("failed to check repo filter", log.Error(err))
+		return false, err
+	}
+
+	if reason != "" {
+		logger.I("execution ignored, repo excluded", log.String("reason", reason))
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// max commits in push event payload, commit list of the size is treated as truncated
+const maxPushEventCommits = 20
+
+func pushInfo(evt *github.PushEvent) *filter.Push {
+	p := &filter.Push{
+		Ref:           evt.GetRef(),
+		DefaultBranch: evt.GetRepo().GetDefaultBranch(),
+		Users: []string{
+			evt.GetPusher().GetName(),
+			evt.GetPusher().GetEmail(),
+			evt.GetSender().GetLogin(),
+		},
+	}
+
+	// commit list may be truncated (size is not set in webhook payload), changed
+	// files are unknown in that case
+	if len(evt.Commits) == 0 || len(evt.Commits) >= maxPushEventCommits || !headCommitIncluded(evt) {
+		return p
+	}
+
+	p.ChangedFiles = []string{}
+	for _, c := range evt.Commits {
+		p.ChangedFiles = append(p.ChangedFiles, c.Added...)
+		p.ChangedFiles = append(p.ChangedFiles, c.Removed...)
+		p.ChangedFiles = append(p.ChangedFiles, c.Modified...)
+	}
+
+	return p
+}
+
+// headCommitIncluded checks whether the head commit is in the commit list of the push
+func headCommitIncluded(evt *github.PushEvent) bool {
+	head := evt.GetHeadCommit().GetID()
+	if head == "" {
+		return true
+	}
+
+	for _, c := range evt.Commits {
+		if c.GetID() == head {
+			return true
+		}
+	}
+
+	return false
+}
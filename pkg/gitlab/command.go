@@ -0,0 +1,229 @@
+package gitlab
+
+import (
+	"fmt"
+	"net/http"
+
+	"arhat.dev/pkg/log"
+	"github.com/xanzy/go-gitlab"
+
+	"arhat.dev/renovate-server/pkg/command"
+	"arhat.dev/renovate-server/pkg/constant"
+	"arhat.dev/renovate-server/pkg/util"
+)
+
+// default roles allowed to use slash commands
+var defaultCommandRoles = []string{"developer", "maintainer", "owner"}
+
+// commandNote is the note containing slash command
+type commandNote struct {
+	repo      string
+	projectID int
+	noteID    int
+	body      string
+	system    bool
+	userID    int
+	username  string
+
+	// iid of the issue or merge request
+	iid          int
+	mergeRequest bool
+	// description and source branch of the merge request
+	description  string
+	sourceBranch string
+}
+
+func issueCommandNote(evt *gitlab.IssueCommentEvent) *commandNote {
+	n := &commandNote{
+		repo:      evt.Project.PathWithNamespace,
+		projectID: evt.ProjectID,
+		noteID:    evt.ObjectAttributes.ID,
+		body:      evt.ObjectAttributes.Note,
+		system:    evt.ObjectAttributes.System,
+		iid:       evt.Issue.IID,
+	}
+
+	if evt.User != nil {
+		n.userID, n.username = evt.User.ID, evt.User.Username
+	}
+
+	return n
+}
+
+func mergeRequestCommandNote(evt *gitlab.MergeCommentEvent) *commandNote {
+	n := &commandNote{
+		repo:         evt.Project.PathWithNamespace,
+		projectID:    evt.ProjectID,
+		noteID:       evt.ObjectAttributes.ID,
+		body:         evt.ObjectAttributes.Note,
+		system:       evt.ObjectAttributes.System,
+		iid:          evt.MergeRequest.IID,
+		mergeRequest: true,
+		description:  evt.MergeRequest.Description,
+		sourceBranch: evt.MergeRequest.SourceBranch,
+	}
+
+	if evt.User != nil {
+		n.userID, n.username = evt.User.ID, evt.User.Username
+	}
+
+	return n
+}
+
+// handleCommand handles slash command in the note, returns the repo to run
+// renovate against and the func to acknowledge the command once scheduled, the
+// returned repo has been checked not ignored
+func (m *Manager) handleCommand(logger log.Interface, n *commandNote) (string, func()) {
+	if n.system {
+		return "", nil
+	}
+
+	cmd, ok := command.Parse(m.commandPrefix, n.body)
+	if !ok {
+		logger.V("no command in note")
+		return "", nil
+	}
+
+	logger = logger.WithFields(
+		log.String("repo", n.repo),
+		log.String("command", cmd),
+		log.String("user", n.username),
+	)
+	logger.V("received command")
+
+	reply := func(msg string) {
+		var err error
+		body := fmt.Sprintf("@%s %s", n.username, msg)
+		if n.mergeRequest {
+			_, _, err = m.client.Notes.CreateMergeRequestNote(n.projectID, n.iid,
+				&gitlab.CreateMergeRequestNoteOptions{Body: &body}, gitlab.WithContext(m.ctx),
+			)
+		} else {
+			_, _, err = m.client.Notes.CreateIssueNote(n.projectID, n.iid,
+				&gitlab.CreateIssueNoteOptions{Body: &body}, gitlab.WithContext(m.ctx),
+			)
+		}
+
+		if err != nil {
+			logger.I("failed to reply to command", log.Error(err))
+		}
+	}
+
+	// commands in ignored repos are not answered and never change anything
+	ignored, err := m.ignored(logger, n.repo)
+	if err != nil || ignored {
+		return "", nil
+	}
+
+	role := ""
+	member, resp, err := m.client.ProjectMembers.GetInheritedProjectMember(
+		n.projectID, n.userID, gitlab.WithContext(m.ctx),
+	)
+	switch {
+	case err == nil:
+		role = util.AccessLevelName(int(member.AccessLevel))
+	case resp != nil && resp.StatusCode == http.StatusNotFound:
+		// not a member
+	default:
+		logger.I("failed to get user access level", log.Error(err))
+		reply("failed to check your permission, please try again later")
+		return "", nil
+	}
+
+	if !command.Allowed(role, m.commandRoles) {
+		logger.I("command not allowed", log.String("role", role))
+		reply(fmt.Sprintf("you are not allowed to use `%s` commands", m.commandPrefix))
+		return "", nil
+	}
+
+	if !command.Supported(cmd) {
+		reply(fmt.Sprintf("unknown command `%s`, supported commands are `%s`, `%s` and `%s`",
+			cmd, command.Run, command.Rebase, command.Recreate))
+		return "", nil
+	}
+
+	switch cmd {
+	case command.Rebase, command.Recreate:
+		if !n.mergeRequest {
+			reply(fmt.Sprintf("`%s` is only available in renovate merge requests", cmd))
+			return "", nil
+		}
+
+		if cmd == command.Rebase {
+			err = m.checkRebase(n)
+		} else {
+			err = m.checkRecreate(n)
+		}
+
+		if err != nil {
+			logger.I("failed to apply command", log.Error(err))
+			reply(fmt.Sprintf("failed to %s: %v", cmd, err))
+			return "", nil
+		}
+	}
+
+	return n.repo, func() {
+		var err2 error
+		opts := &gitlab.CreateAwardEmojiOptions{Name: "thumbsup"}
+		if n.mergeRequest {
+			_, _, err2 = m.client.AwardEmoji.CreateMergeRequestAwardEmojiOnNote(
+				n.projectID, n.iid, n.noteID, opts, gitlab.WithContext(m.ctx),
+			)
+		} else {
+			_, _, err2 = m.client.AwardEmoji.CreateIssuesAwardEmojiOnNote(
+				n.projectID, n.iid, n.noteID, opts, gitlab.WithContext(m.ctx),
+			)
+		}
+
+		if err2 != nil {
+			logger.I("failed to acknowledge command", log.Error(err2))
+		}
+	}
+}
+
+// checkRebase checks the rebase checkbox of the renovate merge request
+func (m *Manager) checkRebase(n *commandNote) error {
+	desc, ok := command.CheckRebase(n.description)
+	if !ok {
+		return fmt.Errorf("not a renovate merge request")
+	}
+
+	_, _, err := m.client.MergeRequests.UpdateMergeRequest(n.projectID, n.iid,
+		&gitlab.UpdateMergeRequestOptions{Description: &desc}, gitlab.WithContext(m.ctx),
+	)
+	return err
+}
+
+// checkRecreate checks the recreate checkbox of the merge request source branch
+// in the dependency dashboard
+func (m *Manager) checkRecreate(n *commandNote) error {
+	state := "opened"
+	opts := &gitlab.ListProjectIssuesOptions{
+		ListOptions: gitlab.ListOptions{PerPage: constant.MaxAPIPageSize},
+		State:       &state,
+	}
+	for {
+		issues, resp, err := m.client.Issues.ListProjectIssues(n.projectID, opts, gitlab.WithContext(m.ctx))
+		if err != nil {
+			return fmt.Errorf("failed to list issues: %w", err)
+		}
+
+		for _, issue := range issues {
+			desc, ok := command.CheckRecreate(issue.Description, n.sourceBranch)
+			if !ok {
+				continue
+			}
+
+			_, _, err = m.client.Issues.UpdateIssue(n.projectID, issue.IID,
+				&gitlab.UpdateIssueOptions{Description: &desc}, gitlab.WithContext(m.ctx),
+			)
+			return err
+		}
+
+		if resp.NextPage == 0 {
+			return fmt.Errorf("branch %q not found in dependency dashboard", n.sourceBranch)
+		}
+
+		opts.Page = resp.NextPage
+	}
+}
@@ -0,0 +1,178 @@
+package gitlab
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+)
+
+// fakeCommandAPI records requests made when handling slash commands
+type fakeCommandAPI struct {
+	// access level of the user, 0 means not a member
+	accessLevel string
+
+	mu       sync.Mutex
+	requests []string
+	bodies   []string
+}
+
+func (f *fakeCommandAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(r.URL.Path, "/api/v4/projects/") {
+		// rate limiter configuration
+		_, _ = w.Write([]byte(`{}`))
+		return
+	}
+
+	data, _ := io.ReadAll(r.Body)
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	f.bodies = append(f.bodies, string(data))
+
+	switch {
+	case strings.Contains(r.URL.Path, "/members/all/"):
+		if f.accessLevel == "" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_level":` + f.accessLevel + `}`))
+	default:
+		_, _ = w.Write([]byte(`{}`))
+	}
+}
+
+func TestManager_ServeHTTP_Command(t *testing.T) {
+	tests := []struct {
+		name        string
+		accessLevel string
+		comment     string
+
+		disabled         bool
+		includeNameMatch string
+
+		scheduled bool
+		requests  []string
+		body      string
+	}{
+		{
+			name:        "Rebase",
+			accessLevel: "30",
+			comment:     "/renovate rebase",
+			scheduled:   true,
+			requests: []string{
+				"GET /api/v4/projects/1/members/all/3",
+				"PUT /api/v4/projects/1/merge_requests/4",
+				"POST /api/v4/projects/1/merge_requests/4/notes/2/award_emoji",
+			},
+			body: `[x] \u003c!-- rebase-check --\u003e`,
+		},
+		{
+			name:        "Reporter",
+			accessLevel: "20",
+			comment:     "/renovate",
+			requests: []string{
+				"GET /api/v4/projects/1/members/all/3",
+				"POST /api/v4/projects/1/merge_requests/4/notes",
+			},
+			body: "you are not allowed",
+		},
+		{
+			name:        "Unknown",
+			accessLevel: "30",
+			comment:     "/renovate merge",
+			requests: []string{
+				"GET /api/v4/projects/1/members/all/3",
+				"POST /api/v4/projects/1/merge_requests/4/notes",
+			},
+			body: "unknown command `merge`",
+		},
+		{
+			name:        "Unknown Reporter",
+			accessLevel: "20",
+			comment:     "/renovate merge",
+			requests: []string{
+				"GET /api/v4/projects/1/members/all/3",
+				"POST /api/v4/projects/1/merge_requests/4/notes",
+			},
+			body: "you are not allowed",
+		},
+		{
+			name:        "Disabled",
+			accessLevel: "30",
+			comment:     "/renovate rebase",
+			disabled:    true,
+		},
+		{
+			name:             "Excluded",
+			accessLevel:      "30",
+			comment:          "/renovate rebase",
+			includeNameMatch: "^other/",
+		},
+		{
+			name:    "Not Member",
+			comment: "/renovate",
+			requests: []string{
+				"GET /api/v4/projects/1/members/all/3",
+				"POST /api/v4/projects/1/merge_requests/4/notes",
+			},
+			body: "you are not allowed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api := &fakeCommandAPI{accessLevel: test.accessLevel}
+			srv := httptest.NewServer(api)
+			defer srv.Close()
+
+			scheduler := &fakeScheduler{}
+			config := &conf.PlatformConfig{
+				API: conf.APIConfig{
+					BaseURL:    srv.URL + "/",
+					OAuthToken: "test-token",
+				},
+				Projects: []conf.ProjectConfig{{Name: "foo/bar", Disabled: test.disabled}},
+			}
+			config.Filter.IncludeNameMatch = test.includeNameMatch
+
+			mgr, err := NewManager(context.TODO(), config, scheduler)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			payload := `{"object_kind":"note","project_id":1,` +
+				`"user":{"id":3,"username":"alice"},` +
+				`"project":{"path_with_namespace":"foo/bar"},` +
+				`"object_attributes":{"id":2,"note":"` + test.comment + `","noteable_type":"MergeRequest"},` +
+				`"merge_request":{"iid":4,"source_branch":"renovate/foo",` +
+				`"description":" - [ ] <!-- rebase-check -->If you want to rebase, check this box"}}`
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
+			req.Header.Set("X-Gitlab-Event", "Note Hook")
+
+			rec := httptest.NewRecorder()
+			mgr.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			if test.scheduled {
+				assert.Equal(t, []string{"foo/bar"}, scheduler.repos)
+			} else {
+				assert.Empty(t, scheduler.repos)
+			}
+
+			assert.Equal(t, test.requests, api.requests)
+			if test.body != "" {
+				assert.Contains(t, strings.Join(api.bodies, "\n"), test.body)
+			}
+		})
+	}
+}
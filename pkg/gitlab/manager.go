@@ -2,6 +2,7 @@ package gitlab
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"regexp"
 
@@ -10,8 +11,11 @@ import (
 
 	"arhat.dev/renovate-server/pkg/conf"
 	"arhat.dev/renovate-server/pkg/constant"
+	"arhat.dev/renovate-server/pkg/filter"
+	"arhat.dev/renovate-server/pkg/metrics"
 	"arhat.dev/renovate-server/pkg/types"
 	"arhat.dev/renovate-server/pkg/util"
+	"arhat.dev/renovate-server/pkg/validator"
 )
 
 func NewManager(
@@ -31,6 +35,24 @@ func NewManager(
 		}
 	}
 
+	repoFilter, err := filter.New(&config.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	pushFilter, err := filter.NewPushFilter(&config.Push, &config.Git)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push filter: %w", err)
+	}
+
+	statusContext := config.ConfigValidation.StatusContext
+	if statusContext == "" {
+		statusContext = constant.DefaultConfigValidationStatusContext
+	}
+
+	// executors may validate config with renovate-config-validator
+	externalValidator, _ := scheduler.(types.ConfigValidator)
+
 	client, err := config.API.Client.NewClient()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create http client")
@@ -41,11 +63,14 @@ func NewManager(
 		baseURL = constant.DefaultGitLabAPIBaseURL
 	}
 
+	client.Transport = metrics.RateLimitTransport("gitlab", baseURL, client.Transport)
+
 	var glClient *gitlab.Client
 	if o := config.API.OAuthToken; o != "" {
 		glClient, err = gitlab.NewOAuthClient(o,
 			gitlab.WithBaseURL(baseURL),
 			gitlab.WithHTTPClient(client),
+			gitlab.WithCustomRetry(retryCheck),
 		)
 	} else {
 		return nil, fmt.Errorf("no oauth token provided")
@@ -54,6 +79,24 @@ func NewManager(
 		return nil, fmt.Errorf("failed to create gitlab client: %w", err)
 	}
 
+	var webhookSecrets [][]byte
+	for _, secret := range append([]string{config.Webhook.Secret}, config.Webhook.Secrets...) {
+		if secret != "" {
+			digest := sha256.Sum256([]byte(secret))
+			webhookSecrets = append(webhookSecrets, digest[:])
+		}
+	}
+
+	commandPrefix := config.Commands.Prefix
+	if commandPrefix == "" {
+		commandPrefix = constant.DefaultCommandPrefix
+	}
+
+	commandRoles := config.Commands.AllowedRoles
+	if len(commandRoles) == 0 {
+		commandRoles = defaultCommandRoles
+	}
+
 	dashboardTitles := make(map[string]string)
 	disabledRepos := make(map[string]struct{})
 	for _, p := range config.Projects {
@@ -77,11 +120,23 @@ func NewManager(
 		defaultDashboardTitle: config.DashboardIssueTitle,
 		dashboardTitles:       dashboardTitles,
 		disabledRepos:         disabledRepos,
+		filter:                repoFilter,
+		pushFilter:            pushFilter,
+
+		validateConfig:   config.ConfigValidation.Enabled,
+		validator:        validator.New(externalValidator),
+		statusContext:    statusContext,
+		commentOnFailure: config.ConfigValidation.CommentOnFailure,
 
 		apiURL:   baseURL,
 		apiToken: config.API.OAuthToken,
 		gitUser:  config.Git.User,
 		gitEmail: config.Git.Email,
+
+		webhookSecrets: webhookSecrets,
+
+		commandPrefix: commandPrefix,
+		commandRoles:  commandRoles,
 	}, nil
 }
 
@@ -96,11 +151,24 @@ type Manager struct {
 	defaultDashboardTitle string
 	dashboardTitles       map[string]string
 	disabledRepos         map[string]struct{}
+	filter                *filter.Filter
+	pushFilter            *filter.PushFilter
+
+	validateConfig   bool
+	validator        *validator.Validator
+	statusContext    string
+	commentOnFailure bool
 
 	apiURL   string
 	apiToken string
 	gitUser  string
 	gitEmail string
+
+	// sha256 digests of accepted X-Gitlab-Token values, no verification when empty
+	webhookSecrets [][]byte
+
+	commandPrefix string
+	commandRoles  []string
 }
 
 func (m *Manager) getDashboardTitle(repo string) string {
@@ -108,13 +176,7 @@ func (m *Manager) getDashboardTitle(repo string) string {
 }
 
 func (m *Manager) ListRepos() ([]string, error) {
-	falseP := false
-	trueP := true
-
-	repos, _, err := m.client.Projects.ListProjects(&gitlab.ListProjectsOptions{
-		Archived: &falseP,
-		Simple:   &trueP,
-	}, gitlab.WithContext(m.ctx))
+	repos, err := m.listProjects()
 	if err != nil {
 		return nil, fmt.Errorf("failed to list all repos: %w", err)
 	}
@@ -132,13 +194,106 @@ func (m *Manager) ListRepos() ([]string, error) {
 			continue
 		}
 
+		if reason := m.filter.Match(repoInfo(repo)); reason != "" {
+			m.logger.V("repo excluded", log.String("repo", name), log.String("reason", reason))
+			continue
+		}
+
 		ret = append(ret, name)
 	}
 
 	return ret, nil
 }
 
-func (m *Manager) ExecutionArgs(repos ...string) types.ExecutionArgs {
+// listProjects lists all projects the user is a member of, public projects the
+// user can not contribute to are not included
+func (m *Manager) listProjects() ([]*gitlab.Project, error) {
+	trueP := true
+	simple := !m.filter.NeedsMetadata()
+
+	var ret []*gitlab.Project
+	opts := &gitlab.ListProjectsOptions{
+		ListOptions: gitlab.ListOptions{PerPage: constant.MaxAPIPageSize},
+		Simple:      &simple,
+		Membership:  &trueP,
+	}
+
+	if !m.filter.IncludeArchived() {
+		falseP := false
+		opts.Archived = &falseP
+	}
+
+	if l := m.filter.MinAccessLevel(); l > 0 {
+		opts.MinAccessLevel = gitlab.AccessLevel(gitlab.AccessLevelValue(l))
+	}
+
+	for {
+		projects, resp, err := m.client.Projects.ListProjects(opts, gitlab.WithContext(m.ctx))
+		if err != nil {
+			return nil, rateLimitError(err)
+		}
+
+		ret = append(ret, projects...)
+		if resp.NextPage == 0 {
+			return ret, nil
+		}
+
+		opts.Page = resp.NextPage
+	}
+}
+
+// excluded returns the reason why the repo is excluded by the filter, empty if included
+func (m *Manager) excluded(repo string) (string, error) {
+	if reason := m.filter.MatchName(repo); reason != "" || !m.filter.NeedsMetadata() {
+		return reason, nil
+	}
+
+	p, _, err := m.client.Projects.GetProject(repo, nil, gitlab.WithContext(m.ctx))
+	if err != nil {
+		return "", fmt.Errorf("failed to get project: %w", rateLimitError(err))
+	}
+
+	return m.filter.Match(repoInfo(p)), nil
+}
+
+func repoInfo(p *gitlab.Project) *filter.Repo {
+	accessLevel := 0
+	if perm := p.Permissions; perm != nil {
+		if perm.ProjectAccess != nil {
+			accessLevel = int(perm.ProjectAccess.AccessLevel)
+		}
+
+		if perm.GroupAccess != nil && int(perm.GroupAccess.AccessLevel) > accessLevel {
+			accessLevel = int(perm.GroupAccess.AccessLevel)
+		}
+	}
+
+	return &filter.Repo{
+		Name:        p.PathWithNamespace,
+		Topics:      p.TagList,
+		Visibility:  string(p.Visibility),
+		Fork:        p.ForkedFromProject != nil,
+		Archived:    p.Archived,
+		Empty:       p.EmptyRepo,
+		AccessLevel: accessLevel,
+	}
+}
+
+// CheckHealth checks credentials with gitlab api
+func (m *Manager) CheckHealth(ctx context.Context) error {
+	_, _, err := m.client.Users.CurrentUser(gitlab.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to authenticate with gitlab api: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Manager) APIURL() string {
+	return m.apiURL
+}
+
+func (m *Manager) ExecutionArgs(repos ...string) (types.ExecutionArgs, error) {
 	return types.ExecutionArgs{
 		Platform: "gitlab",
 		APIURL:   m.apiURL,
@@ -146,5 +301,5 @@ func (m *Manager) ExecutionArgs(repos ...string) types.ExecutionArgs {
 		Repos:    repos,
 		GitUser:  m.gitUser,
 		GitEmail: m.gitEmail,
-	}
+	}, nil
 }
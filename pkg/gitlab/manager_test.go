@@ -0,0 +1,123 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+)
+
+// fakeProjectsAPI serves /api/v4/projects in pages, rate limits requests as configured
+type fakeProjectsAPI struct {
+	total int
+
+	mu sync.Mutex
+	// responds with 429 to the next request
+	rateLimitReset time.Time
+	requests       int
+}
+
+func (f *fakeProjectsAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// the client also requests the api root to configure its rate limiter
+	if r.URL.Path != "/api/v4/projects" || r.URL.Query().Get("membership") != "true" {
+		http.NotFound(w, r)
+		return
+	}
+	f.requests++
+
+	if !f.rateLimitReset.IsZero() {
+		w.Header().Set(headerRateLimitReset, strconv.FormatInt(f.rateLimitReset.Unix(), 10))
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"message":"rate limited"}`))
+		if time.Until(f.rateLimitReset) < time.Hour {
+			f.rateLimitReset = time.Time{}
+		}
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page == 0 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+
+	start, end := (page-1)*perPage, page*perPage
+	if end >= f.total {
+		end = f.total
+	} else {
+		w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+	}
+
+	var projects []map[string]string
+	for i := start; i < end; i++ {
+		projects = append(projects, map[string]string{"path_with_namespace": fmt.Sprintf("foo/%d", i)})
+	}
+	_ = json.NewEncoder(w).Encode(projects)
+}
+
+func newTestManager(t *testing.T, baseURL string) *Manager {
+	mgr, err := NewManager(context.TODO(), &conf.PlatformConfig{
+		API: conf.APIConfig{
+			BaseURL:    baseURL,
+			OAuthToken: "test-token",
+		},
+		Projects: []conf.ProjectConfig{
+			{Name: "foo/1", Disabled: true},
+		},
+	}, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return mgr.(*Manager)
+}
+
+func TestManager_ListRepos(t *testing.T) {
+	t.Run("Pages", func(t *testing.T) {
+		api := &fakeProjectsAPI{total: 250}
+		srv := httptest.NewServer(api)
+		defer srv.Close()
+
+		repos, err := newTestManager(t, srv.URL+"/").ListRepos()
+		assert.NoError(t, err)
+		assert.Len(t, repos, 249)
+		assert.NotContains(t, repos, "foo/1")
+		assert.Contains(t, repos, "foo/249")
+		assert.Equal(t, 3, api.requests)
+	})
+
+	t.Run("Wait For Reset", func(t *testing.T) {
+		api := &fakeProjectsAPI{total: 10, rateLimitReset: time.Now()}
+		srv := httptest.NewServer(api)
+		defer srv.Close()
+
+		repos, err := newTestManager(t, srv.URL+"/").ListRepos()
+		assert.NoError(t, err)
+		assert.Len(t, repos, 9)
+		assert.Equal(t, 2, api.requests)
+	})
+
+	t.Run("Reset Too Late", func(t *testing.T) {
+		api := &fakeProjectsAPI{total: 10, rateLimitReset: time.Now().Add(2 * time.Hour)}
+		srv := httptest.NewServer(api)
+		defer srv.Close()
+
+		_, err := newTestManager(t, srv.URL+"/").ListRepos()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "rate limit exceeded, resets in")
+		}
+		assert.Equal(t, 1, api.requests)
+	})
+}
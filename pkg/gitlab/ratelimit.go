@@ -0,0 +1,64 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+
+	"arhat.dev/renovate-server/pkg/constant"
+)
+
+const headerRateLimitReset = "RateLimit-Reset"
+
+// retryCheck retries server errors and rate limited requests, the gitlab client
+// waits until the rate limit resets before retrying, so rate limited requests
+// are only retried when the rate limit resets soon
+func retryCheck(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return rateLimitResetIn(resp.Header) <= constant.MaxAPIRateLimitWait, nil
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// rateLimitResetIn returns the duration until the rate limit resets, 0 if unknown
+func rateLimitResetIn(header http.Header) time.Duration {
+	reset, _ := strconv.ParseInt(header.Get(headerRateLimitReset), 10, 64)
+	if reset <= 0 {
+		return 0
+	}
+
+	return time.Until(time.Unix(reset, 0))
+}
+
+// rateLimitError adds rate limit reset time to the error if err is caused by rate limit
+func rateLimitError(err error) error {
+	var errResp *gitlab.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil ||
+		errResp.Response.StatusCode != http.StatusTooManyRequests {
+		return err
+	}
+
+	wait := rateLimitResetIn(errResp.Response.Header)
+	if wait <= 0 {
+		return fmt.Errorf("gitlab api rate limit exceeded: %w", err)
+	}
+
+	return fmt.Errorf("gitlab api rate limit exceeded, resets in %s: %w", wait.Round(time.Second), err)
+}
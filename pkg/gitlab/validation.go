@@ -0,0 +1,101 @@
+package gitlab
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"arhat.dev/pkg/log"
+	"github.com/xanzy/go-gitlab"
+
+	"arhat.dev/renovate-server/pkg/validator"
+)
+
+// checkConfigChanges validates renovate config files changed by the push in background
+func (m *Manager) checkConfigChanges(logger log.Interface, evt *gitlab.PushEvent, changedFiles []string) {
+	// checkout sha is empty when the branch is deleted
+	if !m.validateConfig || evt.CheckoutSHA == "" || strings.HasPrefix(evt.Ref, "refs/tags/") {
+		return
+	}
+
+	files := validator.ChangedConfigFiles(changedFiles)
+	if len(files) == 0 {
+		return
+	}
+
+	logger.V("renovate config changed", log.Strings("files", files))
+	go m.validateConfigFiles(logger,
+		evt.ProjectID, strings.TrimPrefix(evt.Ref, "refs/heads/"), evt.CheckoutSHA, files,
+	)
+}
+
+// validateConfigFiles validates renovate config files at the commit, reports the result
+// as commit status
+func (m *Manager) validateConfigFiles(logger log.Interface, projectID int, branch, sha string, files []string) {
+	setStatus := func(state gitlab.BuildStateValue, description string) {
+		_, _, err := m.client.Commits.SetCommitStatus(projectID, sha, &gitlab.SetCommitStatusOptions{
+			State:       state,
+			Ref:         &branch,
+			Name:        &m.statusContext,
+			Description: &description,
+		}, gitlab.WithContext(m.ctx))
+		if err != nil {
+			logger.I("failed to set commit status", log.Error(err))
+		}
+	}
+
+	report := &validator.Report{}
+	for _, f := range files {
+		data, resp, err := m.client.RepositoryFiles.GetRawFile(projectID, f,
+			&gitlab.GetRawFileOptions{Ref: &sha}, gitlab.WithContext(m.ctx),
+		)
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				// removed
+				continue
+			}
+
+			logger.I("failed to get renovate config file", log.String("file", f), log.Error(err))
+			setStatus(gitlab.Failed, "failed to get renovate config")
+			return
+		}
+
+		problem, err := m.validator.Validate(f, data)
+		if err != nil {
+			if errors.Is(err, validator.ErrNoConfig) {
+				continue
+			}
+
+			logger.I("failed to validate renovate config", log.String("file", f), log.Error(err))
+			setStatus(gitlab.Failed, "failed to validate renovate config")
+			return
+		}
+
+		report.Add(f, problem)
+	}
+
+	if report.Empty() {
+		return
+	}
+
+	if report.Valid() {
+		logger.V("renovate config valid")
+		setStatus(gitlab.Success, report.Summary())
+		return
+	}
+
+	logger.I("renovate config invalid", log.String("summary", report.Summary()))
+	setStatus(gitlab.Failed, report.Summary())
+
+	if !m.commentOnFailure {
+		return
+	}
+
+	details := report.Details()
+	_, _, err := m.client.Commits.PostCommitComment(projectID, sha,
+		&gitlab.PostCommitCommentOptions{Note: &details}, gitlab.WithContext(m.ctx),
+	)
+	if err != nil {
+		logger.I("failed to comment on commit", log.Error(err))
+	}
+}
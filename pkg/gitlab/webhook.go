@@ -1,12 +1,15 @@
 package gitlab
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
 	"io/ioutil"
 	"net/http"
 
 	"arhat.dev/pkg/log"
 	"github.com/xanzy/go-gitlab"
 
+	"arhat.dev/renovate-server/pkg/filter"
 	"arhat.dev/renovate-server/pkg/util"
 )
 
@@ -22,6 +25,12 @@ func (m *Manager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 	logger.D("received event")
 
+	if !m.validToken(req.Header.Get("X-Gitlab-Token")) {
+		logger.I("webhook token invalid")
+		http.Error(w, "invalid webhook token", http.StatusUnauthorized)
+		return
+	}
+
 	payload, err := ioutil.ReadAll(req.Body)
 	if err != nil {
 		logger.I("failed to read event payload", log.Error(err))
@@ -36,8 +45,31 @@ func (m *Manager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	var (
+		// acknowledges slash command after scheduled
+		ack func()
+		// repo already checked not ignored
+		checked bool
+		// validates changed renovate config files if the push is not ignored
+		validate func()
+	)
+
 	repo := func() string {
 		switch evt := ev.(type) {
+		case *gitlab.IssueCommentEvent:
+			logger.V("received issue note event")
+
+			var repo string
+			repo, ack = m.handleCommand(logger, issueCommandNote(evt))
+			checked = true
+			return repo
+		case *gitlab.MergeCommentEvent:
+			logger.V("received merge request note event")
+
+			var repo string
+			repo, ack = m.handleCommand(logger, mergeRequestCommandNote(evt))
+			checked = true
+			return repo
 		case *gitlab.IssueEvent:
 			repo := evt.Project.PathWithNamespace
 			logger = logger.WithFields(log.String("repo", repo))
@@ -79,11 +111,32 @@ func (m *Manager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 			logger.V("received push event")
 
-			if evt.UserEmail == m.gitEmail {
+			push := pushInfo(evt)
+			if reason := m.pushFilter.Match(push); reason != "" {
+				logger.D("push ignored", log.String("reason", reason))
+				return ""
+			}
+
+			validate = func() {
+				m.checkConfigChanges(logger, evt, push.ChangedFiles)
+			}
+
+			return repo
+		case *gitlab.TagEvent:
+			repo := evt.Project.PathWithNamespace
+			logger = logger.WithFields(log.String("repo", repo))
+
+			logger.V("received tag push event")
+
+			if reason := m.pushFilter.Match(&filter.Push{
+				Ref:   evt.Ref,
+				Users: []string{evt.UserName, evt.UserEmail},
+			}); reason != "" {
+				logger.D("push ignored", log.String("reason", reason))
 				return ""
 			}
 
-			return evt.Project.PathWithNamespace
+			return repo
 		default:
 			logger.V("ignored event")
 			return ""
@@ -95,15 +148,26 @@ func (m *Manager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if _, disabled := m.disabledRepos[repo]; disabled {
-		logger.I("execution ignored")
-		w.WriteHeader(http.StatusOK)
-		return
+	if !checked {
+		ignored, err2 := m.ignored(logger, repo)
+		if err2 != nil {
+			http.Error(w, "failed to check repo", http.StatusInternalServerError)
+			return
+		}
+
+		if ignored {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+
+	if validate != nil {
+		validate()
 	}
 
 	logger.I("scheduling renovate execution")
 
-	err = m.scheduler.Schedule(m.ExecutionArgs(repo))
+	err = m.scheduler.Schedule(repo)
 
 	if err != nil {
 		logger.I("failed to schedule renovate execution", log.Error(err))
@@ -112,5 +176,70 @@ func (m *Manager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 
 	logger.I("scheduled renovate execution")
+	if ack != nil {
+		ack()
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
+
+// ignored checks whether the repo is disabled or excluded by repo filter
+func (m *Manager) ignored(logger log.Interface, repo string) (bool, error) {
+	if _, disabled := m.disabledRepos[repo]; disabled {
+		logger.I("execution ignored")
+		return true, nil
+	}
+
+	reason, err := m.excluded(repo)
+	if err != nil {
+		logger.I("failed to check repo filter", log.Error(err))
+		return false, err
+	}
+
+	if reason != "" {
+		logger.I("execution ignored, repo excluded", log.String("reason", reason))
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// validToken checks the token against all accepted secrets in constant time, digests
+// are compared so the length of secrets is not leaked
+func (m *Manager) validToken(token string) bool {
+	if len(m.webhookSecrets) == 0 {
+		return true
+	}
+
+	digest := sha256.Sum256([]byte(token))
+
+	match := 0
+	for _, secret := range m.webhookSecrets {
+		match |= subtle.ConstantTimeCompare(digest[:], secret)
+	}
+
+	return match == 1
+}
+
+func pushInfo(evt *gitlab.PushEvent) *filter.Push {
+	p := &filter.Push{
+		Ref:           evt.Ref,
+		DefaultBranch: evt.Project.DefaultBranch,
+		Users:         []string{evt.UserUsername, evt.UserName, evt.UserEmail},
+	}
+
+	// commit list is truncated when there are too many commits, changed files
+	// are unknown in that case
+	if len(evt.Commits) == 0 || evt.TotalCommitsCount > len(evt.Commits) {
+		return p
+	}
+
+	p.ChangedFiles = []string{}
+	for _, c := range evt.Commits {
+		p.ChangedFiles = append(p.ChangedFiles, c.Added...)
+		p.ChangedFiles = append(p.ChangedFiles, c.Removed...)
+		p.ChangedFiles = append(p.ChangedFiles, c.Modified...)
+	}
+
+	return p
+}
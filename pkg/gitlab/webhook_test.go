@@ -0,0 +1,97 @@
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+)
+
+type fakeScheduler struct {
+	repos []string
+}
+
+func (s *fakeScheduler) Schedule(repos ...string) error {
+	s.repos = append(s.repos, repos...)
+	return nil
+}
+
+func TestManager_ServeHTTP_Token(t *testing.T) {
+	const payload = `{"object_kind":"push","user_email":"dev@example.com","project":{"path_with_namespace":"foo/bar"}}`
+
+	tests := []struct {
+		name    string
+		secret  string
+		secrets []string
+		token   string
+
+		expectedCode int
+	}{
+		{
+			name:         "No Secret",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Valid",
+			secret:       "current",
+			token:        "current",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Rotated",
+			secret:       "new",
+			secrets:      []string{"old"},
+			token:        "old",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Missing",
+			secret:       "current",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "Mismatch",
+			secret:       "current",
+			secrets:      []string{"old"},
+			token:        "curren",
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scheduler := &fakeScheduler{}
+			mgr, err := NewManager(context.TODO(), &conf.PlatformConfig{
+				API: conf.APIConfig{OAuthToken: "test-token"},
+				Webhook: conf.WebhookConfig{
+					Secret:  test.secret,
+					Secrets: test.secrets,
+				},
+			}, scheduler)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(payload)))
+			req.Header.Set("X-Gitlab-Event", "Push Hook")
+			if test.token != "" {
+				req.Header.Set("X-Gitlab-Token", test.token)
+			}
+
+			rec := httptest.NewRecorder()
+			mgr.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.expectedCode, rec.Code)
+			if test.expectedCode == http.StatusOK {
+				assert.Equal(t, []string{"foo/bar"}, scheduler.repos)
+			} else {
+				assert.Empty(t, scheduler.repos)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "renovate_server"
+
+// Webhook events
+var (
+	WebhookEventsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_events_received_total",
+		Help:      "Webhook events received",
+	}, []string{"manager", "event"})
+
+	WebhookEventsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_events_rejected_total",
+		Help:      "Webhook events rejected due to invalid signature or payload",
+	}, []string{"manager", "event"})
+)
+
+// Queue
+var (
+	QueueItemsScheduled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queue_items_scheduled_total",
+		Help:      "Pending executions scheduled",
+	}, []string{"manager"})
+
+	QueueItemsMerged = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queue_items_merged_total",
+		Help:      "Pending executions merged into existing ones",
+	}, []string{"manager"})
+
+	QueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "Repos of pending executions, scheduled: waiting for due time, waiting: waiting for concurrency limits",
+	}, []string{"state"})
+
+	QueueOldestItemAge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_oldest_item_age_seconds",
+		Help:      "Time since the oldest pending execution was scheduled",
+	})
+)
+
+// Executions
+var (
+	ExecutionsStarted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "executions_started_total",
+		Help:      "Renovate executions started",
+	}, []string{"executor", "manager"})
+
+	ExecutionsFinished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "executions_finished_total",
+		Help:      "Renovate executions finished, result is one of succeeded, failed, canceled",
+	}, []string{"executor", "manager", "result"})
+)
+
+// Repo checks
+var (
+	CronRunDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cron_run_duration_seconds",
+		Help:      "Duration of cron jobs checking all repos",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+	})
+
+	ListReposDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "list_repos_duration_seconds",
+		Help:      "Latency of listing repos from platform",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+	}, []string{"manager", "result"})
+)
+
+// Platform API
+var (
+	APIRateLimitRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "api_rate_limit_remaining",
+		Help:      "Remaining requests in current rate limit window reported by platform api",
+	}, []string{"platform", "api"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		WebhookEventsReceived,
+		WebhookEventsRejected,
+		QueueItemsScheduled,
+		QueueItemsMerged,
+		QueueDepth,
+		QueueOldestItemAge,
+		ExecutionsStarted,
+		ExecutionsFinished,
+		CronRunDuration,
+		ListReposDuration,
+		APIRateLimitRemaining,
+	)
+}
+
+// Handler serves metrics in prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// rate limit headers of github and gitlab
+var rateLimitRemainingHeaders = []string{
+	"X-RateLimit-Remaining",
+	"RateLimit-Remaining",
+}
+
+// RateLimitTransport records rate limit remaining reported in api responses
+func RateLimitTransport(platform, api string, rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	return &rateLimitTransport{
+		gauge: APIRateLimitRemaining.WithLabelValues(platform, api),
+		rt:    rt,
+	}
+}
+
+type rateLimitTransport struct {
+	gauge prometheus.Gauge
+	rt    http.RoundTripper
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	for _, h := range rateLimitRemainingHeaders {
+		remaining, err2 := strconv.ParseFloat(resp.Header.Get(h), 64)
+		if err2 == nil {
+			t.gauge.Set(remaining)
+			break
+		}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func value(t *testing.T, m prometheus.Metric) float64 {
+	out := &dto.Metric{}
+	if !assert.NoError(t, m.Write(out)) {
+		return 0
+	}
+
+	switch {
+	case out.Counter != nil:
+		return out.Counter.GetValue()
+	case out.Gauge != nil:
+		return out.Gauge.GetValue()
+	default:
+		return 0
+	}
+}
+
+func TestInstrumentWebhook(t *testing.T) {
+	h := InstrumentWebhook("/test", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Token") != "ok" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		// body is still readable after event type detection
+		_, _ = io.Copy(w, req.Body)
+	}))
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		body   string
+		event  string
+		code   int
+	}{
+		{name: "Header", header: map[string]string{"X-GitHub-Event": "push", "X-Token": "ok"}, event: "push", code: 200},
+		{name: "Rejected", header: map[string]string{"X-Gitlab-Event": "Push Hook"}, event: "Push Hook", code: 401},
+		{name: "Payload", header: map[string]string{"X-Token": "ok"}, body: `{"eventType":"git.push"}`, event: "git.push", code: 200},
+		{name: "Unknown", header: map[string]string{"X-Token": "ok"}, body: `[]`, event: "unknown", code: 200},
+		{name: "Other", header: map[string]string{"X-GitHub-Event": "random-1234"}, event: "other", code: 401},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			received := value(t, WebhookEventsReceived.WithLabelValues("/test", test.event))
+			rejected := value(t, WebhookEventsRejected.WithLabelValues("/test", test.event))
+
+			req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(test.body))
+			for k, v := range test.header {
+				req.Header.Set(k, v)
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.code, rec.Code)
+			if test.code == http.StatusOK {
+				assert.Equal(t, test.body, rec.Body.String())
+			}
+
+			assert.Equal(t, received+1, value(t, WebhookEventsReceived.WithLabelValues("/test", test.event)))
+			if test.code == http.StatusOK {
+				assert.Equal(t, rejected, value(t, WebhookEventsRejected.WithLabelValues("/test", test.event)))
+			} else {
+				assert.Equal(t, rejected+1, value(t, WebhookEventsRejected.WithLabelValues("/test", test.event)))
+			}
+		})
+	}
+}
+
+func TestRateLimitTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/github":
+			w.Header().Set("X-RateLimit-Remaining", "4999")
+		case "/gitlab":
+			w.Header().Set("RateLimit-Remaining", "599")
+		}
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: RateLimitTransport("test", srv.URL, nil)}
+	gauge := APIRateLimitRemaining.WithLabelValues("test", srv.URL)
+
+	for _, test := range []struct {
+		path     string
+		expected float64
+	}{
+		{path: "/github", expected: 4999},
+		{path: "/gitlab", expected: 599},
+		// unchanged without rate limit headers
+		{path: "/none", expected: 599},
+	} {
+		resp, err := client.Get(srv.URL + test.path)
+		if !assert.NoError(t, err) {
+			return
+		}
+		_ = resp.Body.Close()
+
+		assert.Equal(t, test.expected, value(t, gauge), test.path)
+	}
+}
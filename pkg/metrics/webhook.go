@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+)
+
+// headers of event type sent by platforms
+var eventTypeHeaders = []string{
+	"X-GitHub-Event",
+	"X-Gitlab-Event",
+	"X-Gitea-Event",
+	"X-Forgejo-Event",
+	// bitbucket server
+	"X-Event-Key",
+}
+
+// eventOther is the label of events not in knownEvents, event types are sent by
+// clients before authentication, arbitrary values would create unbounded series
+const eventOther = "other"
+
+// knownEvents are event types sent by platforms
+var knownEvents = map[string]struct{}{
+	// github and gitea
+	"ping":                      {},
+	"push":                      {},
+	"create":                    {},
+	"delete":                    {},
+	"issues":                    {},
+	"issue_comment":             {},
+	"pull_request":              {},
+	"pull_request_review":       {},
+	"installation":              {},
+	"installation_repositories": {},
+	"repository":                {},
+	"release":                   {},
+	"status":                    {},
+	"check_run":                 {},
+	"check_suite":               {},
+
+	// gitlab
+	"Push Hook":          {},
+	"Tag Push Hook":      {},
+	"Issue Hook":         {},
+	"Note Hook":          {},
+	"Merge Request Hook": {},
+	"Pipeline Hook":      {},
+	"Job Hook":           {},
+	"System Hook":        {},
+
+	// bitbucket server
+	"diagnostics:ping":   {},
+	"repo:refs_changed":  {},
+	"repo:modified":      {},
+	"pr:opened":          {},
+	"pr:modified":        {},
+	"pr:merged":          {},
+	"pr:declined":        {},
+	"pr:deleted":         {},
+	"pr:comment:added":   {},
+	"pr:comment:edited":  {},
+	"pr:comment:deleted": {},
+
+	// azure devops
+	"git.push":                                  {},
+	"git.pullrequest.created":                   {},
+	"git.pullrequest.updated":                   {},
+	"git.pullrequest.merged":                    {},
+	"ms.vss-code.git-pullrequest-comment-event": {},
+
+	"unknown": {},
+}
+
+// InstrumentWebhook counts webhook events received and rejected by the handler of
+// the platform manager, events with 4xx responses are considered rejected
+func InstrumentWebhook(manager string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		event := eventType(req)
+		if _, ok := knownEvents[event]; !ok {
+			event = eventOther
+		}
+		WebhookEventsReceived.WithLabelValues(manager, event).Inc()
+
+		rw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rw, req)
+
+		if rw.status >= 400 && rw.status < 500 {
+			WebhookEventsRejected.WithLabelValues(manager, event).Inc()
+		}
+	})
+}
+
+// eventType of the webhook request, azure devops only sends event type in payload
+func eventType(req *http.Request) string {
+	for _, h := range eventTypeHeaders {
+		if evt := req.Header.Get(h); evt != "" {
+			return evt
+		}
+	}
+
+	if req.Body == nil {
+		return "unknown"
+	}
+
+	data, err := ioutil.ReadAll(req.Body)
+	_ = req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return "unknown"
+	}
+
+	payload := &struct {
+		EventType string `json:"eventType"`
+	}{}
+	if json.Unmarshal(data, payload) != nil || payload.EventType == "" {
+		return "unknown"
+	}
+
+	return payload.EventType
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"arhat.dev/pkg/envhelper"
+	corev1 "k8s.io/api/core/v1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	clientcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+// max attempts to update the configmap when there are conflicts
+const configMapUpdateAttempts = 5
+
+func NewConfigMapStore(ctx context.Context, config *conf.ConfigMapStoreConfig) (*ConfigMapStore, error) {
+	client, _, err := config.KubeClient.NewKubeClient(nil, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	return newConfigMapStore(ctx, config, client)
+}
+
+func newConfigMapStore(
+	ctx context.Context,
+	config *conf.ConfigMapStoreConfig,
+	client kubernetes.Interface,
+) (*ConfigMapStore, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("configmap name is required")
+	}
+
+	return &ConfigMapStore{
+		ctx:    ctx,
+		name:   config.Name,
+		client: client.CoreV1().ConfigMaps(envhelper.ThisPodNS()),
+	}, nil
+}
+
+// ConfigMapStore stores pending executions in a configmap, one key for each,
+// the configmap size limit (1MiB) applies
+type ConfigMapStore struct {
+	ctx    context.Context
+	name   string
+	client clientcorev1.ConfigMapInterface
+}
+
+func (s *ConfigMapStore) Put(item types.PendingExecution) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(cm *corev1.ConfigMap) bool {
+		if cm.Data == nil {
+			cm.Data = make(map[string]string)
+		}
+
+		cm.Data[itemKey(item.Manager, item.Repo)] = string(data)
+		return true
+	})
+}
+
+func (s *ConfigMapStore) Delete(manager, repo string) error {
+	return s.update(func(cm *corev1.ConfigMap) bool {
+		key := itemKey(manager, repo)
+		if _, ok := cm.Data[key]; !ok {
+			return false
+		}
+
+		delete(cm.Data, key)
+		return true
+	})
+}
+
+func (s *ConfigMapStore) List() ([]types.PendingExecution, error) {
+	cm, err := s.client.Get(s.ctx, s.name, metav1.GetOptions{})
+	if err != nil {
+		if kubeerrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get store configmap: %w", err)
+	}
+
+	var ret []types.PendingExecution
+	for k, v := range cm.Data {
+		var item types.PendingExecution
+		err = json.Unmarshal([]byte(v), &item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pending execution %q: %w", k, err)
+		}
+
+		ret = append(ret, item)
+	}
+
+	return ret, nil
+}
+
+// update applies mutate to the configmap, retries on conflict, the configmap
+// is created if not found
+func (s *ConfigMapStore) update(mutate func(cm *corev1.ConfigMap) (changed bool)) error {
+	var err error
+	for i := 0; i < configMapUpdateAttempts; i++ {
+		var cm *corev1.ConfigMap
+		cm, err = s.client.Get(s.ctx, s.name, metav1.GetOptions{})
+		switch {
+		case err == nil:
+			if !mutate(cm) {
+				return nil
+			}
+
+			_, err = s.client.Update(s.ctx, cm, metav1.UpdateOptions{})
+		case kubeerrors.IsNotFound(err):
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      s.name,
+					Namespace: envhelper.ThisPodNS(),
+				},
+			}
+			if !mutate(cm) {
+				return nil
+			}
+
+			_, err = s.client.Create(s.ctx, cm, metav1.CreateOptions{})
+		default:
+			return fmt.Errorf("failed to get store configmap: %w", err)
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		if !kubeerrors.IsConflict(err) && !kubeerrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to update store configmap: %w", err)
+		}
+	}
+
+	return fmt.Errorf("failed to update store configmap after %d attempts: %w", configMapUpdateAttempts, err)
+}
@@ -0,0 +1,22 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"arhat.dev/renovate-server/pkg/conf"
+)
+
+func TestConfigMapStore(t *testing.T) {
+	s, err := newConfigMapStore(context.TODO(), &conf.ConfigMapStoreConfig{
+		Name: "renovate-server-queue",
+	}, fake.NewSimpleClientset())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	testQueueStore(t, s)
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+const fileStoreExt = ".json"
+
+func NewFileStore(config *conf.FileStoreConfig) (*FileStore, error) {
+	if config.Dir == "" {
+		return nil, fmt.Errorf("file store dir is required")
+	}
+
+	err := os.MkdirAll(config.Dir, 0750)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ensure file store dir: %w", err)
+	}
+
+	return &FileStore{dir: config.Dir}, nil
+}
+
+// FileStore stores each pending execution in a json file
+type FileStore struct {
+	dir string
+}
+
+func (s *FileStore) Put(item types.PendingExecution) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(s.dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	_ = f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write pending execution: %w", err)
+	}
+
+	// rename is atomic, readers never see partially written items
+	err = os.Rename(f.Name(), s.path(item.Manager, item.Repo))
+	if err != nil {
+		return fmt.Errorf("failed to save pending execution: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FileStore) Delete(manager, repo string) error {
+	err := os.Remove(s.path(manager, repo))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete pending execution: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FileStore) List() ([]types.PendingExecution, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file store dir: %w", err)
+	}
+
+	var ret []types.PendingExecution
+	for _, f := range files {
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") || filepath.Ext(f.Name()) != fileStoreExt {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(s.dir, f.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pending execution: %w", err)
+		}
+
+		var item types.PendingExecution
+		err = json.Unmarshal(data, &item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pending execution %q: %w", f.Name(), err)
+		}
+
+		ret = append(ret, item)
+	}
+
+	return ret, nil
+}
+
+func (s *FileStore) path(manager, repo string) string {
+	return filepath.Join(s.dir, itemKey(manager, repo)+fileStoreExt)
+}
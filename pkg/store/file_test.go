@@ -0,0 +1,47 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+func testQueueStore(t *testing.T, s types.QueueStore) {
+	dueAt := time.Now().Add(time.Minute).UTC().Round(time.Second)
+
+	items, err := s.List()
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+
+	assert.NoError(t, s.Put(types.PendingExecution{Manager: "/a", Repo: "foo/bar", DueAt: dueAt}))
+	assert.NoError(t, s.Put(types.PendingExecution{Manager: "/a", Repo: "foo/baz", DueAt: dueAt}))
+	// replaced
+	assert.NoError(t, s.Put(types.PendingExecution{Manager: "/a", Repo: "foo/bar", DueAt: dueAt.Add(time.Minute)}))
+
+	items, err = s.List()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []types.PendingExecution{
+		{Manager: "/a", Repo: "foo/bar", DueAt: dueAt.Add(time.Minute)},
+		{Manager: "/a", Repo: "foo/baz", DueAt: dueAt},
+	}, items)
+
+	assert.NoError(t, s.Delete("/a", "foo/bar"))
+	assert.NoError(t, s.Delete("/a", "foo/bar"))
+
+	items, err = s.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []types.PendingExecution{{Manager: "/a", Repo: "foo/baz", DueAt: dueAt}}, items)
+}
+
+func TestFileStore(t *testing.T) {
+	s, err := NewFileStore(&conf.FileStoreConfig{Dir: t.TempDir()})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	testQueueStore(t, s)
+}
@@ -0,0 +1,29 @@
+package store
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"arhat.dev/pkg/hashhelper"
+
+	"arhat.dev/renovate-server/pkg/conf"
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+// NewQueueStore creates the configured queue store, returns nil if no store configured
+func NewQueueStore(ctx context.Context, config *conf.StoreConfig) (types.QueueStore, error) {
+	switch {
+	case config.File != nil:
+		return NewFileStore(config.File)
+	case config.ConfigMap != nil:
+		return NewConfigMapStore(ctx, config.ConfigMap)
+	default:
+		return nil, nil
+	}
+}
+
+// itemKey is a file name and configmap key safe identifier of the pending execution
+func itemKey(manager, repo string) string {
+	return hex.EncodeToString(hashhelper.MD5Sum([]byte(fmt.Sprintf("%s#%s", manager, repo))))
+}
@@ -1,6 +1,43 @@
 package types
 
+import "errors"
+
+// ErrExecutionDeadlineExceeded is returned by executors when renovate didn't finish in time
+var ErrExecutionDeadlineExceeded = errors.New("execution deadline exceeded")
+
+// PermanentError is an execution error not going to be resolved by retrying
+// (e.g. unauthorized, invalid spec)
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent marks err as not retryable
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent checks whether err or any error it wraps is not retryable
+func IsPermanent(err error) bool {
+	var e *PermanentError
+	return errors.As(err, &e)
+}
+
 type ExecutionArgs struct {
+	// Manager is the key of the platform manager (webhook path) scheduled the execution
+	Manager string
+
 	Platform string
 	APIURL   string
 	APIToken string
@@ -10,5 +47,22 @@ type ExecutionArgs struct {
 }
 
 type Executor interface {
+	// Execute runs renovate and blocks until the execution finished
 	Execute(args ExecutionArgs) error
 }
+
+// ExecutionCounter is implemented by executors able to find running executions
+// not started by this process (e.g. started before restart)
+type ExecutionCounter interface {
+	// UntrackedExecutions returns count of running executions not started by this process,
+	// grouped by platform manager, it should not block
+	UntrackedExecutions() map[string]int
+}
+
+// ConfigValidator is implemented by executors able to validate renovate config
+// with renovate-config-validator
+type ConfigValidator interface {
+	// ValidateConfig validates content of the renovate config file, returns the problem
+	// found, empty if the config is valid
+	ValidateConfig(file string, data []byte) (string, error)
+}
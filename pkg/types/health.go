@@ -0,0 +1,10 @@
+package types
+
+import "context"
+
+// HealthChecker is implemented by platform managers and executors able to check
+// availability of their dependencies (e.g. platform api credentials, kubernetes api)
+type HealthChecker interface {
+	// CheckHealth returns an error if the dependency is not available
+	CheckHealth(ctx context.Context) error
+}
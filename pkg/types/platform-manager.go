@@ -5,5 +5,10 @@ import "net/http"
 type PlatformManager interface {
 	http.Handler
 	ListRepos() ([]string, error)
-	ExecutionArgs(repos ...string) ExecutionArgs
+
+	// APIURL is the api endpoint used by renovate
+	APIURL() string
+
+	// ExecutionArgs returns args to run renovate for repos with fresh credentials
+	ExecutionArgs(repos ...string) (ExecutionArgs, error)
 }
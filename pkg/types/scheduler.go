@@ -1,5 +1,6 @@
 package types
 
 type Scheduler interface {
-	Schedule(args ExecutionArgs) error
+	// Schedule renovate execution for repos
+	Schedule(repos ...string) error
 }
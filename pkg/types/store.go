@@ -0,0 +1,24 @@
+package types
+
+import "time"
+
+// PendingExecution is a scheduled renovate execution of a repo, api token is not
+// recorded, it's resolved from platform config when executing
+type PendingExecution struct {
+	// Manager is the key of the platform manager (webhook path)
+	Manager string    `json:"manager"`
+	Repo    string    `json:"repo"`
+	DueAt   time.Time `json:"dueAt"`
+}
+
+// QueueStore persists pending executions across restarts
+type QueueStore interface {
+	// Put creates or replaces the pending execution of the repo
+	Put(item PendingExecution) error
+
+	// Delete removes the pending execution of the repo, it's not an error if not found
+	Delete(manager, repo string) error
+
+	// List all pending executions
+	List() ([]PendingExecution, error)
+}
@@ -0,0 +1,40 @@
+package util
+
+import "strings"
+
+// access levels of gitlab
+var accessLevels = []struct {
+	name  string
+	level int
+}{
+	{name: "guest", level: 10},
+	{name: "reporter", level: 20},
+	{name: "developer", level: 30},
+	{name: "maintainer", level: 40},
+	{name: "owner", level: 50},
+}
+
+// ParseAccessLevel converts access level name to its value
+func ParseAccessLevel(name string) (int, bool) {
+	name = strings.ToLower(name)
+	for _, l := range accessLevels {
+		if l.name == name {
+			return l.level, true
+		}
+	}
+
+	return 0, false
+}
+
+// AccessLevelName returns the name of the highest access level not greater than level,
+// empty if level is lower than guest
+func AccessLevelName(level int) string {
+	name := ""
+	for _, l := range accessLevels {
+		if level >= l.level {
+			name = l.name
+		}
+	}
+
+	return name
+}
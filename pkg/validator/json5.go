@@ -0,0 +1,443 @@
+package validator
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ParseJSON5 parses json5 document (https://spec.json5.org), objects are decoded as
+// map[string]interface{}, arrays as []interface{} and numbers as float64, same as
+// encoding/json
+func ParseJSON5(data []byte) (interface{}, error) {
+	p := &json5Parser{data: data}
+
+	// byte order mark
+	if bytes.HasPrefix(data, []byte("\xef\xbb\xbf")) {
+		p.pos = 3
+	}
+
+	err := p.skipSpace()
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.skipSpace()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.data) {
+		return nil, p.errorf("unexpected character %q after top-level value", p.peekRune())
+	}
+
+	return v, nil
+}
+
+type json5Parser struct {
+	data []byte
+	pos  int
+}
+
+func (p *json5Parser) errorf(format string, args ...interface{}) error {
+	line, col := 1, 1
+	for _, r := range string(p.data[:p.pos]) {
+		if r == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+
+	return fmt.Errorf("line %d, column %d: %s", line, col, fmt.Sprintf(format, args...))
+}
+
+func (p *json5Parser) peekRune() rune {
+	if p.pos >= len(p.data) {
+		return utf8.RuneError
+	}
+
+	r, _ := utf8.DecodeRune(p.data[p.pos:])
+	return r
+}
+
+func (p *json5Parser) nextRune() rune {
+	r, size := utf8.DecodeRune(p.data[p.pos:])
+	p.pos += size
+	return r
+}
+
+// skipSpace skips white spaces and comments
+func (p *json5Parser) skipSpace() error {
+	for p.pos < len(p.data) {
+		r := p.peekRune()
+		switch {
+		case unicode.IsSpace(r) || r == '\uFEFF':
+			p.nextRune()
+		case bytes.HasPrefix(p.data[p.pos:], []byte("//")):
+			idx := bytes.IndexByte(p.data[p.pos:], '\n')
+			if idx < 0 {
+				p.pos = len(p.data)
+			} else {
+				p.pos += idx + 1
+			}
+		case bytes.HasPrefix(p.data[p.pos:], []byte("/*")):
+			idx := bytes.Index(p.data[p.pos+2:], []byte("*/"))
+			if idx < 0 {
+				return p.errorf("unterminated comment")
+			}
+			p.pos += idx + 4
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (p *json5Parser) parseValue() (interface{}, error) {
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unexpected end of input")
+	}
+
+	r := p.peekRune()
+	switch {
+	case r == '{':
+		return p.parseObject()
+	case r == '[':
+		return p.parseArray()
+	case r == '"' || r == '\'':
+		return p.parseString()
+	case r == '-' || r == '+' || r == '.' || (r >= '0' && r <= '9'):
+		return p.parseNumber()
+	}
+
+	start := p.pos
+	ident := p.parseIdentifier()
+	switch ident {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+
+	p.pos = start
+	return nil, p.errorf("unexpected character %q", r)
+}
+
+func (p *json5Parser) parseObject() (interface{}, error) {
+	// skip `{`
+	p.pos++
+	obj := make(map[string]interface{})
+	for {
+		err := p.skipSpace()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.pos >= len(p.data) {
+			return nil, p.errorf("unterminated object")
+		}
+
+		if p.data[p.pos] == '}' {
+			p.pos++
+			return obj, nil
+		}
+
+		var key string
+		switch p.data[p.pos] {
+		case '"', '\'':
+			key, err = p.parseString()
+			if err != nil {
+				return nil, err
+			}
+		default:
+			key = p.parseIdentifier()
+			if key == "" {
+				return nil, p.errorf("invalid object key starting with %q", p.peekRune())
+			}
+		}
+
+		err = p.skipSpace()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.pos >= len(p.data) || p.data[p.pos] != ':' {
+			return nil, p.errorf("expecting ':' after object key %q", key)
+		}
+		p.pos++
+
+		err = p.skipSpace()
+		if err != nil {
+			return nil, err
+		}
+
+		obj[key], err = p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		err = p.skipSpace()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.pos >= len(p.data) {
+			return nil, p.errorf("unterminated object")
+		}
+
+		switch p.data[p.pos] {
+		case ',':
+			p.pos++
+		case '}':
+		default:
+			return nil, p.errorf("expecting ',' or '}' in object, got %q", p.peekRune())
+		}
+	}
+}
+
+func (p *json5Parser) parseArray() (interface{}, error) {
+	// skip `[`
+	p.pos++
+	arr := make([]interface{}, 0)
+	for {
+		err := p.skipSpace()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.pos >= len(p.data) {
+			return nil, p.errorf("unterminated array")
+		}
+
+		if p.data[p.pos] == ']' {
+			p.pos++
+			return arr, nil
+		}
+
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+
+		err = p.skipSpace()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.pos >= len(p.data) {
+			return nil, p.errorf("unterminated array")
+		}
+
+		switch p.data[p.pos] {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, p.errorf("expecting ',' or ']' in array, got %q", p.peekRune())
+		}
+	}
+}
+
+// parseIdentifier parses ecmascript identifier name, returns empty string
+// if there is no identifier
+func (p *json5Parser) parseIdentifier() string {
+	start := p.pos
+	for p.pos < len(p.data) {
+		r := p.peekRune()
+		switch {
+		case r == '_' || r == '$' || unicode.IsLetter(r):
+		case p.pos != start && (unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Pc, r)):
+		default:
+			return string(p.data[start:p.pos])
+		}
+
+		p.nextRune()
+	}
+
+	return string(p.data[start:p.pos])
+}
+
+func (p *json5Parser) parseString() (string, error) {
+	quote := p.nextRune()
+	sb := &strings.Builder{}
+	for {
+		if p.pos >= len(p.data) {
+			return "", p.errorf("unterminated string")
+		}
+
+		r := p.nextRune()
+		switch r {
+		case quote:
+			return sb.String(), nil
+		case '\n', '\r':
+			p.pos--
+			return "", p.errorf("unescaped line break in string")
+		case '\\':
+		default:
+			sb.WriteRune(r)
+			continue
+		}
+
+		if p.pos >= len(p.data) {
+			return "", p.errorf("unterminated string")
+		}
+
+		r = p.nextRune()
+		switch r {
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'v':
+			sb.WriteByte('\v')
+		case '0':
+			if p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '9' {
+				return "", p.errorf("octal escape sequence is not allowed")
+			}
+			sb.WriteByte(0)
+		case 'x':
+			c, err := p.parseHex(2)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteRune(c)
+		case 'u':
+			c, err := p.parseHex(4)
+			if err != nil {
+				return "", err
+			}
+
+			// surrogate pair
+			if c >= 0xD800 && c < 0xDC00 && bytes.HasPrefix(p.data[p.pos:], []byte(`\u`)) {
+				p.pos += 2
+				c2, err := p.parseHex(4)
+				if err != nil {
+					return "", err
+				}
+				c = (c-0xD800)<<10 + (c2 - 0xDC00) + 0x10000
+			}
+			sb.WriteRune(c)
+		case '\n', '\u2028', '\u2029':
+			// line continuation
+		case '\r':
+			// line continuation
+			if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+				p.pos++
+			}
+		default:
+			if r >= '1' && r <= '9' {
+				return "", p.errorf("invalid escape sequence \\%c", r)
+			}
+
+			// other characters are escaped as is, including quotes and back slash
+			sb.WriteRune(r)
+		}
+	}
+}
+
+func (p *json5Parser) parseHex(n int) (rune, error) {
+	if p.pos+n > len(p.data) {
+		return 0, p.errorf("invalid hex escape sequence")
+	}
+
+	v, err := strconv.ParseUint(string(p.data[p.pos:p.pos+n]), 16, 32)
+	if err != nil {
+		return 0, p.errorf("invalid hex escape sequence")
+	}
+
+	p.pos += n
+	return rune(v), nil
+}
+
+func (p *json5Parser) parseNumber() (interface{}, error) {
+	start := p.pos
+
+	sign := 1.0
+	switch p.data[p.pos] {
+	case '-':
+		sign = -1
+		p.pos++
+	case '+':
+		p.pos++
+	}
+
+	rest := p.data[p.pos:]
+	switch {
+	case bytes.HasPrefix(rest, []byte("Infinity")):
+		p.pos += len("Infinity")
+		return math.Inf(int(sign)), nil
+	case bytes.HasPrefix(rest, []byte("NaN")):
+		p.pos += len("NaN")
+		return math.NaN(), nil
+	case bytes.HasPrefix(rest, []byte("0x")) || bytes.HasPrefix(rest, []byte("0X")):
+		p.pos += 2
+		digitsStart := p.pos
+		for p.pos < len(p.data) && isHexDigit(p.data[p.pos]) {
+			p.pos++
+		}
+
+		v, err := strconv.ParseUint(string(p.data[digitsStart:p.pos]), 16, 64)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid hex number")
+		}
+
+		return sign * float64(v), nil
+	}
+
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if (c >= '0' && c <= '9') || c == '.' || c == 'e' || c == 'E' ||
+			((c == '+' || c == '-') && (p.data[p.pos-1] == 'e' || p.data[p.pos-1] == 'E')) {
+			p.pos++
+			continue
+		}
+
+		break
+	}
+
+	num := string(p.data[start:p.pos])
+	digits := strings.TrimLeft(num, "+-")
+	if len(digits) > 1 && digits[0] == '0' && digits[1] >= '0' && digits[1] <= '9' {
+		p.pos = start
+		return nil, p.errorf("invalid number %q, leading zero is not allowed", num)
+	}
+
+	// strconv accepts leading and trailing decimal point, but not leading `+`
+	v, err := strconv.ParseFloat(strings.TrimPrefix(num, "+"), 64)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("invalid number %q", num)
+	}
+
+	return v, nil
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
@@ -0,0 +1,72 @@
+package validator
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJSON5(t *testing.T) {
+	const doc = `// renovate config
+{
+  extends: ['config:base', "group:allNonMajor",],
+  /* multi
+     line comment */
+  "packageRules": [{
+    matchPackagePatterns: ["^foo"], $enabled: false,
+  }],
+  prHourlyLimit: +0x10,
+  prConcurrentLimit: .5e1,
+  minimumReleaseAge: 3.,
+  'description': 'it\'s \x41 é \
+line',
+  timeout: -Infinity,
+}
+`
+
+	v, err := ParseJSON5([]byte(doc))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	obj := v.(map[string]interface{})
+	assert.Equal(t, []interface{}{"config:base", "group:allNonMajor"}, obj["extends"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"matchPackagePatterns": []interface{}{"^foo"},
+		"$enabled":             false,
+	}}, obj["packageRules"])
+	assert.Equal(t, float64(16), obj["prHourlyLimit"])
+	assert.Equal(t, float64(5), obj["prConcurrentLimit"])
+	assert.Equal(t, float64(3), obj["minimumReleaseAge"])
+	assert.Equal(t, "it's A é line", obj["description"])
+	assert.True(t, math.IsInf(obj["timeout"].(float64), -1))
+}
+
+func TestParseJSON5_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		err  string
+	}{
+		{name: "Empty", doc: "", err: "unexpected end of input"},
+		{name: "Unterminated Object", doc: `{"foo": 1`, err: "unterminated object"},
+		{name: "Missing Comma", doc: "{\n  foo: 1\n  bar: 2\n}", err: "line 3, column 3: expecting ',' or '}'"},
+		{name: "Double Comma", doc: `[1,,2]`, err: "unexpected character ','"},
+		{name: "Unterminated Comment", doc: `{} /* foo`, err: "unterminated comment"},
+		{name: "Line Break In String", doc: "'foo\nbar'", err: "unescaped line break"},
+		{name: "Leading Zero", doc: `{a: 01}`, err: "leading zero"},
+		{name: "Invalid Key", doc: `{1a: 1}`, err: "invalid object key"},
+		{name: "Trailing Value", doc: `{} {}`, err: "after top-level value"},
+		{name: "Unknown Identifier", doc: `{a: yes}`, err: "unexpected character 'y'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseJSON5([]byte(test.doc))
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), test.err)
+			}
+		})
+	}
+}
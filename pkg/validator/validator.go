@@ -0,0 +1,165 @@
+package validator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"arhat.dev/renovate-server/pkg/types"
+)
+
+// PackageJSON is the npm package manifest, renovate config can be set in the
+// `renovate` field
+const PackageJSON = "package.json"
+
+// max length of commit status description accepted by github
+const maxSummaryLength = 140
+
+// ErrNoConfig is returned when there is no renovate config in package.json
+var ErrNoConfig = errors.New("no renovate config")
+
+// ConfigFiles are paths of renovate config files in the repo root
+var ConfigFiles = []string{
+	"renovate.json",
+	"renovate.json5",
+	".github/renovate.json",
+	".github/renovate.json5",
+	".gitlab/renovate.json",
+	".gitlab/renovate.json5",
+	".renovaterc",
+	".renovaterc.json",
+	PackageJSON,
+}
+
+// ChangedConfigFiles returns renovate config files in changed files
+func ChangedConfigFiles(changed []string) []string {
+	var ret []string
+	for _, f := range ConfigFiles {
+		for _, c := range changed {
+			if strings.TrimPrefix(c, "/") == f {
+				ret = append(ret, f)
+				break
+			}
+		}
+	}
+
+	return ret
+}
+
+func New(external types.ConfigValidator) *Validator {
+	return &Validator{external: external}
+}
+
+// Validator of renovate config files
+type Validator struct {
+	// external validator (e.g. renovate-config-validator), nil if not available
+	external types.ConfigValidator
+}
+
+// Validate renovate config file, returns the problem found, empty if the config is
+// valid, ErrNoConfig if there is no renovate config in package.json
+func (v *Validator) Validate(file string, data []byte) (string, error) {
+	if file == PackageJSON {
+		pkg := make(map[string]json.RawMessage)
+		err := json.Unmarshal(data, &pkg)
+		if err != nil {
+			return fmt.Sprintf("invalid json: %v", err), nil
+		}
+
+		cfg, ok := pkg["renovate"]
+		if !ok {
+			return "", ErrNoConfig
+		}
+
+		var parsed interface{}
+		_ = json.Unmarshal(cfg, &parsed)
+		if _, ok = parsed.(map[string]interface{}); !ok {
+			return "renovate config is not an object", nil
+		}
+	} else {
+		parsed, err := ParseJSON5(data)
+		if err != nil {
+			return fmt.Sprintf("invalid json5: %v", err), nil
+		}
+
+		if _, ok := parsed.(map[string]interface{}); !ok {
+			return "renovate config is not an object", nil
+		}
+	}
+
+	if v.external == nil {
+		return "", nil
+	}
+
+	return v.external.ValidateConfig(file, data)
+}
+
+// Report of validated renovate config files
+type Report struct {
+	files    []string
+	problems map[string]string
+}
+
+// Add validation result of the file
+func (r *Report) Add(file, problem string) {
+	r.files = append(r.files, file)
+	if problem == "" {
+		return
+	}
+
+	if r.problems == nil {
+		r.problems = make(map[string]string)
+	}
+
+	r.problems[file] = problem
+}
+
+// Empty returns true if no file validated
+func (r *Report) Empty() bool {
+	return len(r.files) == 0
+}
+
+// Valid returns true if no problem found
+func (r *Report) Valid() bool {
+	return len(r.problems) == 0
+}
+
+// Summary is the one line description of the report
+func (r *Report) Summary() string {
+	var summary string
+	if r.Valid() {
+		summary = fmt.Sprintf("renovate config valid: %s", strings.Join(r.files, ", "))
+	} else {
+		invalid := make([]string, 0, len(r.problems))
+		for f := range r.problems {
+			invalid = append(invalid, f)
+		}
+		sort.Strings(invalid)
+
+		summary = fmt.Sprintf("renovate config invalid: %s", strings.Join(invalid, ", "))
+	}
+
+	if len(summary) > maxSummaryLength {
+		summary = summary[:maxSummaryLength-3] + "..."
+	}
+
+	return summary
+}
+
+// Details formats problems found as markdown
+func (r *Report) Details() string {
+	sb := &strings.Builder{}
+	sb.WriteString("**Renovate config validation failed**\n")
+	for _, f := range r.files {
+		p, ok := r.problems[f]
+		if !ok {
+			continue
+		}
+
+		sb.WriteString(fmt.Sprintf("\n`%s`:\n\n```\n%s\n```\n", f, strings.TrimSpace(p)))
+	}
+
+	return sb.String()
+}
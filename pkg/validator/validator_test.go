@@ -0,0 +1,69 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeValidator struct {
+	problem string
+}
+
+func (v *fakeValidator) ValidateConfig(file string, data []byte) (string, error) {
+	return v.problem, nil
+}
+
+func TestChangedConfigFiles(t *testing.T) {
+	assert.Equal(t,
+		[]string{"renovate.json", ".github/renovate.json5", "package.json"},
+		ChangedConfigFiles([]string{"package.json", "web/package.json", ".github/renovate.json5", "renovate.json"}),
+	)
+	assert.Empty(t, ChangedConfigFiles([]string{"docs/renovate.json"}))
+}
+
+func TestValidator_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		data     string
+		external string
+
+		problem string
+		err     error
+	}{
+		{name: "Valid", file: "renovate.json5", data: `{extends: ['config:base']}`},
+		{name: "Invalid JSON5", file: "renovate.json", data: `{extends: }`, problem: "invalid json5: line 1, column 11: "},
+		{name: "Not Object", file: ".renovaterc", data: `[]`, problem: "renovate config is not an object"},
+		{name: "External", file: "renovate.json", data: `{}`, external: "unknown option", problem: "unknown option"},
+		{name: "Package JSON", file: PackageJSON, data: `{"name":"foo","renovate":{"extends":["config:base"]}}`},
+		{name: "Package JSON No Config", file: PackageJSON, data: `{"name":"foo"}`, err: ErrNoConfig},
+		{name: "Package JSON Invalid", file: PackageJSON, data: `{"renovate":"foo"}`, problem: "renovate config is not an object"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			problem, err := New(&fakeValidator{problem: test.external}).Validate(test.file, []byte(test.data))
+			assert.Equal(t, test.err, err)
+			if test.problem == "" {
+				assert.Empty(t, problem)
+			} else {
+				assert.Contains(t, problem, test.problem)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	r := &Report{}
+	assert.True(t, r.Empty())
+
+	r.Add("renovate.json", "")
+	assert.True(t, r.Valid())
+	assert.Equal(t, "renovate config valid: renovate.json", r.Summary())
+
+	r.Add("package.json", "renovate config is not an object")
+	assert.False(t, r.Valid())
+	assert.Equal(t, "renovate config invalid: package.json", r.Summary())
+	assert.Contains(t, r.Details(), "`package.json`:\n\n```\nrenovate config is not an object\n```")
+}